@@ -1,41 +1,95 @@
 package api
 
 import (
-	"database/sql"
-	"log"
+	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
 	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/token"
+	"github.com/minhdang2803/simple_bank/utils"
 )
 
+type AccountResponse struct {
+	ID             int64       `json:"id"`
+	Owner          string      `json:"owner"`
+	Balance        utils.Money `json:"balance"`
+	OverdraftLimit utils.Money `json:"overdraft_limit"`
+	Currency       string      `json:"currency"`
+	Status         string      `json:"status"`
+	CreatedAt      time.Time   `json:"created_at"`
+	// AvailableBalance is the balance minus the funds reserved by active
+	// holds, it is only returned for a single account
+	AvailableBalance *utils.Money `json:"available_balance,omitempty"`
+}
+
+func newAccountResponse(account db.Account) AccountResponse {
+	return AccountResponse{
+		ID:             account.ID,
+		Owner:          account.Owner,
+		Balance:        utils.NewMoney(account.Balance, account.Currency),
+		OverdraftLimit: utils.NewMoney(account.OverdraftLimit, account.Currency),
+		Currency:       account.Currency,
+		Status:         account.Status,
+		CreatedAt:      account.CreatedAt,
+	}
+}
+
+func newAccountsResponse(accounts []db.Account) []AccountResponse {
+	response := make([]AccountResponse, 0, len(accounts))
+	for _, account := range accounts {
+		response = append(response, newAccountResponse(account))
+	}
+	return response
+}
+
+// replayAccount renders the account stored with an idempotency key
+func replayAccount(body []byte) (interface{}, error) {
+	var account db.Account
+	if err := json.Unmarshal(body, &account); err != nil {
+		return nil, err
+	}
+	return newAccountResponse(account), nil
+}
+
 type CreateAccountRequest struct {
-	Owner    string `json:"owner" binding:"required"`
-	Currency string `json:"currency" binding:"required,currency"`
+	Currency string `json:"currency" binding:"required,enabled_currency"`
 }
 
 func (server *Server) CreateAccount(ctx *gin.Context) {
 	var request CreateAccountRequest
 	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	idempotency, proceed := server.checkIdempotency(ctx, request, replayAccount)
+	if !proceed {
 		return
 	}
-	arg := db.CreateAccountParams{
-		Owner:    request.Owner,
-		Balance:  0,
-		Currency: request.Currency,
+	defer server.saveIdempotentError(ctx, idempotency)
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	arg := db.CreateAccountTxParams{
+		CreateAccountParams: db.CreateAccountParams{
+			Owner:    authPayload.Username,
+			Balance:  0,
+			Currency: request.Currency,
+		},
+		Idempotency: idempotency,
 	}
 
-	account, err := server.store.CreateAccount(ctx, arg)
+	account, err := server.store.CreateAccountTx(ctx, arg)
 	if err != nil {
-		if pqError, ok := err.(*pq.Error); ok {
-			log.Print(pqError, pqError.Code.Name())
+		if isIdempotencyConflict(err) {
+			server.replayConcurrent(ctx, idempotency, replayAccount)
+			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, account)
+	ctx.JSON(http.StatusOK, newAccountResponse(account))
 }
 
 type GetAccountRequest struct {
@@ -45,46 +99,117 @@ type GetAccountRequest struct {
 func (server *Server) GetAccount(ctx *gin.Context) {
 	var request GetAccountRequest
 	if err := ctx.ShouldBindUri(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	account, ok := server.readableAccount(ctx, request.Id)
+	if !ok {
+		return
+	}
+
+	held, err := server.store.GetHeldAmount(ctx, account.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	response := newAccountResponse(account)
+	available := utils.NewMoney(account.Balance-held, account.Currency)
+	response.AvailableBalance = &available
+	ctx.JSON(http.StatusOK, response)
+}
+
+// CloseAccount closes an account of the authenticated user. The account must
+// have a zero balance and no active hold, it stays readable once closed.
+func (server *Server) CloseAccount(ctx *gin.Context) {
+	var request GetAccountRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
 		return
 	}
 
 	account, err := server.store.GetAccount(ctx, request.Id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if account.Owner != authPayload.Username {
+		abortWithError(ctx, errForbidden("account doesn't belong to the authenticated user"))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, account)
+	account, err = server.store.CloseAccount(ctx, account.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newAccountResponse(account))
 }
 
-type GetListAccountRequest struct {
-	PageID   int32 `form:"page_id" binding:"required,min=1"`
-	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+// readableAccount loads an account the caller may read, the handler must stop when it returns false
+func (server *Server) readableAccount(ctx *gin.Context, accountID int64) (db.Account, bool) {
+	account, err := server.store.GetAccount(ctx, accountID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return account, false
+	}
+
+	// bankers and admins can look up accounts they don't own for support purposes
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if account.Owner != authPayload.Username && !hasRole(authPayload, utils.BankerRole, utils.AdminRole) {
+		abortWithError(ctx, errForbidden("account doesn't belong to the authenticated user"))
+		return account, false
+	}
+	return account, true
 }
 
+// ListAccount returns the accounts of the authenticated user, see PageRequest
 func (server *Server) ListAccount(ctx *gin.Context) {
-	var request GetListAccountRequest
+	var request PageRequest
 	if err := ctx.ShouldBindQuery(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, errInvalidRequest(err))
 		return
 	}
-	accounts, err := server.store.ListAccounts(ctx, db.ListAccountsParams{
-		Limit:  request.PageSize,
-		Offset: request.PageID,
-	})
+
+	page, err := server.newPageQuery(request)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, accounts)
 
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	var accounts []db.Account
+	if page.Backward {
+		accounts, err = server.store.ListAccountsByOwnerBefore(ctx, db.ListAccountsByOwnerBeforeParams{
+			Owner:    authPayload.Username,
+			BeforeID: page.ID,
+			Limit:    page.limit(),
+		})
+	} else {
+		accounts, err = server.store.ListAccountsByOwner(ctx, db.ListAccountsByOwnerParams{
+			Owner:   authPayload.Username,
+			AfterID: page.ID,
+			Limit:   page.limit(),
+		})
+	}
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAccountsPage(page, accounts))
+}
+
+func newAccountsPage(page pageQuery, accounts []db.Account) Page[AccountResponse] {
+	accounts, next, prev := paginate(page, accounts, func(account db.Account) int64 {
+		return account.ID
+	})
+	return Page[AccountResponse]{
+		Items:      newAccountsResponse(accounts),
+		NextCursor: next,
+		PrevCursor: prev,
+	}
 }
@@ -9,10 +9,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	mockdb "github.com/minhdang2803/simple_bank/db/mock"
 	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/token"
 	"github.com/minhdang2803/simple_bank/utils"
 	"github.com/stretchr/testify/require"
 )
@@ -20,6 +22,7 @@ import (
 func getGetAccountAPITestCases() []struct {
 	name          string
 	accountID     int64
+	setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
 	buildStubs    func(store *mockdb.MockStore)
 	checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 } {
@@ -27,25 +30,82 @@ func getGetAccountAPITestCases() []struct {
 	testCases := []struct {
 		name          string
 		accountID     int64
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{{
 		name:      "OK",
 		accountID: account.ID,
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			addAuthorization(t, request, tokenMaker, authorizationTypeBearer, account.Owner, utils.DepositorRole, time.Minute)
+		},
 		buildStubs: func(store *mockdb.MockStore) {
 			store.EXPECT().
 				GetAccount(gomock.Any(), gomock.Eq(account.ID)).
 				Times(1).Return(account, nil)
+			store.EXPECT().
+				GetHeldAmount(gomock.Any(), gomock.Eq(account.ID)).
+				Times(1).Return(int64(25), nil)
 		},
 		checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 			require.Equal(t, http.StatusOK, recorder.Code)
-			requireBodyMatchAccount(t, recorder, account)
+			requireBodyMatchAccountDetails(t, recorder, account, 25)
 		},
 	},
-
+		{
+			name:      "Unauthorized user",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", utils.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:      "Banker can view other accounts",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, "banker", utils.BankerRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).Return(account, nil)
+				store.EXPECT().
+					GetHeldAmount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).Return(int64(0), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccountDetails(t, recorder, account, 0)
+			},
+		},
+		{
+			name:      "No authorization",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name:      "Not found",
 			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, account.Owner, utils.DepositorRole, time.Minute)
+			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
@@ -58,6 +118,9 @@ func getGetAccountAPITestCases() []struct {
 		{
 			name:      "Internal Error",
 			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, account.Owner, utils.DepositorRole, time.Minute)
+			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
@@ -70,6 +133,9 @@ func getGetAccountAPITestCases() []struct {
 		{
 			name:      "Internal Error",
 			accountID: -1,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, account.Owner, utils.DepositorRole, time.Minute)
+			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Any()).
@@ -93,13 +159,14 @@ func TestGetAccountAPI(t *testing.T) {
 			///
 			tc.buildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recoder := httptest.NewRecorder()
 			url := fmt.Sprintf("/accounts/%d", tc.accountID)
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
 
 			//
+			tc.setupAuth(t, request, server.tokenMaker)
 			server.router.ServeHTTP(recoder, request)
 			tc.checkResponse(t, recoder)
 		})
@@ -107,80 +174,165 @@ func TestGetAccountAPI(t *testing.T) {
 }
 
 func TestListAccountAPI(t *testing.T) {
+	owner := utils.RandomOwner()
 	listAccount := []db.Account{}
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 6; i++ {
 		newAccount := RandomAccount()
+		newAccount.ID = int64(i + 1)
+		newAccount.Owner = owner
 		listAccount = append(listAccount, newAccount)
 	}
 
 	testCases := []struct {
 		name          string
-		params        db.ListAccountsParams
-		buildStubs    func(store *mockdb.MockStore, param db.ListAccountsParams)
+		query         string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
-			name: "Happy case",
-			params: db.ListAccountsParams{
-				Limit:  5,
-				Offset: 1,
+			name:  "Happy case",
+			query: "page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, owner, utils.DepositorRole, time.Minute)
 			},
-			buildStubs: func(store *mockdb.MockStore, param db.ListAccountsParams) {
-				store.EXPECT().ListAccounts(gomock.Any(), gomock.Eq(param)).Times(1).Return(listAccount, nil)
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAccountsByOwnerParams{Owner: owner, AfterID: 0, Limit: 6}
+				store.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Eq(arg)).Times(1).Return(listAccount[:5], nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
-				requireBodyMatchAccounts(t, recorder.Body, listAccount)
+				page := requireBodyMatchAccounts(t, recorder.Body, listAccount[:5])
+				require.Empty(t, page.NextCursor)
+				require.Empty(t, page.PrevCursor)
 			},
 		},
 		{
-			name: "Internal server error",
-			params: db.ListAccountsParams{
-				Limit:  5,
-				Offset: 1,
+			name:  "Default page size",
+			query: "",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, owner, utils.DepositorRole, time.Minute)
 			},
-			buildStubs: func(store *mockdb.MockStore, param db.ListAccountsParams) {
-				store.EXPECT().ListAccounts(gomock.Any(), gomock.Eq(param)).Times(1).Return([]db.Account{}, sql.ErrConnDone)
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAccountsByOwnerParams{Owner: owner, AfterID: 0, Limit: defaultPageSize + 1}
+				store.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Eq(arg)).Times(1).Return(listAccount, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:  "Has next page",
+			query: "page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, owner, utils.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Any()).Times(1).Return(listAccount, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				page := requireBodyMatchAccounts(t, recorder.Body, listAccount[:5])
+				require.Equal(t, encodeCursor(cursor{ID: listAccount[4].ID}), page.NextCursor)
+				require.Empty(t, page.PrevCursor)
+			},
+		},
+		{
+			name:  "Next cursor",
+			query: "page_size=5&cursor=" + encodeCursor(cursor{ID: 3}),
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, owner, utils.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAccountsByOwnerParams{Owner: owner, AfterID: 3, Limit: 6}
+				store.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Eq(arg)).Times(1).Return(listAccount[3:], nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				page := requireBodyMatchAccounts(t, recorder.Body, listAccount[3:])
+				require.Empty(t, page.NextCursor)
+				require.Equal(t, encodeCursor(cursor{ID: 4, Backward: true}), page.PrevCursor)
+			},
+		},
+		{
+			name:  "Prev cursor",
+			query: "page_size=2&cursor=" + encodeCursor(cursor{ID: 4, Backward: true}),
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, owner, utils.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAccountsByOwnerBeforeParams{Owner: owner, BeforeID: 4, Limit: 3}
+				// newest first, as returned by the query
+				rows := []db.Account{listAccount[2], listAccount[1], listAccount[0]}
+				store.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListAccountsByOwnerBefore(gomock.Any(), gomock.Eq(arg)).Times(1).Return(rows, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				page := requireBodyMatchAccounts(t, recorder.Body, listAccount[1:3])
+				require.Equal(t, encodeCursor(cursor{ID: 3}), page.NextCursor)
+				require.Equal(t, encodeCursor(cursor{ID: 2, Backward: true}), page.PrevCursor)
+			},
+		},
+		{
+			name:  "No authorization",
+			query: "page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:  "Internal server error",
+			query: "page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, owner, utils.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Any()).Times(1).Return([]db.Account{}, sql.ErrConnDone)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
 			},
 		},
 		{
-			name: "Not found",
-			params: db.ListAccountsParams{
-				Limit:  5,
-				Offset: 1,
+			name:  "Invalid cursor",
+			query: "page_size=5&cursor=not-a-cursor",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, owner, utils.DepositorRole, time.Minute)
 			},
-			buildStubs: func(store *mockdb.MockStore, param db.ListAccountsParams) {
-				store.EXPECT().ListAccounts(gomock.Any(), gomock.Eq(param)).Times(1).Return([]db.Account{}, sql.ErrNoRows)
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
 			},
 		},
-
 		{
-			name: "Bad Request 1",
-			params: db.ListAccountsParams{
-				Limit:  5,
-				Offset: -1,
+			name:  "Negative page size",
+			query: "page_size=-1",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, owner, utils.DepositorRole, time.Minute)
 			},
-			buildStubs: func(store *mockdb.MockStore, param db.ListAccountsParams) {
-				store.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(0)
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
 			},
 		},
 		{
-			name: "Bad Request 2",
-			params: db.ListAccountsParams{
-				Limit:  0,
-				Offset: 1,
+			name:  "Page size above maximum",
+			query: "page_size=1000",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, owner, utils.DepositorRole, time.Minute)
 			},
-			buildStubs: func(store *mockdb.MockStore, param db.ListAccountsParams) {
-				store.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(0)
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
@@ -196,14 +348,15 @@ func TestListAccountAPI(t *testing.T) {
 			store := mockdb.NewMockStore(ctrl)
 
 			// build stubs
-			tc.buildStubs(store, tc.params)
+			tc.buildStubs(store)
 			//
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
-			url := fmt.Sprintf("/accounts?page_size=%d&page_id=%d", tc.params.Limit, tc.params.Offset)
+			url := "/accounts?" + tc.query
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
 
+			tc.setupAuth(t, request, server.tokenMaker)
 			//serve the request
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
@@ -217,36 +370,84 @@ func TestCreateAccountAPI(t *testing.T) {
 	testCase := []struct {
 		name          string
 		body          func(account *db.Account) []byte
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker, account *db.Account)
 		buildStubs    func(store *mockdb.MockStore, account *db.Account)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, account *db.Account)
 	}{{
 		name: "Happy case",
 		body: func(account *db.Account) []byte {
 			request, _ := json.Marshal(CreateAccountRequest{
-				Owner:    account.Owner,
 				Currency: account.Currency,
 			})
 			return request
 		},
+		setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, account *db.Account) {
+			addAuthorization(t, request, tokenMaker, authorizationTypeBearer, account.Owner, utils.DepositorRole, time.Minute)
+		},
 		buildStubs: func(store *mockdb.MockStore, account *db.Account) {
-			store.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(1).Return(*account, nil)
+			arg := db.CreateAccountTxParams{
+				CreateAccountParams: db.CreateAccountParams{
+					Owner:    account.Owner,
+					Balance:  0,
+					Currency: account.Currency,
+				},
+			}
+			store.EXPECT().CreateAccountTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(*account, nil)
 		},
 		checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, account *db.Account) {
 			require.Equal(t, http.StatusOK, recorder.Code)
 			requireBodyMatchAccount(t, recorder, *account)
 		},
 	},
+		{
+			name: "Disabled currency",
+			body: func(account *db.Account) []byte {
+				request, _ := json.Marshal(CreateAccountRequest{
+					Currency: utils.BHD,
+				})
+				return request
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, account *db.Account) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, account.Owner, utils.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore, account *db.Account) {
+				store.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, account *db.Account) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "is not a currency new accounts can be opened in")
+			},
+		},
+		{
+			name: "No authorization",
+			body: func(account *db.Account) []byte {
+				request, _ := json.Marshal(CreateAccountRequest{
+					Currency: account.Currency,
+				})
+				return request
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, account *db.Account) {
+			},
+			buildStubs: func(store *mockdb.MockStore, account *db.Account) {
+				store.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, account *db.Account) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name: "StatusInternalServerError case",
 			body: func(account *db.Account) []byte {
 				request, _ := json.Marshal(CreateAccountRequest{
-					Owner:    account.Owner,
 					Currency: account.Currency,
 				})
 				return request
 			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, account *db.Account) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, account.Owner, utils.DepositorRole, time.Minute)
+			},
 			buildStubs: func(store *mockdb.MockStore, account *db.Account) {
-				store.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, sql.ErrConnDone)
+				store.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, sql.ErrConnDone)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, account *db.Account) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
@@ -255,13 +456,14 @@ func TestCreateAccountAPI(t *testing.T) {
 		{
 			name: "BadRequest case",
 			body: func(account *db.Account) []byte {
-				request, _ := json.Marshal(CreateAccountRequest{
-					Owner: account.Owner,
-				})
+				request, _ := json.Marshal(CreateAccountRequest{})
 				return request
 			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker, account *db.Account) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, account.Owner, utils.DepositorRole, time.Minute)
+			},
 			buildStubs: func(store *mockdb.MockStore, account *db.Account) {
-				store.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, account *db.Account) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
@@ -281,7 +483,7 @@ func TestCreateAccountAPI(t *testing.T) {
 				store := mockdb.NewMockStore(ctrl)
 				tc.buildStubs(store, &account)
 
-				server := NewServer(store)
+				server := newTestServer(t, store)
 				recorder := httptest.NewRecorder()
 				url := "/accounts"
 				request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(tc.body(&account)))
@@ -289,6 +491,7 @@ func TestCreateAccountAPI(t *testing.T) {
 
 				// Add the appropriate headers
 				request.Header.Set("Content-Type", "application/json")
+				tc.setupAuth(t, request, server.tokenMaker, &account)
 
 				//Serve the request
 				server.router.ServeHTTP(recorder, request)
@@ -299,32 +502,123 @@ func TestCreateAccountAPI(t *testing.T) {
 
 }
 
-func requireBodyMatchAccounts(t *testing.T, body *bytes.Buffer, acccounts []db.Account) {
+func TestCloseAccountAPI(t *testing.T) {
+	account := RandomAccount()
+
+	testCases := []struct {
+		name          string
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: account.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				closed := account
+				closed.Balance = 0
+				closed.Status = db.AccountClosed
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().CloseAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(closed, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"closed"`)
+			},
+		},
+		{
+			name:     "Not empty",
+			username: account.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().CloseAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).
+					Return(db.Account{}, fmt.Errorf("%w: account [%d] has a balance of 10", db.ErrAccountNotEmpty, account.ID))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "has a balance of 10")
+			},
+		},
+		{
+			name:     "Not the owner",
+			username: "someone",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().CloseAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/accounts/%d/close", account.ID)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, utils.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+// requireBodyMatchAccounts checks the items of a page and returns it for cursor checks
+func requireBodyMatchAccounts(t *testing.T, body *bytes.Buffer, acccounts []db.Account) Page[json.RawMessage] {
 	data, err := io.ReadAll(body)
 	require.NoError(t, err)
 
-	var gotAccounts []db.Account
-	err = json.Unmarshal(data, &gotAccounts)
-	require.NoError(t, err)
+	var page Page[json.RawMessage]
+	require.NoError(t, json.Unmarshal(data, &page))
 
-	require.Equal(t, acccounts, gotAccounts)
+	expected, err := json.Marshal(newAccountsResponse(acccounts))
+	require.NoError(t, err)
+	got, err := json.Marshal(page.Items)
+	require.NoError(t, err)
+	require.JSONEq(t, string(expected), string(got))
+	return page
 }
 
 func requireBodyMatchAccount(t *testing.T, body *httptest.ResponseRecorder, account db.Account) {
 	data, err := io.ReadAll(body.Body)
 	require.NoError(t, err)
 
-	var gotAccount db.Account
-	err = json.Unmarshal(data, &gotAccount)
+	expected, err := json.Marshal(newAccountResponse(account))
 	require.NoError(t, err)
+	require.JSONEq(t, string(expected), string(data))
+}
 
-	require.Equal(t, account, gotAccount)
+// requireBodyMatchAccountDetails checks the response of GetAccount, which
+// carries the available balance as well
+func requireBodyMatchAccountDetails(t *testing.T, body *httptest.ResponseRecorder, account db.Account, held int64) {
+	data, err := io.ReadAll(body.Body)
+	require.NoError(t, err)
+
+	response := newAccountResponse(account)
+	available := utils.NewMoney(account.Balance-held, account.Currency)
+	response.AvailableBalance = &available
+	expected, err := json.Marshal(response)
+	require.NoError(t, err)
+	require.JSONEq(t, string(expected), string(data))
 }
+
 func RandomAccount() db.Account {
 	return db.Account{
 		ID:       utils.RandomInt(1, 1000),
 		Owner:    utils.RandomOwner(),
 		Balance:  utils.RandomMoney(),
 		Currency: utils.RandomCurrency2(),
+		Status:   db.AccountActive,
 	}
 }
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+// ListUsers returns every registered user in username order, admin only
+func (server *Server) ListUsers(ctx *gin.Context) {
+	var request PageRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	page, err := server.newPageQuery(request)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	var users []db.User
+	if page.Backward {
+		users, err = server.store.ListUsersBefore(ctx, db.ListUsersBeforeParams{
+			BeforeUsername: page.Key,
+			Limit:          page.limit(),
+		})
+	} else {
+		users, err = server.store.ListUsers(ctx, db.ListUsersParams{
+			AfterUsername: page.Key,
+			Limit:         page.limit(),
+		})
+	}
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	users, next, prev := paginateByKey(page, users, func(user db.User) string {
+		return user.Username
+	})
+	response := make([]UserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, newUserResponse(user))
+	}
+	ctx.JSON(http.StatusOK, Page[UserResponse]{
+		Items:      response,
+		NextCursor: next,
+		PrevCursor: prev,
+	})
+}
+
+// ListAllAccounts returns accounts across every owner, admin only
+func (server *Server) ListAllAccounts(ctx *gin.Context) {
+	var request PageRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	page, err := server.newPageQuery(request)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	var accounts []db.Account
+	if page.Backward {
+		accounts, err = server.store.ListAccountsBefore(ctx, db.ListAccountsBeforeParams{
+			BeforeID: page.ID,
+			Limit:    page.limit(),
+		})
+	} else {
+		accounts, err = server.store.ListAccounts(ctx, db.ListAccountsParams{
+			AfterID: page.ID,
+			Limit:   page.limit(),
+		})
+	}
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newAccountsPage(page, accounts))
+}
+
+// errOverdraftBelowDebt refuses a limit the account is already overdrawn
+// beyond, the balance check of the database would report it as insufficient funds
+var errOverdraftBelowDebt = &apiError{
+	status:  http.StatusBadRequest,
+	code:    codeInvalidRequest,
+	message: "invalid request",
+	details: []ErrorDetail{{Field: "overdraft_limit", Message: "must cover the current debt of the account"}},
+}
+
+type UpdateOverdraftLimitRequest struct {
+	// OverdraftLimit is a decimal amount in the account's currency
+	OverdraftLimit string `json:"overdraft_limit" binding:"required"`
+}
+
+// UpdateOverdraftLimit sets how far below zero an account may go, admin only
+func (server *Server) UpdateOverdraftLimit(ctx *gin.Context) {
+	var uri GetAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	var request UpdateOverdraftLimitRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, uri.Id)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	limit, err := utils.ParseMoney(request.OverdraftLimit, account.Currency)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	if limit.Amount < 0 {
+		abortWithError(ctx, errBadRequest("overdraft limit can't be negative"))
+		return
+	}
+	if account.Balance < -limit.Amount {
+		abortWithError(ctx, errOverdraftBelowDebt)
+		return
+	}
+
+	account, err = server.store.UpdateAccountOverdraftLimit(ctx, db.UpdateAccountOverdraftLimitParams{
+		ID:             uri.Id,
+		OverdraftLimit: limit.Amount,
+	})
+	if err != nil {
+		// the balance went down since it was read
+		if errors.Is(db.TranslateError(err), db.ErrInsufficientFunds) {
+			err = errOverdraftBelowDebt
+		}
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newAccountResponse(account))
+}
+
+// FreezeAccount stops money from moving in or out of an active account, admin only
+func (server *Server) FreezeAccount(ctx *gin.Context) {
+	server.updateAccountStatus(ctx, "frozen", server.store.FreezeAccount)
+}
+
+// UnfreezeAccount reactivates a frozen account, admin only
+func (server *Server) UnfreezeAccount(ctx *gin.Context) {
+	server.updateAccountStatus(ctx, "unfrozen", server.store.UnfreezeAccount)
+}
+
+// updateAccountStatus applies update to the account of the request, no row
+// means the account isn't in a status the update applies to
+func (server *Server) updateAccountStatus(ctx *gin.Context, action string, update func(ctx context.Context, id int64) (db.Account, error)) {
+	var uri GetAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, uri.Id)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	updated, err := update(ctx, account.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			abortWithError(ctx, newAPIError(http.StatusConflict, codeConflict,
+				fmt.Sprintf("account is %s and can't be %s", account.Status, action)))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newAccountResponse(updated))
+}
+
+type VerifyLedgerRequest struct {
+	Snapshot bool `form:"snapshot"`
+}
+
+type LedgerReportResponse struct {
+	OK bool `json:"ok"`
+	db.LedgerReport
+}
+
+// VerifyLedger runs the ledger integrity checks, admin only. Discrepancies
+// are part of a successful response, check the ok field.
+func (server *Server) VerifyLedger(ctx *gin.Context) {
+	var request VerifyLedgerRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	report, err := server.store.VerifyLedger(ctx, db.VerifyLedgerParams{Snapshot: request.Snapshot})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, LedgerReportResponse{OK: report.OK(), LedgerReport: report})
+}
@@ -0,0 +1,408 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminListUsersAPI(t *testing.T) {
+	users := make([]db.User, 3)
+	for i := range users {
+		users[i], _ = RandomUser(t)
+		users[i].Username = fmt.Sprintf("user%d%s", i, users[i].Username)
+	}
+
+	testCases := []struct {
+		name          string
+		role          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "Admin",
+			role:  utils.AdminRole,
+			query: "page_size=2",
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListUsersParams{AfterUsername: "", Limit: 3}
+				store.EXPECT().ListUsers(gomock.Any(), gomock.Eq(arg)).Times(1).Return(users, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.NotContains(t, recorder.Body.String(), users[0].HashedPassword)
+
+				page := decodeUsersPage(t, recorder)
+				require.Len(t, page.Items, 2)
+				require.Equal(t, users[1].Username, page.Items[1].Username)
+				require.Equal(t, encodeCursor(cursor{Key: users[1].Username}), page.NextCursor)
+				require.Empty(t, page.PrevCursor)
+			},
+		},
+		{
+			name:  "Next cursor",
+			role:  utils.AdminRole,
+			query: "page_size=2&cursor=" + encodeCursor(cursor{Key: users[1].Username}),
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListUsersParams{AfterUsername: users[1].Username, Limit: 3}
+				store.EXPECT().ListUsers(gomock.Any(), gomock.Eq(arg)).Times(1).Return(users[2:], nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				page := decodeUsersPage(t, recorder)
+				require.Len(t, page.Items, 1)
+				require.Empty(t, page.NextCursor)
+				require.Equal(t, encodeCursor(cursor{Key: users[2].Username, Backward: true}), page.PrevCursor)
+			},
+		},
+		{
+			name:  "Prev cursor",
+			role:  utils.AdminRole,
+			query: "page_size=1&cursor=" + encodeCursor(cursor{Key: users[2].Username, Backward: true}),
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListUsersBeforeParams{BeforeUsername: users[2].Username, Limit: 2}
+				// newest first, as returned by the query
+				rows := []db.User{users[1], users[0]}
+				store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListUsersBefore(gomock.Any(), gomock.Eq(arg)).Times(1).Return(rows, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				page := decodeUsersPage(t, recorder)
+				require.Len(t, page.Items, 1)
+				require.Equal(t, users[1].Username, page.Items[0].Username)
+				require.Equal(t, encodeCursor(cursor{Key: users[1].Username}), page.NextCursor)
+				require.Equal(t, encodeCursor(cursor{Key: users[1].Username, Backward: true}), page.PrevCursor)
+			},
+		},
+		{
+			name:  "PageSizeTooLarge",
+			role:  utils.AdminRole,
+			query: fmt.Sprintf("page_size=%d", fallbackMaxPageSize+1),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidCursor",
+			role:  utils.AdminRole,
+			query: "cursor=abc",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "Depositor",
+			role:  utils.DepositorRole,
+			query: "page_size=2",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:  "Banker",
+			role:  utils.BankerRole,
+			query: "page_size=2",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/admin/users?"+tc.query, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func decodeUsersPage(t *testing.T, recorder *httptest.ResponseRecorder) Page[UserResponse] {
+	var page Page[UserResponse]
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &page))
+	return page
+}
+
+func TestAdminVerifyLedgerAPI(t *testing.T) {
+	report := db.LedgerReport{
+		Accounts:  2,
+		Entries:   3,
+		Transfers: 1,
+		Discrepancies: []db.LedgerDiscrepancy{
+			{Kind: db.DiscrepancyTransfer, TransferID: 1, Detail: "has 1 entries instead of 2"},
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		role          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "Mismatch",
+			role:  utils.AdminRole,
+			query: "?snapshot=true",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().VerifyLedger(gomock.Any(), gomock.Eq(db.VerifyLedgerParams{Snapshot: true})).Times(1).Return(report, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got LedgerReportResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.False(t, got.OK)
+				require.Equal(t, int64(2), got.Accounts)
+				require.Equal(t, report.Discrepancies, got.Discrepancies)
+			},
+		},
+		{
+			name: "Consistent",
+			role: utils.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().VerifyLedger(gomock.Any(), gomock.Eq(db.VerifyLedgerParams{})).Times(1).
+					Return(db.LedgerReport{Discrepancies: []db.LedgerDiscrepancy{}}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"ok":true`)
+			},
+		},
+		{
+			name: "Banker",
+			role: utils.BankerRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().VerifyLedger(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "Internal error",
+			role: utils.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().VerifyLedger(gomock.Any(), gomock.Any()).Times(1).Return(db.LedgerReport{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/admin/ledger/verify"+tc.query, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAdminFreezeAccountAPI(t *testing.T) {
+	account := RandomAccount()
+
+	testCases := []struct {
+		name          string
+		action        string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "Freeze",
+			action: "freeze",
+			role:   utils.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				frozen := account
+				frozen.Status = db.AccountFrozen
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().FreezeAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(frozen, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"frozen"`)
+			},
+		},
+		{
+			name:   "Unfreeze an active account",
+			action: "unfreeze",
+			role:   utils.AdminRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().UnfreezeAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "account is active and can't be unfrozen")
+			},
+		},
+		{
+			name:   "Banker",
+			action: "freeze",
+			role:   utils.BankerRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().FreezeAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/admin/accounts/%d/%s", account.ID, tc.action)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAdminUpdateOverdraftLimitAPI(t *testing.T) {
+	account := RandomAccount()
+	account.Currency = utils.USD
+	account.Balance = -5000
+	account.OverdraftLimit = 10000
+
+	testCases := []struct {
+		name          string
+		limit         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			limit: "60.00",
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := account
+				updated.OverdraftLimit = 6000
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().UpdateAccountOverdraftLimit(gomock.Any(), gomock.Eq(db.UpdateAccountOverdraftLimitParams{
+					ID:             account.ID,
+					OverdraftLimit: 6000,
+				})).Times(1).Return(updated, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:  "BelowDebt",
+			limit: "49.99",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().UpdateAccountOverdraftLimit(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"field":"overdraft_limit"`)
+				require.NotContains(t, recorder.Body.String(), codeInsufficientFunds)
+			},
+		},
+		{
+			name:  "DebtGrewMeanwhile",
+			limit: "50.00",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().UpdateAccountOverdraftLimit(gomock.Any(), gomock.Any()).Times(1).
+					Return(db.Account{}, &pq.Error{Code: db.CheckViolation, Constraint: "accounts_balance_check"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"field":"overdraft_limit"`)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"overdraft_limit": tc.limit})
+			require.NoError(t, err)
+			url := fmt.Sprintf("/admin/accounts/%d/overdraft_limit", account.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "admin", utils.AdminRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
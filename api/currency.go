@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+func newCurrency(currency db.Currency) utils.Currency {
+	return utils.Currency{
+		Code:        currency.Code,
+		NumericCode: int(currency.NumericCode),
+		MinorUnits:  int(currency.MinorUnits),
+		Symbol:      currency.Symbol,
+		Enabled:     currency.Enabled,
+	}
+}
+
+// LoadCurrencies fills utils.Currencies from CURRENCIES_FILE when it is set,
+// otherwise from the currencies table. The built-in defaults are kept when
+// the table is empty.
+func LoadCurrencies(ctx context.Context, config utils.Config, store db.Store) error {
+	var currencies []utils.Currency
+
+	if config.CurrenciesFile != "" {
+		loaded, err := utils.LoadCurrencies(config.CurrenciesFile)
+		if err != nil {
+			return err
+		}
+		currencies = loaded
+	} else {
+		rows, err := store.ListCurrencies(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot list currencies: %w", err)
+		}
+		for _, row := range rows {
+			currencies = append(currencies, newCurrency(row))
+		}
+	}
+
+	if len(currencies) == 0 {
+		return nil
+	}
+	return utils.Currencies.Replace(currencies)
+}
+
+// ListCurrencies returns the currencies that can be used for accounts and transfers
+func (server *Server) ListCurrencies(ctx *gin.Context) {
+	currencies := []utils.Currency{}
+	for _, currency := range utils.Currencies.List() {
+		if currency.Enabled {
+			currencies = append(currencies, currency)
+		}
+	}
+	ctx.JSON(http.StatusOK, currencies)
+}
+
+// ListAllCurrencies includes disabled currencies, admin only
+func (server *Server) ListAllCurrencies(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, utils.Currencies.List())
+}
+
+type CurrencyCodeRequest struct {
+	Code string `uri:"code" binding:"required,len=3"`
+}
+
+type UpdateCurrencyEnabledRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// UpdateCurrencyEnabled turns a currency on or off at runtime, admin only.
+// Existing accounts keep working, only new accounts are refused in a disabled
+// currency. The change applies to this instance right away, other instances
+// read the currencies table again when they restart.
+func (server *Server) UpdateCurrencyEnabled(ctx *gin.Context) {
+	var uri CurrencyCodeRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	var request UpdateCurrencyEnabledRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	row, err := server.store.UpdateCurrencyEnabled(ctx, db.UpdateCurrencyEnabledParams{
+		Code:    uri.Code,
+		Enabled: *request.Enabled,
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	currency := newCurrency(row)
+	if err := utils.Currencies.Register(currency); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, currency)
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListCurrenciesAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/currencies", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var currencies []utils.Currency
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &currencies))
+	require.NotEmpty(t, currencies)
+	for _, currency := range currencies {
+		require.True(t, currency.Enabled)
+		require.NotEqual(t, utils.JPY, currency.Code)
+	}
+}
+
+func TestUpdateCurrencyEnabledAPI(t *testing.T) {
+	// the registry is global, put it back for the other tests
+	saved := utils.Currencies.List()
+	t.Cleanup(func() {
+		require.NoError(t, utils.Currencies.Replace(saved))
+	})
+
+	jpy := db.Currency{
+		Code:        utils.JPY,
+		NumericCode: 392,
+		MinorUnits:  0,
+		Symbol:      "¥",
+		Enabled:     true,
+	}
+
+	testCases := []struct {
+		name          string
+		code          string
+		role          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			code: utils.JPY,
+			role: utils.AdminRole,
+			body: gin.H{"enabled": true},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.UpdateCurrencyEnabledParams{Code: utils.JPY, Enabled: true}
+				store.EXPECT().UpdateCurrencyEnabled(gomock.Any(), gomock.Eq(arg)).Times(1).Return(jpy, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.True(t, utils.IsSupportedCurrency(utils.JPY))
+			},
+		},
+		{
+			name: "NotFound",
+			code: "XXX",
+			role: utils.AdminRole,
+			body: gin.H{"enabled": true},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateCurrencyEnabled(gomock.Any(), gomock.Any()).Times(1).Return(db.Currency{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "MissingEnabled",
+			code: utils.JPY,
+			role: utils.AdminRole,
+			body: gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateCurrencyEnabled(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Depositor",
+			code: utils.JPY,
+			role: utils.DepositorRole,
+			body: gin.H{"enabled": true},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateCurrencyEnabled(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/admin/currencies/%s/enabled", tc.code)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, utils.RandomOwner(), tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/fx"
+	"github.com/minhdang2803/simple_bank/token"
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+// Error codes returned in the "code" field of ErrorResponse
+const (
+	codeInvalidRequest      = "invalid_request"
+	codeUnauthorized        = "unauthorized"
+	codeForbidden           = "forbidden"
+	codeNotFound            = "not_found"
+	codeAlreadyExists       = "already_exists"
+	codeInvalidReference    = "invalid_reference"
+	codeConflict            = "conflict"
+	codeInsufficientFunds   = "insufficient_funds"
+	codeUnprocessable       = "unprocessable"
+	codeInternalServerError = "internal_error"
+)
+
+// apiErrorKey holds the *apiError a handler aborted with, for the deferred
+// steps that record the outcome of the request
+const apiErrorKey = "api_error"
+
+// ErrorResponse is the JSON envelope of every error returned by the API
+type ErrorResponse struct {
+	Code      string        `json:"code"`
+	Message   string        `json:"message"`
+	Details   []ErrorDetail `json:"details,omitempty"`
+	RequestID string        `json:"request_id"`
+}
+
+// ErrorDetail describes a single invalid field of the request
+type ErrorDetail struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// apiError is an error raised by a handler that already knows its status and code
+type apiError struct {
+	status  int
+	code    string
+	message string
+	details []ErrorDetail
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func newAPIError(status int, code string, message string) *apiError {
+	return &apiError{status: status, code: code, message: message}
+}
+
+func errForbidden(message string) *apiError {
+	return newAPIError(http.StatusForbidden, codeForbidden, message)
+}
+
+func errUnauthorized(message string) *apiError {
+	return newAPIError(http.StatusUnauthorized, codeUnauthorized, message)
+}
+
+func errBadRequest(message string) *apiError {
+	return newAPIError(http.StatusBadRequest, codeInvalidRequest, message)
+}
+
+// errInvalidRequest turns a binding error into a 400, validation errors are
+// rendered field by field
+func errInvalidRequest(err error) *apiError {
+	apiErr := errBadRequest("invalid request")
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		apiErr.message = fmt.Sprintf("invalid request: %s", err.Error())
+		return apiErr
+	}
+
+	for _, fieldErr := range validationErrors {
+		apiErr.details = append(apiErr.details, ErrorDetail{
+			Field:   fieldErr.Field(),
+			Message: validationMessage(fieldErr),
+		})
+	}
+	return apiErr
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %s", fieldErr.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fieldErr.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fieldErr.Param())
+	case "email":
+		return "must be a valid email"
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "uuid":
+		return "must be a valid uuid"
+	case "len":
+		return fmt.Sprintf("must be exactly %s characters long", fieldErr.Param())
+	case "currency":
+		return "is not a supported currency"
+	case "enabled_currency":
+		return "is not a currency new accounts can be opened in"
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s]", fieldErr.Param())
+	}
+	return fmt.Sprintf("failed on the %s rule", fieldErr.Tag())
+}
+
+// toAPIError maps any error to the status and code the client should see.
+// Errors that aren't part of the taxonomy become a 500 without leaking their message.
+func toAPIError(err error) *apiError {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	err = db.TranslateError(err)
+	switch {
+	case errors.Is(err, db.ErrRecordNotFound):
+		return newAPIError(http.StatusNotFound, codeNotFound, "record not found")
+	case errors.Is(err, db.ErrUniqueViolation):
+		return newAPIError(http.StatusConflict, codeAlreadyExists, "record already exists")
+	case errors.Is(err, db.ErrForeignKeyViolation):
+		return newAPIError(http.StatusUnprocessableEntity, codeInvalidReference, "referenced record does not exist")
+	case errors.Is(err, db.ErrInsufficientFunds):
+		return newAPIError(http.StatusUnprocessableEntity, codeInsufficientFunds, "insufficient funds")
+	case errors.Is(err, db.ErrHoldNotActive):
+		return newAPIError(http.StatusConflict, codeConflict, err.Error())
+	case errors.Is(err, db.ErrReversalExceedsTransfer), errors.Is(err, db.ErrReversalOfReversal),
+		errors.Is(err, db.ErrCaptureExceedsHold), errors.Is(err, db.ErrAccountNotEmpty),
+		errors.Is(err, db.ErrAccountFrozen), errors.Is(err, db.ErrAccountClosed):
+		return newAPIError(http.StatusUnprocessableEntity, codeUnprocessable, err.Error())
+	case errors.Is(err, db.ErrCheckViolation):
+		return newAPIError(http.StatusUnprocessableEntity, codeUnprocessable, "request violates a business rule")
+	case errors.Is(err, utils.ErrInvalidAmount):
+		return errBadRequest(err.Error())
+	case errors.Is(err, utils.ErrUnknownCurrency), errors.Is(err, fx.ErrUnsupportedPair),
+		errors.Is(err, fx.ErrAmountOverflow):
+		return newAPIError(http.StatusUnprocessableEntity, codeUnprocessable, err.Error())
+	case errors.Is(err, token.ErrExpiredToken), errors.Is(err, token.ErrInvalidToken):
+		return errUnauthorized(err.Error())
+	}
+
+	log.Printf("internal error: %v", err)
+	return newAPIError(http.StatusInternalServerError, codeInternalServerError, "internal server error")
+}
+
+// abortWithError renders err with the standard error envelope and stops the handler chain
+func abortWithError(ctx *gin.Context, err error) {
+	apiErr := toAPIError(err)
+	ctx.Set(apiErrorKey, apiErr)
+	ctx.AbortWithStatusJSON(apiErr.status, ErrorResponse{
+		Code:      apiErr.code,
+		Message:   apiErr.message,
+		Details:   apiErr.details,
+		RequestID: ctx.GetString(requestIDKey),
+	})
+}
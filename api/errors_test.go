@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	user, password := RandomUser(t)
+	account := RandomAccount()
+
+	testCases := []struct {
+		name          string
+		method        string
+		url           string
+		body          gin.H
+		authUsername  string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, response ErrorResponse)
+	}{
+		{
+			name:         "ValidationErrorsByField",
+			method:       http.MethodPost,
+			url:          "/transfers",
+			body:         gin.H{"from_account_id": 1, "to_account_id": 2, "currency": "XYZ"},
+			authUsername: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, response ErrorResponse) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Equal(t, codeInvalidRequest, response.Code)
+				require.ElementsMatch(t, []ErrorDetail{
+					{Field: "amount", Message: "is required"},
+					{Field: "currency", Message: "is not a supported currency"},
+				}, response.Details)
+			},
+		},
+		{
+			name:         "MissingUserIsNotFound",
+			method:       http.MethodGet,
+			url:          "/users?username=" + user.Username,
+			authUsername: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.User{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, response ErrorResponse) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.Equal(t, codeNotFound, response.Code)
+			},
+		},
+		{
+			name:   "DuplicateUserIsConflict",
+			method: http.MethodPost,
+			url:    "/users",
+			body: gin.H{
+				"username":  user.Username,
+				"password":  password,
+				"full_name": user.FullName,
+				"email":     user.Email,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, &pq.Error{Code: db.UniqueViolation})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, response ErrorResponse) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Equal(t, codeAlreadyExists, response.Code)
+			},
+		},
+		{
+			name:         "InternalErrorDoesNotLeak",
+			method:       http.MethodGet,
+			url:          "/accounts/1",
+			authUsername: account.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, errors.New("pq: secret connection details"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, response ErrorResponse) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				require.Equal(t, codeInternalServerError, response.Code)
+				require.NotContains(t, recorder.Body.String(), "secret")
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			var body []byte
+			if tc.body != nil {
+				var err error
+				body, err = json.Marshal(tc.body)
+				require.NoError(t, err)
+			}
+
+			request, err := http.NewRequest(tc.method, tc.url, bytes.NewReader(body))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set(requestIDHeaderKey, "test-request-id")
+			if len(tc.authUsername) > 0 {
+				addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.authUsername, utils.DepositorRole, time.Minute)
+			}
+
+			server.router.ServeHTTP(recorder, request)
+
+			var response ErrorResponse
+			err = json.Unmarshal(recorder.Body.Bytes(), &response)
+			require.NoError(t, err)
+			require.Equal(t, "test-request-id", response.RequestID)
+			require.Equal(t, "test-request-id", recorder.Header().Get(requestIDHeaderKey))
+			tc.checkResponse(t, recorder, response)
+		})
+	}
+}
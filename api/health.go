@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minhdang2803/simple_bank/db/migration"
+)
+
+// SchemaStatus reports the schema version of the database behind the store
+type SchemaStatus func(ctx context.Context) (migration.Status, error)
+
+// ReadinessCheck reports whether a dependency of the server works, nil when
+// it does
+type ReadinessCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+// SetSchemaStatus makes the readiness endpoint report the schema version
+func (server *Server) SetSchemaStatus(status SchemaStatus) {
+	server.schemaStatus = status
+}
+
+// AddReadinessCheck makes the readiness endpoint run check under name, the
+// server isn't ready while it fails
+func (server *Server) AddReadinessCheck(name string, check ReadinessCheck) {
+	server.readinessChecks = append(server.readinessChecks, namedCheck{name: name, check: check})
+}
+
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+	healthStopping    = "stopping"
+	// readinessTimeout bounds the checks of a single readiness request
+	readinessTimeout = 2 * time.Second
+)
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+	Schema *migration.Status `json:"schema,omitempty"`
+}
+
+// Liveness reports that the process serves requests, it doesn't look at any
+// dependency so that an outage of the database doesn't get it restarted
+func (server *Server) Liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, healthResponse{Status: healthOK})
+}
+
+// Readiness reports whether the server should get traffic: every check
+// passes, the schema has the migrations of the binary and the server isn't
+// shutting down. The endpoint is public, why a check failed only goes to the log.
+func (server *Server) Readiness(ctx *gin.Context) {
+	if server.stopping.Load() {
+		ctx.JSON(http.StatusServiceUnavailable, healthResponse{Status: healthStopping})
+		return
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	rsp := healthResponse{Status: healthOK, Checks: map[string]string{}}
+	for _, check := range server.readinessChecks {
+		if err := check.check(checkCtx); err != nil {
+			log.Printf("readiness check %s failed: %v", check.name, err)
+			rsp.Checks[check.name] = healthUnavailable
+			rsp.Status = healthUnavailable
+			continue
+		}
+		rsp.Checks[check.name] = healthOK
+	}
+
+	if server.schemaStatus != nil {
+		status, err := server.schemaStatus(checkCtx)
+		switch {
+		case err != nil:
+			log.Printf("cannot read schema version: %v", err)
+			rsp.Checks["schema"] = healthUnavailable
+			rsp.Status = healthUnavailable
+		case !status.Current():
+			rsp.Schema = &status
+			rsp.Checks["schema"] = "migrations pending or dirty"
+			rsp.Status = healthUnavailable
+		default:
+			rsp.Schema = &status
+			rsp.Checks["schema"] = healthOK
+		}
+	}
+
+	if rsp.Status != healthOK {
+		ctx.JSON(http.StatusServiceUnavailable, rsp)
+		return
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
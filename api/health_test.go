@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/minhdang2803/simple_bank/db/migration"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLivenessAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+	server.AddReadinessCheck("database", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/healthz", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, healthOK, decodeHealth(t, recorder).Status)
+}
+
+func TestReadinessAPI(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	current := func(ctx context.Context) (migration.Status, error) {
+		return migration.Status{Version: 16, Latest: 16}, nil
+	}
+
+	testCases := []struct {
+		name          string
+		checks        map[string]ReadinessCheck
+		schemaStatus  SchemaStatus
+		stopping      bool
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:         "OK",
+			checks:       map[string]ReadinessCheck{"database": ok, "scheduler": ok},
+			schemaStatus: current,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				rsp := decodeHealth(t, recorder)
+				require.Equal(t, healthOK, rsp.Status)
+				require.Equal(t, map[string]string{"database": healthOK, "scheduler": healthOK, "schema": healthOK}, rsp.Checks)
+				require.Equal(t, &migration.Status{Version: 16, Latest: 16}, rsp.Schema)
+			},
+		},
+		{
+			name: "NoChecks",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				rsp := decodeHealth(t, recorder)
+				require.Equal(t, healthOK, rsp.Status)
+				require.Nil(t, rsp.Schema)
+			},
+		},
+		{
+			name: "CheckFails",
+			checks: map[string]ReadinessCheck{
+				"database":  ok,
+				"scheduler": func(ctx context.Context) error { return errors.New("executor is not running") },
+			},
+			schemaStatus: current,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				rsp := decodeHealth(t, recorder)
+				require.Equal(t, healthUnavailable, rsp.Status)
+				require.Equal(t, healthOK, rsp.Checks["database"])
+				require.Equal(t, healthUnavailable, rsp.Checks["scheduler"])
+				require.NotContains(t, recorder.Body.String(), "executor is not running")
+			},
+		},
+		{
+			name: "MigrationsPending",
+			schemaStatus: func(ctx context.Context) (migration.Status, error) {
+				return migration.Status{Version: 15, Latest: 16}, nil
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				rsp := decodeHealth(t, recorder)
+				require.Equal(t, healthUnavailable, rsp.Status)
+				require.Equal(t, int64(15), rsp.Schema.Version)
+			},
+		},
+		{
+			name: "Dirty",
+			schemaStatus: func(ctx context.Context) (migration.Status, error) {
+				return migration.Status{Version: 16, Dirty: true, Latest: 16}, nil
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				require.True(t, decodeHealth(t, recorder).Schema.Dirty)
+			},
+		},
+		{
+			name: "SchemaUnreadable",
+			schemaStatus: func(ctx context.Context) (migration.Status, error) {
+				return migration.Status{}, errors.New("connection refused")
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				rsp := decodeHealth(t, recorder)
+				require.Equal(t, healthUnavailable, rsp.Status)
+				require.Equal(t, healthUnavailable, rsp.Checks["schema"])
+				require.NotContains(t, recorder.Body.String(), "connection refused")
+			},
+		},
+		{
+			name:         "Stopping",
+			checks:       map[string]ReadinessCheck{"database": ok},
+			schemaStatus: current,
+			stopping:     true,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				require.Equal(t, healthStopping, decodeHealth(t, recorder).Status)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := newTestServer(t, mockdb.NewMockStore(ctrl))
+			for name, check := range tc.checks {
+				server.AddReadinessCheck(name, check)
+			}
+			server.SetSchemaStatus(tc.schemaStatus)
+			server.stopping.Store(tc.stopping)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+
+	// a slow request is in flight when the shutdown starts
+	started := make(chan struct{})
+	server.router.GET("/slow", func(ctx *gin.Context) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		ctx.Status(http.StatusNoContent)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	// no idle connection is left for Shutdown to close
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	url := fmt.Sprintf("http://%s", listener.Addr())
+	rsp, err := client.Get(url + "/healthz")
+	require.NoError(t, err)
+	rsp.Body.Close()
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+
+	slow := make(chan int, 1)
+	go func() {
+		rsp, err := client.Get(url + "/slow")
+		if err != nil {
+			slow <- 0
+			return
+		}
+		rsp.Body.Close()
+		slow <- rsp.StatusCode
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, server.Shutdown(ctx))
+	require.NoError(t, <-served)
+	require.Equal(t, http.StatusNoContent, <-slow)
+
+	_, err = client.Get(url + "/healthz")
+	require.Error(t, err)
+}
+
+func decodeHealth(t *testing.T, recorder *httptest.ResponseRecorder) healthResponse {
+	var rsp healthResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	return rsp
+}
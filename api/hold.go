@@ -0,0 +1,375 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/token"
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+const (
+	// defaultHoldDuration is how long a hold reserves funds when the client
+	// doesn't say, maxHoldDuration is the longest it may ask for
+	defaultHoldDuration = 7 * 24 * time.Hour
+	maxHoldDuration     = 30 * 24 * time.Hour
+)
+
+// CreateHoldRequest reserves Amount on AccountID until it is captured by the
+// owner of ToAccountID, voided or expires
+type CreateHoldRequest struct {
+	AccountID   int64 `json:"account_id" binding:"required,min=1"`
+	ToAccountID int64 `json:"to_account_id" binding:"required,min=1"`
+	// Amount is a decimal string in Currency such as "12.50"
+	Amount    string     `json:"amount" binding:"required"`
+	Currency  string     `json:"currency" binding:"required,currency"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type HoldResponse struct {
+	ID             int64        `json:"id"`
+	AccountID      int64        `json:"account_id"`
+	ToAccountID    int64        `json:"to_account_id"`
+	Amount         utils.Money  `json:"amount"`
+	Currency       string       `json:"currency"`
+	Status         string       `json:"status"`
+	CapturedAmount *utils.Money `json:"captured_amount,omitempty"`
+	TransferID     *int64       `json:"transfer_id,omitempty"`
+	ExpiresAt      time.Time    `json:"expires_at"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+func newHoldResponse(hold db.Hold) HoldResponse {
+	response := HoldResponse{
+		ID:          hold.ID,
+		AccountID:   hold.AccountID,
+		ToAccountID: hold.ToAccountID,
+		Amount:      utils.NewMoney(hold.Amount, hold.Currency),
+		Currency:    hold.Currency,
+		Status:      db.HoldStatus(hold),
+		TransferID:  nullInt64(hold.TransferID),
+		ExpiresAt:   hold.ExpiresAt,
+		CreatedAt:   hold.CreatedAt,
+	}
+	if hold.Status == db.HoldCaptured {
+		captured := utils.NewMoney(hold.CapturedAmount, hold.Currency)
+		response.CapturedAmount = &captured
+	}
+	return response
+}
+
+// replayHold renders the hold stored with an idempotency key
+func replayHold(body []byte) (interface{}, error) {
+	var hold db.Hold
+	if err := json.Unmarshal(body, &hold); err != nil {
+		return nil, err
+	}
+	return newHoldResponse(hold), nil
+}
+
+type CaptureHoldResponse struct {
+	Hold     HoldResponse       `json:"hold"`
+	Transfer TransferTxResponse `json:"transfer"`
+}
+
+func newCaptureHoldResponse(result db.CaptureHoldResult) CaptureHoldResponse {
+	return CaptureHoldResponse{
+		Hold:     newHoldResponse(result.Hold),
+		Transfer: newTransferTxResponse(result.TransferTxResult),
+	}
+}
+
+// replayCapture renders the capture stored with an idempotency key
+func replayCapture(body []byte) (interface{}, error) {
+	var result db.CaptureHoldResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return newCaptureHoldResponse(result), nil
+}
+
+func (server *Server) CreateHold(ctx *gin.Context) {
+	var request CreateHoldRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	expiresAt := time.Now().Add(defaultHoldDuration)
+	if request.ExpiresAt != nil {
+		expiresAt = *request.ExpiresAt
+		if !expiresAt.After(time.Now()) {
+			abortWithError(ctx, errBadRequest("expires_at must be in the future"))
+			return
+		}
+		if expiresAt.After(time.Now().Add(maxHoldDuration)) {
+			abortWithError(ctx, errBadRequest("a hold can't last more than 30 days"))
+			return
+		}
+	}
+	if request.AccountID == request.ToAccountID {
+		abortWithError(ctx, errBadRequest("cannot hold funds for the same account"))
+		return
+	}
+
+	amount, err := utils.ParseMoney(request.Amount, request.Currency)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	if amount.Amount <= 0 {
+		abortWithError(ctx, errBadRequest("amount must be positive"))
+		return
+	}
+
+	idempotency, proceed := server.checkIdempotency(ctx, request, replayHold)
+	if !proceed {
+		return
+	}
+	defer server.saveIdempotentError(ctx, idempotency)
+
+	account, toAccount, valid := server.validPayment(ctx, request.AccountID, request.ToAccountID, request.Currency)
+	if !valid {
+		return
+	}
+
+	hold, err := server.store.CreateHold(ctx, db.CreateHoldParams{
+		AccountID:   account.ID,
+		ToAccountID: toAccount.ID,
+		Amount:      amount.Amount,
+		ExpiresAt:   expiresAt,
+		Idempotency: idempotency,
+	})
+	if err != nil {
+		if isIdempotencyConflict(err) {
+			server.replayConcurrent(ctx, idempotency, replayHold)
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newHoldResponse(hold))
+}
+
+type HoldRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// GetHold returns a hold on or for one of the caller's accounts
+func (server *Server) GetHold(ctx *gin.Context) {
+	var request HoldRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	hold, err := server.store.GetHold(ctx, request.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !hasRole(authPayload, utils.BankerRole, utils.AdminRole) {
+		owns, err := server.ownsAnyAccount(ctx, authPayload.Username, hold.AccountID, hold.ToAccountID)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		if !owns {
+			abortWithError(ctx, errForbidden("hold doesn't involve an account of the authenticated user"))
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, newHoldResponse(hold))
+}
+
+// settlingHold loads the hold of the request for a capture or a void, which
+// only the recipient, bankers and admins can do. It also returns the
+// destination account of the hold.
+func (server *Server) settlingHold(ctx *gin.Context) (db.Hold, db.Account, bool) {
+	var request HoldRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return db.Hold{}, db.Account{}, false
+	}
+
+	hold, err := server.store.GetHold(ctx, request.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return hold, db.Account{}, false
+	}
+
+	toAccount, err := server.store.GetAccount(ctx, hold.ToAccountID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return hold, toAccount, false
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if toAccount.Owner != authPayload.Username && !hasRole(authPayload, utils.BankerRole, utils.AdminRole) {
+		abortWithError(ctx, errForbidden("only the recipient of a hold can settle it"))
+		return hold, toAccount, false
+	}
+	return hold, toAccount, true
+}
+
+type CaptureHoldRequest struct {
+	HoldID int64 `json:"hold_id"`
+	// Amount is a decimal string in the currency of the hold, it defaults to
+	// the whole hold
+	Amount string `json:"amount"`
+}
+
+// CaptureHold turns a hold into a transfer to its recipient, the part of the
+// hold that isn't captured is released
+func (server *Server) CaptureHold(ctx *gin.Context) {
+	var request CaptureHoldRequest
+	// the body is optional, an empty one captures the whole hold
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			abortWithError(ctx, errInvalidRequest(err))
+			return
+		}
+	}
+
+	hold, toAccount, ok := server.settlingHold(ctx)
+	if !ok {
+		return
+	}
+	// the id is part of the fingerprint of the idempotency key
+	request.HoldID = hold.ID
+
+	arg := db.CaptureHoldParams{HoldID: hold.ID, Amount: hold.Amount}
+	if request.Amount != "" {
+		amount, err := utils.ParseMoney(request.Amount, hold.Currency)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		if amount.Amount <= 0 {
+			abortWithError(ctx, errBadRequest("amount must be positive"))
+			return
+		}
+		arg.Amount = amount.Amount
+	}
+
+	// cross-currency captures are converted at the rate of the capture
+	if toAccount.Currency != hold.Currency {
+		rate, err := server.rates.Rate(ctx, hold.Currency, toAccount.Currency)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		arg.ToAmount, err = rate.Convert(arg.Amount)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		arg.ExchangeRate = rate.String()
+		if arg.ToAmount <= 0 {
+			abortWithError(ctx, errBadRequest("amount is too small to be converted"))
+			return
+		}
+	}
+
+	idempotency, proceed := server.checkIdempotency(ctx, request, replayCapture)
+	if !proceed {
+		return
+	}
+	defer server.saveIdempotentError(ctx, idempotency)
+	arg.Idempotency = idempotency
+
+	result, err := server.store.CaptureHold(ctx, arg)
+	if err != nil {
+		if isIdempotencyConflict(err) {
+			server.replayConcurrent(ctx, idempotency, replayCapture)
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newCaptureHoldResponse(result))
+}
+
+// VoidHold releases a hold without moving any money
+func (server *Server) VoidHold(ctx *gin.Context) {
+	hold, _, ok := server.settlingHold(ctx)
+	if !ok {
+		return
+	}
+
+	voided, err := server.store.VoidHold(ctx, hold.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newHoldResponse(voided))
+}
+
+// ListAccountHolds returns the holds placed on an account, newest first, see
+// PageRequest
+func (server *Server) ListAccountHolds(ctx *gin.Context) {
+	var uri GetAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	var request PageRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	page, err := server.newPageQuery(request)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	account, ok := server.readableAccount(ctx, uri.Id)
+	if !ok {
+		return
+	}
+
+	var holds []db.Hold
+	if page.Backward {
+		holds, err = server.store.ListHoldsByAccountAfter(ctx, db.ListHoldsByAccountAfterParams{
+			AccountID: account.ID,
+			AfterID:   page.ID,
+			Limit:     page.limit(),
+		})
+	} else {
+		beforeID := page.ID
+		if beforeID == 0 {
+			beforeID = math.MaxInt64
+		}
+		holds, err = server.store.ListHoldsByAccount(ctx, db.ListHoldsByAccountParams{
+			AccountID: account.ID,
+			BeforeID:  beforeID,
+			Limit:     page.limit(),
+		})
+	}
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	holds, next, prev := paginate(page, holds, func(hold db.Hold) int64 {
+		return hold.ID
+	})
+	response := Page[HoldResponse]{
+		Items:      make([]HoldResponse, 0, len(holds)),
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+	for _, hold := range holds {
+		response.Items = append(response.Items, newHoldResponse(hold))
+	}
+	ctx.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func randomHold(from db.Account, to db.Account) db.Hold {
+	return db.Hold{
+		ID:          utils.RandomInt(1, 1000),
+		AccountID:   from.ID,
+		ToAccountID: to.ID,
+		Amount:      utils.RandomInt(100, 1000),
+		Currency:    from.Currency,
+		Status:      db.HoldActive,
+		ExpiresAt:   time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+	}
+}
+
+func TestCreateHoldAPI(t *testing.T) {
+	account1 := RandomAccount()
+	account2 := RandomAccount()
+	account1.Currency = utils.USD
+	account2.Currency = utils.USD
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"account_id":    account1.ID,
+				"to_account_id": account2.ID,
+				"amount":        "25.00",
+				"currency":      utils.USD,
+			},
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().CreateHold(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateHoldParams) (db.Hold, error) {
+						require.Equal(t, account1.ID, arg.AccountID)
+						require.Equal(t, account2.ID, arg.ToAccountID)
+						require.Equal(t, int64(2500), arg.Amount)
+						require.WithinDuration(t, time.Now().Add(defaultHoldDuration), arg.ExpiresAt, time.Minute)
+						return db.Hold{
+							ID:          1,
+							AccountID:   arg.AccountID,
+							ToAccountID: arg.ToAccountID,
+							Amount:      arg.Amount,
+							Currency:    utils.USD,
+							Status:      db.HoldActive,
+							ExpiresAt:   arg.ExpiresAt,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"amount":"25.00"`)
+				require.Contains(t, recorder.Body.String(), `"status":"active"`)
+				require.NotContains(t, recorder.Body.String(), "captured_amount")
+			},
+		},
+		{
+			name: "Expiry too far",
+			body: gin.H{
+				"account_id":    account1.ID,
+				"to_account_id": account2.ID,
+				"amount":        "25.00",
+				"currency":      utils.USD,
+				"expires_at":    time.Now().Add(maxHoldDuration + time.Hour),
+			},
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateHold(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Not the owner",
+			body: gin.H{
+				"account_id":    account1.ID,
+				"to_account_id": account2.ID,
+				"amount":        "25.00",
+				"currency":      utils.USD,
+			},
+			username: account2.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().CreateHold(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "Insufficient funds",
+			body: gin.H{
+				"account_id":    account1.ID,
+				"to_account_id": account2.ID,
+				"amount":        "25.00",
+				"currency":      utils.USD,
+			},
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().CreateHold(gomock.Any(), gomock.Any()).Times(1).Return(db.Hold{}, db.ErrInsufficientFunds)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/holds", bytes.NewReader(data))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, utils.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestSettleHoldAPI(t *testing.T) {
+	account1 := RandomAccount()
+	account2 := RandomAccount()
+	account1.Currency = utils.USD
+	account2.Currency = utils.USD
+	hold := randomHold(account1, account2)
+
+	captured := func(amount int64) db.CaptureHoldResult {
+		closed := hold
+		closed.Status = db.HoldCaptured
+		closed.CapturedAmount = amount
+		closed.TransferID = sql.NullInt64{Int64: 9, Valid: true}
+		return db.CaptureHoldResult{
+			Hold: closed,
+			TransferTxResult: db.TransferTxResult{
+				Transfer: db.Transfer{ID: 9, FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: amount},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		action        string
+		body          gin.H
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Capture the whole hold",
+			action:   "capture",
+			username: account2.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(hold, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				arg := db.CaptureHoldParams{HoldID: hold.ID, Amount: hold.Amount}
+				store.EXPECT().CaptureHold(gomock.Any(), gomock.Eq(arg)).Times(1).Return(captured(hold.Amount), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"captured"`)
+				require.Contains(t, recorder.Body.String(), `"transfer_id":9`)
+			},
+		},
+		{
+			name:     "Capture part of the hold",
+			action:   "capture",
+			body:     gin.H{"amount": "0.50"},
+			username: account2.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(hold, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				arg := db.CaptureHoldParams{HoldID: hold.ID, Amount: 50}
+				store.EXPECT().CaptureHold(gomock.Any(), gomock.Eq(arg)).Times(1).Return(captured(50), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"captured_amount":"0.50"`)
+			},
+		},
+		{
+			name:     "Capture by the payer",
+			action:   "capture",
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(hold, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().CaptureHold(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "Capture more than the hold",
+			action:   "capture",
+			body:     gin.H{"amount": "100000.00"},
+			username: account2.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(hold, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().CaptureHold(gomock.Any(), gomock.Any()).Times(1).
+					Return(db.CaptureHoldResult{}, db.ErrCaptureExceedsHold)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name:     "Capture a closed hold",
+			action:   "capture",
+			username: account2.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(hold, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().CaptureHold(gomock.Any(), gomock.Any()).Times(1).
+					Return(db.CaptureHoldResult{}, db.ErrHoldNotActive)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name:     "Void",
+			action:   "void",
+			username: account2.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				voided := hold
+				voided.Status = db.HoldVoided
+				store.EXPECT().GetHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(hold, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().VoidHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(voided, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"voided"`)
+			},
+		},
+		{
+			name:     "Void twice",
+			action:   "void",
+			username: account2.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(hold, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().VoidHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(hold, db.ErrHoldNotActive)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/holds/%d/%s", hold.ID, tc.action)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+			if tc.body != nil {
+				data, err := json.Marshal(tc.body)
+				require.NoError(t, err)
+				request, err = http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+				require.NoError(t, err)
+				request.Header.Set("Content-Type", "application/json")
+			}
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, utils.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListAccountHoldsAPI(t *testing.T) {
+	account1 := RandomAccount()
+	account2 := RandomAccount()
+	expired := randomHold(account1, account2)
+	expired.ID = 1
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	holds := []db.Hold{randomHold(account1, account2), expired}
+	holds[0].ID = 2
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+	store.EXPECT().ListHoldsByAccount(gomock.Any(), gomock.Eq(db.ListHoldsByAccountParams{
+		AccountID: account1.ID,
+		BeforeID:  math.MaxInt64,
+		Limit:     defaultPageSize + 1,
+	})).Times(1).Return(holds, nil)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	url := fmt.Sprintf("/accounts/%d/holds", account1.ID)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account1.Owner, utils.DepositorRole, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var page struct {
+		Items []struct {
+			ID     int64  `json:"id"`
+			Status string `json:"status"`
+		} `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &page))
+	require.Len(t, page.Items, 2)
+	require.Equal(t, db.HoldActive, page.Items[0].Status)
+	require.Equal(t, db.HoldExpired, page.Items[1].Status)
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/token"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	idempotencyKeyMaxLength = 255
+)
+
+// errIdempotencyConflict is sent while another request with the same key is
+// still in flight and its response can't be replayed yet. The client retries
+// the request later with the same key and gets the original response.
+var errIdempotencyConflict = newAPIError(http.StatusConflict, codeConflict, "a request with the same idempotency key is still being processed, retry it later")
+
+// checkIdempotency looks up the Idempotency-Key header of the request.
+// It returns the params to store along with the response, or nil when the
+// client didn't send a key. When the key was already used, the original
+// response is replayed, errors included (or a 422 is sent for a different
+// body), and the handler must stop. replay renders the stored body the same
+// way the handler renders a fresh result.
+func (server *Server) checkIdempotency(ctx *gin.Context, request interface{}, replay func(body []byte) (interface{}, error)) (*db.IdempotencyParams, bool) {
+	key := ctx.GetHeader(idempotencyKeyHeader)
+	if len(key) == 0 {
+		return nil, true
+	}
+	if len(key) > idempotencyKeyMaxLength {
+		abortWithError(ctx, errBadRequest("idempotency key is too long"))
+		return nil, false
+	}
+	if strings.HasPrefix(key, db.SystemIdempotencyKeyPrefix) {
+		// those keys belong to the transfers the scheduler books for the user
+		abortWithError(ctx, errBadRequest(fmt.Sprintf("idempotency key can't start with %q", db.SystemIdempotencyKeyPrefix)))
+		return nil, false
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		abortWithError(ctx, err)
+		return nil, false
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	params := &db.IdempotencyParams{
+		Username:    authPayload.Username,
+		Key:         key,
+		RequestPath: ctx.FullPath(),
+		RequestHash: fingerprint(ctx.Request.Method, ctx.FullPath(), body),
+	}
+
+	saved, err := server.store.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		Username: params.Username,
+		Key:      params.Key,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return params, true
+		}
+		abortWithError(ctx, err)
+		return nil, false
+	}
+
+	replaySaved(ctx, params, saved, replay)
+	return nil, false
+}
+
+// replaySaved renders the response saved under the idempotency key
+func replaySaved(ctx *gin.Context, params *db.IdempotencyParams, saved db.IdempotencyKey, replay func(body []byte) (interface{}, error)) {
+	if saved.RequestPath != params.RequestPath || saved.RequestHash != params.RequestHash {
+		abortWithError(ctx, newAPIError(http.StatusUnprocessableEntity, codeUnprocessable, "idempotency key was already used with a different request"))
+		return
+	}
+
+	if saved.ResponseCode >= http.StatusBadRequest {
+		var rsp ErrorResponse
+		if err := json.Unmarshal(saved.ResponseBody, &rsp); err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		rsp.RequestID = ctx.GetString(requestIDKey)
+		ctx.AbortWithStatusJSON(int(saved.ResponseCode), rsp)
+		return
+	}
+
+	response, err := replay(saved.ResponseBody)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(int(saved.ResponseCode), response)
+}
+
+// replayConcurrent answers a request that lost the race for its idempotency
+// key with the response of the request that won. The insert of the key waits
+// for the winning transaction, so its response is normally there by now.
+func (server *Server) replayConcurrent(ctx *gin.Context, params *db.IdempotencyParams, replay func(body []byte) (interface{}, error)) {
+	saved, err := server.store.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		Username: params.Username,
+		Key:      params.Key,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			abortWithError(ctx, errIdempotencyConflict)
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+	replaySaved(ctx, params, saved, replay)
+}
+
+// saveIdempotentError saves the error a request with an idempotency key ended
+// with, so that a retry gets the same error instead of running the request
+// again: a transfer refused for insufficient funds mustn't go through later
+// because the account got funded in between. Server errors aren't saved, the
+// retry runs the request again. Handlers defer it right after checkIdempotency.
+func (server *Server) saveIdempotentError(ctx *gin.Context, params *db.IdempotencyParams) {
+	if params == nil {
+		return
+	}
+	value, ok := ctx.Get(apiErrorKey)
+	if !ok {
+		return
+	}
+	apiErr := value.(*apiError)
+	if apiErr.status >= http.StatusInternalServerError || apiErr == errIdempotencyConflict {
+		return
+	}
+
+	body, err := json.Marshal(ErrorResponse{
+		Code:    apiErr.code,
+		Message: apiErr.message,
+		Details: apiErr.details,
+	})
+	if err != nil {
+		log.Printf("cannot save idempotent error: %v", err)
+		return
+	}
+
+	_, err = server.store.CreateIdempotencyKey(ctx, db.CreateIdempotencyKeyParams{
+		Username:     params.Username,
+		Key:          params.Key,
+		RequestPath:  params.RequestPath,
+		RequestHash:  params.RequestHash,
+		ResponseCode: int32(apiErr.status),
+		ResponseBody: body,
+	})
+	if err != nil && !isIdempotencyConflict(err) {
+		log.Printf("cannot save idempotent error: %v", err)
+	}
+}
+
+// isIdempotencyConflict reports whether a concurrent request with the same
+// idempotency key committed first
+func isIdempotencyConflict(err error) bool {
+	return db.IsIdempotencyConflict(err)
+}
+
+func fingerprint(method string, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte(path))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
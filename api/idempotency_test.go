@@ -0,0 +1,311 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTransferIdempotency(t *testing.T) {
+	amount := utils.NewMoney(1000, utils.USD)
+	key := utils.RandomString(16)
+
+	account1 := RandomAccount()
+	account2 := RandomAccount()
+	account1.Currency = utils.USD
+	account2.Currency = utils.USD
+
+	request := TransferRequest{
+		FromAcountID: account1.ID,
+		ToAcountID:   account2.ID,
+		Amount:       amount.String(),
+		Currency:     utils.USD,
+	}
+	body, err := json.Marshal(request)
+	require.NoError(t, err)
+	requestHash := fingerprint(http.MethodPost, "/transfers", body)
+
+	savedResult := db.TransferTxResult{
+		Transfer: db.Transfer{
+			ID:            utils.RandomInt(1, 1000),
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount.Amount,
+			FromCurrency:  utils.USD,
+			ToAmount:      amount.Amount,
+			ToCurrency:    utils.USD,
+			ExchangeRate:  "1",
+		},
+	}
+	savedBody, err := json.Marshal(savedResult)
+	require.NoError(t, err)
+	responseBody, err := json.Marshal(newTransferTxResponse(savedResult))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "FirstRequest",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount.String(),
+				"currency":        utils.USD,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Eq(db.GetIdempotencyKeyParams{
+					Username: account1.Owner,
+					Key:      key,
+				})).Times(1).Return(db.IdempotencyKey{}, sql.ErrNoRows)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+
+				arg := db.TransferTxParams{
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        amount.Amount,
+					Idempotency: &db.IdempotencyParams{
+						Username:    account1.Owner,
+						Key:         key,
+						RequestPath: "/transfers",
+						RequestHash: requestHash,
+					},
+				}
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(savedResult, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, string(responseBody), recorder.Body.String())
+			},
+		},
+		{
+			name: "Replay",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount.String(),
+				"currency":        utils.USD,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Any()).Times(1).Return(db.IdempotencyKey{
+					Username:     account1.Owner,
+					Key:          key,
+					RequestPath:  "/transfers",
+					RequestHash:  requestHash,
+					ResponseCode: http.StatusOK,
+					ResponseBody: savedBody,
+				}, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, string(responseBody), recorder.Body.String())
+			},
+		},
+		{
+			name: "MismatchedBody",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          "10.01",
+				"currency":        utils.USD,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Any()).Times(1).Return(db.IdempotencyKey{
+					Username:     account1.Owner,
+					Key:          key,
+					RequestPath:  "/transfers",
+					RequestHash:  requestHash,
+					ResponseCode: http.StatusOK,
+					ResponseBody: savedBody,
+				}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name: "ConcurrentRequest",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount.String(),
+				"currency":        utils.USD,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Any()).Times(1).Return(db.IdempotencyKey{}, sql.ErrNoRows)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, &pq.Error{
+					Code:       "23505",
+					Constraint: "idempotency_keys_pkey",
+				})
+				store.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Any()).Times(1).Return(db.IdempotencyKey{
+					Username:     account1.Owner,
+					Key:          key,
+					RequestPath:  "/transfers",
+					RequestHash:  requestHash,
+					ResponseCode: http.StatusOK,
+					ResponseBody: savedBody,
+				}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, string(responseBody), recorder.Body.String())
+			},
+		},
+		{
+			name: "ConcurrentRequestInFlight",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount.String(),
+				"currency":        utils.USD,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Any()).Times(2).Return(db.IdempotencyKey{}, sql.ErrNoRows)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, &pq.Error{
+					Code:       "23505",
+					Constraint: "idempotency_keys_pkey",
+				})
+				store.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "InsufficientFunds",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount.String(),
+				"currency":        utils.USD,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Any()).Times(1).Return(db.IdempotencyKey{}, sql.ErrNoRows)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+
+				arg := db.CreateIdempotencyKeyParams{
+					Username:     account1.Owner,
+					Key:          key,
+					RequestPath:  "/transfers",
+					RequestHash:  requestHash,
+					ResponseCode: http.StatusUnprocessableEntity,
+					ResponseBody: json.RawMessage(`{"code":"insufficient_funds","message":"insufficient funds","request_id":""}`),
+				}
+				store.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Eq(arg)).Times(1).Return(db.IdempotencyKey{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name: "ReplayError",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          amount.String(),
+				"currency":        utils.USD,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Any()).Times(1).Return(db.IdempotencyKey{
+					Username:     account1.Owner,
+					Key:          key,
+					RequestPath:  "/transfers",
+					RequestHash:  requestHash,
+					ResponseCode: http.StatusUnprocessableEntity,
+					ResponseBody: json.RawMessage(`{"code":"insufficient_funds","message":"insufficient funds","request_id":""}`),
+				}, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateIdempotencyKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+
+				var rsp ErrorResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, codeInsufficientFunds, rsp.Code)
+				require.NotEmpty(t, rsp.RequestID)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set(idempotencyKeyHeader, key)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account1.Owner, utils.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestReservedIdempotencyKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Any()).Times(0)
+	store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+
+	account1 := RandomAccount()
+	account2 := RandomAccount()
+	data, err := json.Marshal(gin.H{
+		"from_account_id": account1.ID,
+		"to_account_id":   account2.ID,
+		"amount":          "10.00",
+		"currency":        utils.USD,
+	})
+	require.NoError(t, err)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(idempotencyKeyHeader, db.SystemIdempotencyKeyPrefix+"scheduled-transfer-1")
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account1.Owner, utils.DepositorRole, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/minhdang2803/simple_bank/token"
+)
+
+const (
+	authorizationHeaderKey  = "authorization"
+	authorizationTypeBearer = "bearer"
+	authorizationPayloadKey = "authorization_payload"
+
+	requestIDHeaderKey = "X-Request-ID"
+	requestIDKey       = "request_id"
+	requestIDMaxLength = 128
+)
+
+// requestIDMiddleware tags every request with an id, reusing the one sent by
+// the client when present, so errors can be correlated with server logs
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(requestIDHeaderKey)
+		if len(requestID) == 0 || len(requestID) > requestIDMaxLength {
+			requestID = uuid.NewString()
+		}
+
+		ctx.Set(requestIDKey, requestID)
+		ctx.Header(requestIDHeaderKey, requestID)
+		ctx.Next()
+	}
+}
+
+// authMiddleware rejects requests without a valid bearer token and stores
+// the verified payload in the gin context
+func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
+		if len(authorizationHeader) == 0 {
+			abortWithError(ctx, errUnauthorized("authorization header is not provided"))
+			return
+		}
+
+		fields := strings.Fields(authorizationHeader)
+		if len(fields) < 2 {
+			abortWithError(ctx, errUnauthorized("invalid authorization header format"))
+			return
+		}
+
+		authorizationType := strings.ToLower(fields[0])
+		if authorizationType != authorizationTypeBearer {
+			abortWithError(ctx, errUnauthorized(fmt.Sprintf("unsupported authorization type %s", authorizationType)))
+			return
+		}
+
+		accessToken := fields[1]
+		payload, err := tokenMaker.VerifyToken(accessToken, token.TokenTypeAccess)
+		if err != nil {
+			abortWithError(ctx, errUnauthorized(err.Error()))
+			return
+		}
+
+		ctx.Set(authorizationPayloadKey, payload)
+		ctx.Next()
+	}
+}
+
+// requireRole only lets through callers whose token carries one of the given
+// roles, it must be chained after authMiddleware
+func requireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		if !hasRole(authPayload, roles...) {
+			abortWithError(ctx, errForbidden(fmt.Sprintf("role %s is not allowed to access this resource", authPayload.Role)))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func hasRole(payload *token.Payload, roles ...string) bool {
+	for _, role := range roles {
+		if payload.Role == role {
+			return true
+		}
+	}
+	return false
+}
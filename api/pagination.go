@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	defaultPageSize = 10
+	// fallbackMaxPageSize applies when MAX_PAGE_SIZE isn't configured
+	fallbackMaxPageSize = 100
+)
+
+// PageRequest is bound from the query string of list endpoints. Cursor is
+// empty for the first page, then one of the cursors of the previous response.
+type PageRequest struct {
+	Cursor   string `form:"cursor"`
+	PageSize int32  `form:"page_size" binding:"omitempty,min=1"`
+}
+
+// Page is the envelope of every paginated response, a missing cursor means
+// there is nothing more in that direction
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// cursor is what the opaque token carries, rows are walked by primary key.
+// Tables keyed by text, like users, carry it in Key instead of ID.
+type cursor struct {
+	ID       int64  `json:"id"`
+	Key      string `json:"key,omitempty"`
+	Backward bool   `json:"backward,omitempty"`
+}
+
+// set reports whether the cursor points at a row, the first page has none
+func (c cursor) set() bool {
+	return c.ID > 0 || c.Key != ""
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || json.Unmarshal(data, &c) != nil || !c.set() {
+		return c, errBadRequest("invalid cursor")
+	}
+	return c, nil
+}
+
+// pageQuery is a decoded PageRequest, list queries fetch one more row than
+// the page size to find out whether another page follows
+type pageQuery struct {
+	cursor
+	size int32
+}
+
+func (query pageQuery) limit() int32 {
+	return query.size + 1
+}
+
+// newPageQuery validates the page size against MAX_PAGE_SIZE and decodes the cursor
+func (server *Server) newPageQuery(request PageRequest) (pageQuery, error) {
+	maxPageSize := server.config.MaxPageSize
+	if maxPageSize <= 0 {
+		maxPageSize = fallbackMaxPageSize
+	}
+
+	query := pageQuery{size: request.PageSize}
+	if query.size == 0 {
+		query.size = defaultPageSize
+		if query.size > maxPageSize {
+			query.size = maxPageSize
+		}
+	}
+	if query.size > maxPageSize {
+		return query, errBadRequest(fmt.Sprintf("page_size must be at most %d", maxPageSize))
+	}
+
+	if request.Cursor != "" {
+		c, err := decodeCursor(request.Cursor)
+		if err != nil {
+			return query, err
+		}
+		query.cursor = c
+	}
+	return query, nil
+}
+
+// paginate trims the extra row fetched by the query and builds the cursors.
+// Backward queries return rows newest first, they are put back in id order.
+func paginate[T any](query pageQuery, rows []T, id func(T) int64) ([]T, string, string) {
+	return paginateBy(query, rows, func(row T) cursor {
+		return cursor{ID: id(row)}
+	})
+}
+
+// paginateByKey is paginate for rows walked by a text key
+func paginateByKey[T any](query pageQuery, rows []T, key func(T) string) ([]T, string, string) {
+	return paginateBy(query, rows, func(row T) cursor {
+		return cursor{Key: key(row)}
+	})
+}
+
+func paginateBy[T any](query pageQuery, rows []T, at func(T) cursor) ([]T, string, string) {
+	hasMore := len(rows) > int(query.size)
+	if hasMore {
+		rows = rows[:query.size]
+	}
+
+	if query.Backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	if len(rows) == 0 {
+		return rows, "", ""
+	}
+
+	// walking forward there is something before the cursor, walking backward
+	// there is something after it, the other direction depends on hasMore
+	hasNext := hasMore || (query.Backward && query.set())
+	hasPrev := (!query.Backward && query.set()) || (query.Backward && hasMore)
+
+	var next, prev string
+	if hasNext {
+		next = encodeCursor(at(rows[len(rows)-1]))
+	}
+	if hasPrev {
+		c := at(rows[0])
+		c.Backward = true
+		prev = encodeCursor(c)
+	}
+	return rows, next, prev
+}
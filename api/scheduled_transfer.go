@@ -0,0 +1,219 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/token"
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+// CreateScheduledTransferRequest books a transfer at ExecuteAt instead of right
+// away. Cross-currency transfers are converted at the rate of that day.
+type CreateScheduledTransferRequest struct {
+	FromAccountID int64 `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64 `json:"to_account_id" binding:"required,min=1"`
+	// Amount is a decimal string in Currency such as "12.50"
+	Amount    string    `json:"amount" binding:"required"`
+	Currency  string    `json:"currency" binding:"required,currency"`
+	ExecuteAt time.Time `json:"execute_at" binding:"required"`
+}
+
+type ScheduledTransferResponse struct {
+	ID            int64       `json:"id"`
+	FromAccountID int64       `json:"from_account_id"`
+	ToAccountID   int64       `json:"to_account_id"`
+	Amount        utils.Money `json:"amount"`
+	Currency      string      `json:"currency"`
+	ExecuteAt     time.Time   `json:"execute_at"`
+	Status        string      `json:"status"`
+	Attempts      int32       `json:"attempts"`
+	LastError     string      `json:"last_error,omitempty"`
+	TransferID    *int64      `json:"transfer_id,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+func newScheduledTransferResponse(scheduled db.ScheduledTransfer) ScheduledTransferResponse {
+	return ScheduledTransferResponse{
+		ID:            scheduled.ID,
+		FromAccountID: scheduled.FromAccountID,
+		ToAccountID:   scheduled.ToAccountID,
+		Amount:        utils.NewMoney(scheduled.Amount, scheduled.Currency),
+		Currency:      scheduled.Currency,
+		ExecuteAt:     scheduled.ExecuteAt,
+		Status:        scheduled.Status,
+		Attempts:      scheduled.Attempts,
+		LastError:     scheduled.LastError,
+		TransferID:    nullInt64(scheduled.TransferID),
+		CreatedAt:     scheduled.CreatedAt,
+	}
+}
+
+func (server *Server) CreateScheduledTransfer(ctx *gin.Context) {
+	var request CreateScheduledTransferRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	if !request.ExecuteAt.After(time.Now()) {
+		abortWithError(ctx, errBadRequest("execute_at must be in the future"))
+		return
+	}
+	if request.FromAccountID == request.ToAccountID {
+		abortWithError(ctx, errBadRequest("cannot transfer to the same account"))
+		return
+	}
+
+	amount, err := utils.ParseMoney(request.Amount, request.Currency)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	if amount.Amount <= 0 {
+		abortWithError(ctx, errBadRequest("amount must be positive"))
+		return
+	}
+
+	fromAccount, toAccount, valid := server.validPayment(ctx, request.FromAccountID, request.ToAccountID, request.Currency)
+	if !valid {
+		return
+	}
+
+	scheduled, err := server.store.CreateScheduledTransfer(ctx, db.CreateScheduledTransferParams{
+		Owner:         fromAccount.Owner,
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        amount.Amount,
+		Currency:      request.Currency,
+		ExecuteAt:     request.ExecuteAt,
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newScheduledTransferResponse(scheduled))
+}
+
+// validPayment checks the accounts of a transfer booked later on behalf of the
+// authenticated user. The rate is only known on the day, but an unsupported
+// currency pair can be refused right away.
+func (server *Server) validPayment(ctx *gin.Context, fromAccountID int64, toAccountID int64, currency string) (db.Account, db.Account, bool) {
+	fromAccount, valid := server.validAccount(ctx, fromAccountID, currency)
+	if !valid {
+		return fromAccount, db.Account{}, false
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if fromAccount.Owner != authPayload.Username {
+		abortWithError(ctx, errForbidden("from account doesn't belong to the authenticated user"))
+		return fromAccount, db.Account{}, false
+	}
+
+	toAccount, err := server.store.GetAccount(ctx, toAccountID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return fromAccount, toAccount, false
+	}
+	if toAccount.Currency != fromAccount.Currency {
+		if _, err := server.rates.Rate(ctx, fromAccount.Currency, toAccount.Currency); err != nil {
+			abortWithError(ctx, err)
+			return fromAccount, toAccount, false
+		}
+	}
+	return fromAccount, toAccount, true
+}
+
+// ListScheduledTransfers returns the scheduled transfers of the authenticated
+// user whatever their status, see PageRequest
+func (server *Server) ListScheduledTransfers(ctx *gin.Context) {
+	var request PageRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	page, err := server.newPageQuery(request)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	var scheduled []db.ScheduledTransfer
+	if page.Backward {
+		scheduled, err = server.store.ListScheduledTransfersByOwnerBefore(ctx, db.ListScheduledTransfersByOwnerBeforeParams{
+			Owner:    authPayload.Username,
+			BeforeID: page.ID,
+			Limit:    page.limit(),
+		})
+	} else {
+		scheduled, err = server.store.ListScheduledTransfersByOwner(ctx, db.ListScheduledTransfersByOwnerParams{
+			Owner:   authPayload.Username,
+			AfterID: page.ID,
+			Limit:   page.limit(),
+		})
+	}
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	scheduled, next, prev := paginate(page, scheduled, func(scheduled db.ScheduledTransfer) int64 {
+		return scheduled.ID
+	})
+	response := Page[ScheduledTransferResponse]{
+		Items:      make([]ScheduledTransferResponse, 0, len(scheduled)),
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+	for _, item := range scheduled {
+		response.Items = append(response.Items, newScheduledTransferResponse(item))
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+type ScheduledTransferRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// CancelScheduledTransfer stops a transfer that hasn't run yet
+func (server *Server) CancelScheduledTransfer(ctx *gin.Context) {
+	var request ScheduledTransferRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	scheduled, err := server.store.GetScheduledTransfer(ctx, request.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if scheduled.Owner != authPayload.Username && !hasRole(authPayload, utils.AdminRole) {
+		abortWithError(ctx, errForbidden("scheduled transfer doesn't belong to the authenticated user"))
+		return
+	}
+
+	cancelled, err := server.store.CancelScheduledTransfer(ctx, scheduled.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			// the executor claimed it in the meantime, report what we last saw
+			if scheduled.Status == db.ScheduledTransferPending {
+				scheduled.Status = db.ScheduledTransferRunning
+			}
+			abortWithError(ctx, newAPIError(http.StatusConflict, codeConflict,
+				fmt.Sprintf("scheduled transfer is %s and can't be cancelled anymore", scheduled.Status)))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newScheduledTransferResponse(cancelled))
+}
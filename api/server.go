@@ -1,43 +1,167 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/fx"
+	"github.com/minhdang2803/simple_bank/metrics"
+	"github.com/minhdang2803/simple_bank/token"
+	"github.com/minhdang2803/simple_bank/utils"
 )
 
+// readHeaderTimeout bounds how long a client may take to send the headers
+// of a request
+const readHeaderTimeout = 10 * time.Second
+
 // HTTP request
 type Server struct {
-	store  db.Store
-	router *gin.Engine
+	config     utils.Config
+	store      db.Store
+	tokenMaker token.Maker
+	rates      fx.RateProvider
+	router     *gin.Engine
+	metrics    *metrics.Metrics
+	httpServer *http.Server
+	// schemaStatus is nil unless set by SetSchemaStatus
+	schemaStatus    SchemaStatus
+	readinessChecks []namedCheck
+	// stopping is set by Shutdown, the server is no longer ready meanwhile
+	stopping atomic.Bool
 }
 
-func NewServer(store db.Store) *Server {
-	server := &Server{store: store}
-	router := gin.Default()
+func NewServer(config utils.Config, store db.Store) (*Server, error) {
+	rates, err := fx.NewRateProvider(config.FXRatesFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create rate provider: %w", err)
+	}
+	return NewServerWithMetrics(config, store, rates, metrics.New())
+}
+
+// NewServerWithMetrics creates a server that records its requests in
+// metrics. They aren't served by the API, see metrics.Server. rates should be
+// the provider the scheduler uses too, so both convert at the same rates.
+func NewServerWithMetrics(config utils.Config, store db.Store, rates fx.RateProvider, metrics *metrics.Metrics) (*Server, error) {
+	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create token maker: %w", err)
+	}
+
+	server := &Server{
+		config:     config,
+		store:      store,
+		tokenMaker: tokenMaker,
+		rates:      rates,
+		metrics:    metrics,
+	}
 
 	// custom validator
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("currency", validatorCurrency)
+		v.RegisterValidation("enabled_currency", validatorEnabledCurrency)
+		v.RegisterTagNameFunc(fieldName)
 	}
 
-	router.POST("/accounts", server.CreateAccount)
-	router.GET("/accounts/:id", server.GetAccount)
-	router.GET("/accounts", server.ListAccount)
-	router.POST("/transfers", server.CreateTransfer)
+	server.setupRouter()
+	server.httpServer = &http.Server{
+		Handler:           server.router,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	return server, nil
+}
+
+func (server *Server) setupRouter() {
+	router := gin.Default()
+	router.Use(requestIDMiddleware(), server.metrics.Middleware())
+
 	router.POST("/users", server.CreateUser)
-	router.GET("/users", server.GetUser)
-	router.POST("/users/update", server.UpdateUser)
-	server.router = router
-	return server
+	router.POST("/users/login", server.LoginUser)
+	router.POST("/tokens/renew_access", server.RenewAccessToken)
+	router.GET("/currencies", server.ListCurrencies)
+	router.GET("/healthz", server.Liveness)
+	router.GET("/readyz", server.Readiness)
 
+	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker))
+	authRoutes.POST("/accounts", server.CreateAccount)
+	authRoutes.GET("/accounts/:id", server.GetAccount)
+	authRoutes.GET("/accounts", server.ListAccount)
+	authRoutes.GET("/accounts/:id/entries", server.ListAccountEntries)
+	authRoutes.GET("/accounts/:id/statement", server.GetStatement)
+	authRoutes.GET("/accounts/:id/transfers", server.ListAccountTransfers)
+	authRoutes.GET("/accounts/:id/holds", server.ListAccountHolds)
+	authRoutes.POST("/accounts/:id/close", server.CloseAccount)
+	authRoutes.POST("/transfers", server.CreateTransfer)
+	authRoutes.POST("/transfers/batch", server.CreateTransferBatch)
+	authRoutes.GET("/transfers/batch/:id", server.GetTransferBatch)
+	authRoutes.GET("/transfers/:id", server.GetTransfer)
+	authRoutes.POST("/transfers/:id/reverse", server.ReverseTransfer)
+	authRoutes.POST("/holds", server.CreateHold)
+	authRoutes.GET("/holds/:id", server.GetHold)
+	authRoutes.POST("/holds/:id/capture", server.CaptureHold)
+	authRoutes.POST("/holds/:id/void", server.VoidHold)
+	authRoutes.POST("/scheduled-transfers", server.CreateScheduledTransfer)
+	authRoutes.GET("/scheduled-transfers", server.ListScheduledTransfers)
+	authRoutes.POST("/scheduled-transfers/:id/cancel", server.CancelScheduledTransfer)
+	authRoutes.POST("/standing-orders", server.CreateStandingOrder)
+	authRoutes.GET("/standing-orders", server.ListStandingOrders)
+	authRoutes.GET("/standing-orders/:id/runs", server.ListStandingOrderRuns)
+	authRoutes.POST("/standing-orders/:id/pause", server.PauseStandingOrder)
+	authRoutes.POST("/standing-orders/:id/resume", server.ResumeStandingOrder)
+	authRoutes.POST("/standing-orders/:id/cancel", server.CancelStandingOrder)
+	authRoutes.GET("/users", server.GetUser)
+	authRoutes.POST("/users/update", server.UpdateUser)
+	authRoutes.POST("/sessions/:id/revoke", server.RevokeSession)
+	authRoutes.POST("/sessions/revoke_all", server.RevokeAllSessions)
+
+	adminRoutes := router.Group("/admin").Use(authMiddleware(server.tokenMaker), requireRole(utils.AdminRole))
+	adminRoutes.GET("/users", server.ListUsers)
+	adminRoutes.GET("/accounts", server.ListAllAccounts)
+	adminRoutes.POST("/accounts/:id/overdraft_limit", server.UpdateOverdraftLimit)
+	adminRoutes.POST("/accounts/:id/freeze", server.FreezeAccount)
+	adminRoutes.POST("/accounts/:id/unfreeze", server.UnfreezeAccount)
+	adminRoutes.GET("/currencies", server.ListAllCurrencies)
+	adminRoutes.POST("/currencies/:code/enabled", server.UpdateCurrencyEnabled)
+	adminRoutes.GET("/ledger/verify", server.VerifyLedger)
+
+	server.router = router
 }
 
+// Start serves HTTP requests on address until Shutdown, it returns nil once
+// shut down
 func (server *Server) Start(address string) error {
-	return server.router.Run(address)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return server.Serve(listener)
 }
 
-func errorResponse(err error) gin.H {
-	return gin.H{"error": err.Error()}
+// Serve serves HTTP requests on listener until Shutdown, it returns nil once
+// shut down
+func (server *Server) Serve(listener net.Listener) error {
+	err := server.httpServer.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting connections and waits for the requests in
+// flight until ctx is done, then closes the connections left
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.stopping.Store(true)
+	if err := server.httpServer.Shutdown(ctx); err != nil {
+		server.httpServer.Close()
+		return err
+	}
+	return nil
 }
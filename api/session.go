@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/minhdang2803/simple_bank/token"
+)
+
+type RevokeSessionRequest struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+// RevokeSession blocks a single session of the authenticated user so its
+// refresh token can no longer be used to renew access tokens
+func (server *Server) RevokeSession(ctx *gin.Context) {
+	var request RevokeSessionRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	sessionID, err := uuid.Parse(request.ID)
+	if err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	session, err := server.store.GetSession(ctx, sessionID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if session.Username != authPayload.Username {
+		abortWithError(ctx, errForbidden("session doesn't belong to the authenticated user"))
+		return
+	}
+
+	_, err = server.store.BlockSession(ctx, session.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Succesfully revoke session %s", session.ID),
+	})
+}
+
+// RevokeAllSessions logs the authenticated user out everywhere by blocking
+// every active session
+func (server *Server) RevokeAllSessions(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	err := server.store.BlockUserSessions(ctx, authPayload.Username)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Succesfully revoke all sessions of %s", authPayload.Username),
+	})
+}
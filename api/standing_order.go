@@ -0,0 +1,367 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/scheduler"
+	"github.com/minhdang2803/simple_bank/token"
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+// CreateStandingOrderRequest books a transfer on every occurrence of Schedule,
+// a cron expression evaluated in UTC such as "0 9 * * mon" or "0 9 L * *" for
+// the last day of the month, see scheduler.Schedule
+type CreateStandingOrderRequest struct {
+	FromAccountID int64 `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64 `json:"to_account_id" binding:"required,min=1"`
+	// Amount is a decimal string in Currency such as "12.50"
+	Amount   string `json:"amount" binding:"required"`
+	Currency string `json:"currency" binding:"required,currency"`
+	Schedule string `json:"schedule" binding:"required"`
+	// StartAt defaults to now, EndAt and MaxOccurrences to never
+	StartAt        *time.Time `json:"start_at"`
+	EndAt          *time.Time `json:"end_at"`
+	MaxOccurrences int32      `json:"max_occurrences" binding:"omitempty,min=1"`
+	// CatchUp applies to the occurrences missed while the executor was down,
+	// it defaults to latest
+	CatchUp string `json:"catch_up" binding:"omitempty,oneof=all latest skip"`
+}
+
+type StandingOrderResponse struct {
+	ID             int64       `json:"id"`
+	FromAccountID  int64       `json:"from_account_id"`
+	ToAccountID    int64       `json:"to_account_id"`
+	Amount         utils.Money `json:"amount"`
+	Currency       string      `json:"currency"`
+	Schedule       string      `json:"schedule"`
+	CatchUp        string      `json:"catch_up"`
+	StartAt        time.Time   `json:"start_at"`
+	EndAt          *time.Time  `json:"end_at,omitempty"`
+	MaxOccurrences *int32      `json:"max_occurrences,omitempty"`
+	Occurrences    int32       `json:"occurrences"`
+	Status         string      `json:"status"`
+	NextRunAt      *time.Time  `json:"next_run_at,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+func newStandingOrderResponse(order db.StandingOrder) StandingOrderResponse {
+	response := StandingOrderResponse{
+		ID:            order.ID,
+		FromAccountID: order.FromAccountID,
+		ToAccountID:   order.ToAccountID,
+		Amount:        utils.NewMoney(order.Amount, order.Currency),
+		Currency:      order.Currency,
+		Schedule:      order.Schedule,
+		CatchUp:       order.CatchUp,
+		StartAt:       order.StartAt,
+		Occurrences:   order.Occurrences,
+		Status:        order.Status,
+		CreatedAt:     order.CreatedAt,
+	}
+	if order.EndAt.Valid {
+		response.EndAt = &order.EndAt.Time
+	}
+	if order.MaxOccurrences.Valid {
+		response.MaxOccurrences = &order.MaxOccurrences.Int32
+	}
+	// there is no next run once the order is over
+	if order.Status == db.StandingOrderActive || order.Status == db.StandingOrderPaused {
+		response.NextRunAt = &order.NextRunAt
+	}
+	return response
+}
+
+type StandingOrderRunResponse struct {
+	ID           int64     `json:"id"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	Status       string    `json:"status"`
+	TransferID   *int64    `json:"transfer_id,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newStandingOrderRunResponse(run db.StandingOrderRun) StandingOrderRunResponse {
+	return StandingOrderRunResponse{
+		ID:           run.ID,
+		ScheduledFor: run.ScheduledFor,
+		Status:       run.Status,
+		TransferID:   nullInt64(run.TransferID),
+		Error:        run.Error,
+		CreatedAt:    run.CreatedAt,
+	}
+}
+
+// firstRun returns the first occurrence of schedule at or after from
+func firstRun(schedule *scheduler.Schedule, from time.Time) time.Time {
+	return schedule.Next(from.Add(-time.Second))
+}
+
+func (server *Server) CreateStandingOrder(ctx *gin.Context) {
+	var request CreateStandingOrderRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	schedule, err := scheduler.ParseSchedule(request.Schedule)
+	if err != nil {
+		abortWithError(ctx, errBadRequest(err.Error()))
+		return
+	}
+
+	arg := db.CreateStandingOrderParams{
+		Schedule: request.Schedule,
+		CatchUp:  request.CatchUp,
+		StartAt:  time.Now().UTC().Truncate(time.Second),
+	}
+	if arg.CatchUp == "" {
+		arg.CatchUp = db.CatchUpLatest
+	}
+	if request.StartAt != nil {
+		arg.StartAt = *request.StartAt
+	}
+	if request.EndAt != nil {
+		if !request.EndAt.After(arg.StartAt) {
+			abortWithError(ctx, errBadRequest("end_at must be after start_at"))
+			return
+		}
+		arg.EndAt = sql.NullTime{Time: *request.EndAt, Valid: true}
+	}
+	if request.MaxOccurrences > 0 {
+		arg.MaxOccurrences = sql.NullInt32{Int32: request.MaxOccurrences, Valid: true}
+	}
+
+	arg.NextRunAt = firstRun(schedule, arg.StartAt)
+	if arg.NextRunAt.IsZero() || (arg.EndAt.Valid && arg.NextRunAt.After(arg.EndAt.Time)) {
+		abortWithError(ctx, errBadRequest("schedule has no occurrence between start_at and end_at"))
+		return
+	}
+
+	if request.FromAccountID == request.ToAccountID {
+		abortWithError(ctx, errBadRequest("cannot transfer to the same account"))
+		return
+	}
+
+	amount, err := utils.ParseMoney(request.Amount, request.Currency)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	if amount.Amount <= 0 {
+		abortWithError(ctx, errBadRequest("amount must be positive"))
+		return
+	}
+
+	fromAccount, toAccount, valid := server.validPayment(ctx, request.FromAccountID, request.ToAccountID, request.Currency)
+	if !valid {
+		return
+	}
+
+	arg.Owner = fromAccount.Owner
+	arg.FromAccountID = fromAccount.ID
+	arg.ToAccountID = toAccount.ID
+	arg.Amount = amount.Amount
+	arg.Currency = request.Currency
+
+	order, err := server.store.CreateStandingOrder(ctx, arg)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newStandingOrderResponse(order))
+}
+
+// ListStandingOrders returns the standing orders of the authenticated user
+// whatever their status, see PageRequest
+func (server *Server) ListStandingOrders(ctx *gin.Context) {
+	var request PageRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	page, err := server.newPageQuery(request)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	var orders []db.StandingOrder
+	if page.Backward {
+		orders, err = server.store.ListStandingOrdersByOwnerBefore(ctx, db.ListStandingOrdersByOwnerBeforeParams{
+			Owner:    authPayload.Username,
+			BeforeID: page.ID,
+			Limit:    page.limit(),
+		})
+	} else {
+		orders, err = server.store.ListStandingOrdersByOwner(ctx, db.ListStandingOrdersByOwnerParams{
+			Owner:   authPayload.Username,
+			AfterID: page.ID,
+			Limit:   page.limit(),
+		})
+	}
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	orders, next, prev := paginate(page, orders, func(order db.StandingOrder) int64 {
+		return order.ID
+	})
+	response := Page[StandingOrderResponse]{
+		Items:      make([]StandingOrderResponse, 0, len(orders)),
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+	for _, order := range orders {
+		response.Items = append(response.Items, newStandingOrderResponse(order))
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+type StandingOrderRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// ownedStandingOrder loads the standing order of the request, it must belong
+// to the authenticated user unless they are an admin
+func (server *Server) ownedStandingOrder(ctx *gin.Context) (db.StandingOrder, bool) {
+	var request StandingOrderRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return db.StandingOrder{}, false
+	}
+
+	order, err := server.store.GetStandingOrder(ctx, request.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return order, false
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if order.Owner != authPayload.Username && !hasRole(authPayload, utils.AdminRole) {
+		abortWithError(ctx, errForbidden("standing order doesn't belong to the authenticated user"))
+		return order, false
+	}
+	return order, true
+}
+
+// ListStandingOrderRuns returns what happened to each occurrence of a
+// standing order, newest first, see PageRequest
+func (server *Server) ListStandingOrderRuns(ctx *gin.Context) {
+	var request PageRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	page, err := server.newPageQuery(request)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	order, ok := server.ownedStandingOrder(ctx)
+	if !ok {
+		return
+	}
+
+	var runs []db.StandingOrderRun
+	if page.Backward {
+		runs, err = server.store.ListStandingOrderRunsAfter(ctx, db.ListStandingOrderRunsAfterParams{
+			StandingOrderID: order.ID,
+			AfterID:         page.ID,
+			Limit:           page.limit(),
+		})
+	} else {
+		beforeID := page.ID
+		if beforeID == 0 {
+			beforeID = math.MaxInt64
+		}
+		runs, err = server.store.ListStandingOrderRuns(ctx, db.ListStandingOrderRunsParams{
+			StandingOrderID: order.ID,
+			BeforeID:        beforeID,
+			Limit:           page.limit(),
+		})
+	}
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	runs, next, prev := paginate(page, runs, func(run db.StandingOrderRun) int64 {
+		return run.ID
+	})
+	response := Page[StandingOrderRunResponse]{
+		Items:      make([]StandingOrderRunResponse, 0, len(runs)),
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+	for _, run := range runs {
+		response.Items = append(response.Items, newStandingOrderRunResponse(run))
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// updateStandingOrder applies a status change to the standing order of the
+// request, no row from update means the order isn't in a state that allows it
+func (server *Server) updateStandingOrder(ctx *gin.Context, action string, update func(order db.StandingOrder) (db.StandingOrder, error)) {
+	order, ok := server.ownedStandingOrder(ctx)
+	if !ok {
+		return
+	}
+
+	updated, err := update(order)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			abortWithError(ctx, newAPIError(http.StatusConflict, codeConflict,
+				fmt.Sprintf("standing order is %s and can't be %s", order.Status, action)))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newStandingOrderResponse(updated))
+}
+
+// PauseStandingOrder stops an order until it is resumed
+func (server *Server) PauseStandingOrder(ctx *gin.Context) {
+	server.updateStandingOrder(ctx, "paused", func(order db.StandingOrder) (db.StandingOrder, error) {
+		return server.store.PauseStandingOrder(ctx, order.ID)
+	})
+}
+
+// ResumeStandingOrder restarts a paused order from its next occurrence, the
+// ones that came due while it was paused are not caught up
+func (server *Server) ResumeStandingOrder(ctx *gin.Context) {
+	server.updateStandingOrder(ctx, "resumed", func(order db.StandingOrder) (db.StandingOrder, error) {
+		schedule, err := scheduler.ParseSchedule(order.Schedule)
+		if err != nil {
+			return order, err
+		}
+
+		from := time.Now()
+		if order.StartAt.After(from) {
+			from = order.StartAt
+		}
+		return server.store.ResumeStandingOrder(ctx, db.ResumeStandingOrderParams{
+			ID:        order.ID,
+			NextRunAt: firstRun(schedule, from),
+		})
+	})
+}
+
+// CancelStandingOrder ends an order for good, the runs already booked stay
+func (server *Server) CancelStandingOrder(ctx *gin.Context) {
+	server.updateStandingOrder(ctx, "cancelled", func(order db.StandingOrder) (db.StandingOrder, error) {
+		return server.store.CancelStandingOrder(ctx, order.ID)
+	})
+}
@@ -0,0 +1,357 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func randomStandingOrder(from db.Account, to db.Account) db.StandingOrder {
+	return db.StandingOrder{
+		ID:            utils.RandomInt(1, 1000),
+		Owner:         from.Owner,
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        utils.RandomInt(1, 1000),
+		Currency:      from.Currency,
+		Schedule:      "0 9 L * *",
+		CatchUp:       db.CatchUpLatest,
+		StartAt:       time.Now().UTC().Truncate(time.Second),
+		Status:        db.StandingOrderActive,
+		NextRunAt:     time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second),
+	}
+}
+
+func TestCreateStandingOrderAPI(t *testing.T) {
+	account1 := RandomAccount()
+	account2 := RandomAccount()
+	account1.Currency = utils.USD
+	account2.Currency = utils.USD
+	startAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          "800.00",
+				"currency":        utils.USD,
+				"schedule":        "0 9 L * *",
+				"start_at":        startAt,
+				"max_occurrences": 12,
+			},
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				arg := db.CreateStandingOrderParams{
+					Owner:          account1.Owner,
+					FromAccountID:  account1.ID,
+					ToAccountID:    account2.ID,
+					Amount:         80000,
+					Currency:       utils.USD,
+					Schedule:       "0 9 L * *",
+					CatchUp:        db.CatchUpLatest,
+					StartAt:        startAt,
+					MaxOccurrences: sql.NullInt32{Int32: 12, Valid: true},
+					NextRunAt:      time.Date(2030, 1, 31, 9, 0, 0, 0, time.UTC),
+				}
+				store.EXPECT().CreateStandingOrder(gomock.Any(), gomock.Eq(arg)).Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateStandingOrderParams) (db.StandingOrder, error) {
+						return db.StandingOrder{
+							ID:             1,
+							Owner:          arg.Owner,
+							FromAccountID:  arg.FromAccountID,
+							ToAccountID:    arg.ToAccountID,
+							Amount:         arg.Amount,
+							Currency:       arg.Currency,
+							Schedule:       arg.Schedule,
+							CatchUp:        arg.CatchUp,
+							StartAt:        arg.StartAt,
+							MaxOccurrences: arg.MaxOccurrences,
+							Status:         db.StandingOrderActive,
+							NextRunAt:      arg.NextRunAt,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"amount":"800.00"`)
+				require.Contains(t, recorder.Body.String(), `"next_run_at":"2030-01-31T09:00:00Z"`)
+				require.Contains(t, recorder.Body.String(), `"max_occurrences":12`)
+			},
+		},
+		{
+			name: "Invalid schedule",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          "800.00",
+				"currency":        utils.USD,
+				"schedule":        "0 9 32 * *",
+			},
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateStandingOrder(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Invalid catch-up",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          "800.00",
+				"currency":        utils.USD,
+				"schedule":        "@monthly",
+				"catch_up":        "some",
+			},
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateStandingOrder(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "No occurrence before the end",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          "800.00",
+				"currency":        utils.USD,
+				"schedule":        "0 9 L * *",
+				"start_at":        startAt,
+				"end_at":          startAt.Add(7 * 24 * time.Hour),
+			},
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateStandingOrder(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Not the owner",
+			body: gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          "800.00",
+				"currency":        utils.USD,
+				"schedule":        "@monthly",
+			},
+			username: account2.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().CreateStandingOrder(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/standing-orders", bytes.NewReader(data))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, utils.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateStandingOrderAPI(t *testing.T) {
+	account1 := RandomAccount()
+	account2 := RandomAccount()
+	order := randomStandingOrder(account1, account2)
+
+	testCases := []struct {
+		name          string
+		action        string
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Pause",
+			action:   "pause",
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				paused := order
+				paused.Status = db.StandingOrderPaused
+				store.EXPECT().GetStandingOrder(gomock.Any(), gomock.Eq(order.ID)).Times(1).Return(order, nil)
+				store.EXPECT().PauseStandingOrder(gomock.Any(), gomock.Eq(order.ID)).Times(1).Return(paused, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"status":"paused"`)
+			},
+		},
+		{
+			name:     "Pause twice",
+			action:   "pause",
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				paused := order
+				paused.Status = db.StandingOrderPaused
+				store.EXPECT().GetStandingOrder(gomock.Any(), gomock.Eq(order.ID)).Times(1).Return(paused, nil)
+				store.EXPECT().PauseStandingOrder(gomock.Any(), gomock.Eq(order.ID)).Times(1).Return(db.StandingOrder{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "standing order is paused and can't be paused")
+			},
+		},
+		{
+			name:     "Resume",
+			action:   "resume",
+			username: account1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				paused := order
+				paused.Status = db.StandingOrderPaused
+				store.EXPECT().GetStandingOrder(gomock.Any(), gomock.Eq(order.ID)).Times(1).Return(paused, nil)
+				store.EXPECT().ResumeStandingOrder(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, arg db.ResumeStandingOrderParams) (db.StandingOrder, error) {
+						require.Equal(t, order.ID, arg.ID)
+						require.True(t, arg.NextRunAt.After(time.Now()))
+						require.Equal(t, 9, arg.NextRunAt.Hour())
+						require.Equal(t, 1, arg.NextRunAt.AddDate(0, 0, 1).Day())
+						return order, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "Cancel by an admin",
+			action:   "cancel",
+			username: "admin",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetStandingOrder(gomock.Any(), gomock.Eq(order.ID)).Times(1).Return(order, nil)
+				store.EXPECT().CancelStandingOrder(gomock.Any(), gomock.Eq(order.ID)).Times(1).Return(order, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "Not the owner",
+			action:   "cancel",
+			username: account2.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetStandingOrder(gomock.Any(), gomock.Eq(order.ID)).Times(1).Return(order, nil)
+				store.EXPECT().CancelStandingOrder(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/standing-orders/%d/%s", order.ID, tc.action)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			role := utils.DepositorRole
+			if tc.username == "admin" {
+				role = utils.AdminRole
+			}
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListStandingOrderRunsAPI(t *testing.T) {
+	account1 := RandomAccount()
+	account2 := RandomAccount()
+	order := randomStandingOrder(account1, account2)
+	runs := []db.StandingOrderRun{
+		{ID: 2, StandingOrderID: order.ID, Status: db.StandingOrderRunCompleted, TransferID: sql.NullInt64{Int64: 7, Valid: true}},
+		{ID: 1, StandingOrderID: order.ID, Status: db.StandingOrderRunFailed, Error: db.ErrInsufficientFunds.Error()},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetStandingOrder(gomock.Any(), gomock.Eq(order.ID)).Times(1).Return(order, nil)
+	store.EXPECT().ListStandingOrderRuns(gomock.Any(), gomock.Eq(db.ListStandingOrderRunsParams{
+		StandingOrderID: order.ID,
+		BeforeID:        math.MaxInt64,
+		Limit:           defaultPageSize + 1,
+	})).Times(1).Return(runs, nil)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	url := fmt.Sprintf("/standing-orders/%d/runs", order.ID)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account1.Owner, utils.DepositorRole, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var page Page[StandingOrderRunResponse]
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &page))
+	require.Len(t, page.Items, 2)
+	require.Equal(t, int64(7), *page.Items[0].TransferID)
+	require.Equal(t, db.ErrInsufficientFunds.Error(), page.Items[1].Error)
+}
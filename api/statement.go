@@ -0,0 +1,256 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/export"
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+const (
+	statementDateFormat = "2006-01-02"
+	// maxStatementDays keeps a single statement query bounded
+	maxStatementDays = 366
+)
+
+// ListAccountEntries returns the raw ledger entries of an account, see PageRequest
+func (server *Server) ListAccountEntries(ctx *gin.Context) {
+	var uri GetAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	var request PageRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	page, err := server.newPageQuery(request)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	account, ok := server.readableAccount(ctx, uri.Id)
+	if !ok {
+		return
+	}
+
+	var entries []db.Entry
+	if page.Backward {
+		entries, err = server.store.ListEntriesBefore(ctx, db.ListEntriesBeforeParams{
+			AccountID: account.ID,
+			BeforeID:  page.ID,
+			Limit:     page.limit(),
+		})
+	} else {
+		entries, err = server.store.ListEntries(ctx, db.ListEntriesParams{
+			AccountID: account.ID,
+			AfterID:   page.ID,
+			Limit:     page.limit(),
+		})
+	}
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	entries, next, prev := paginate(page, entries, func(entry db.Entry) int64 {
+		return entry.ID
+	})
+	response := Page[EntryResponse]{
+		Items:      make([]EntryResponse, 0, len(entries)),
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+	for _, entry := range entries {
+		response.Items = append(response.Items, newEntryResponse(entry, account.Currency))
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// StatementRequest selects whole days, both ends included, in UTC.
+// Format overrides the Accept header, JSON is used when neither asks for an export.
+type StatementRequest struct {
+	From   time.Time `form:"from" time_format:"2006-01-02" time_utc:"1" binding:"required"`
+	To     time.Time `form:"to" time_format:"2006-01-02" time_utc:"1" binding:"required"`
+	Format string    `form:"format" binding:"omitempty,oneof=json csv ofx camt053"`
+}
+
+// exportFormat reports which export the client asked for, false means JSON
+func (request StatementRequest) exportFormat(accept string) (export.Format, bool) {
+	if request.Format != "" {
+		return export.ParseFormat(request.Format)
+	}
+	return export.FormatFromAccept(accept)
+}
+
+type StatementLineResponse struct {
+	EntryID               int64       `json:"entry_id"`
+	Amount                utils.Money `json:"amount"`
+	RunningBalance        utils.Money `json:"running_balance"`
+	TransferID            *int64      `json:"transfer_id,omitempty"`
+	CounterpartyAccountID *int64      `json:"counterparty_account_id,omitempty"`
+	CounterpartyOwner     string      `json:"counterparty_owner,omitempty"`
+	CreatedAt             time.Time   `json:"created_at"`
+}
+
+type StatementResponse struct {
+	AccountID      int64                   `json:"account_id"`
+	Currency       string                  `json:"currency"`
+	From           string                  `json:"from"`
+	To             string                  `json:"to"`
+	OpeningBalance utils.Money             `json:"opening_balance"`
+	ClosingBalance utils.Money             `json:"closing_balance"`
+	Lines          []StatementLineResponse `json:"lines"`
+}
+
+func newStatementResponse(account db.Account, request StatementRequest, openingBalance int64, rows []db.ListStatementLinesRow) StatementResponse {
+	response := StatementResponse{
+		AccountID:      account.ID,
+		Currency:       account.Currency,
+		From:           request.From.Format(statementDateFormat),
+		To:             request.To.Format(statementDateFormat),
+		OpeningBalance: utils.NewMoney(openingBalance, account.Currency),
+		ClosingBalance: utils.NewMoney(openingBalance, account.Currency),
+		Lines:          make([]StatementLineResponse, 0, len(rows)),
+	}
+
+	for _, row := range rows {
+		line := StatementLineResponse{
+			EntryID:        row.ID,
+			Amount:         utils.NewMoney(row.Amount, account.Currency),
+			RunningBalance: utils.NewMoney(openingBalance+row.RunningTotal, account.Currency),
+			TransferID:     nullInt64(row.TransferID),
+			CreatedAt:      row.CreatedAt,
+		}
+		if row.CounterpartyAccountID != 0 {
+			line.CounterpartyAccountID = &row.CounterpartyAccountID
+			line.CounterpartyOwner = row.CounterpartyOwner
+		}
+		response.Lines = append(response.Lines, line)
+		response.ClosingBalance = line.RunningBalance
+	}
+	return response
+}
+
+// GetStatement returns the entries of an account between two dates along
+// with the opening, running and closing balances
+func (server *Server) GetStatement(ctx *gin.Context) {
+	var uri GetAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	var request StatementRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+	if request.To.Before(request.From) {
+		abortWithError(ctx, errBadRequest("to must not be before from"))
+		return
+	}
+	if request.To.Sub(request.From) >= maxStatementDays*24*time.Hour {
+		abortWithError(ctx, errBadRequest("statement can't cover more than 366 days"))
+		return
+	}
+
+	account, ok := server.readableAccount(ctx, uri.Id)
+	if !ok {
+		return
+	}
+
+	arg := db.ListStatementLinesParams{
+		AccountID: account.ID,
+		FromTime:  request.From,
+		ToTime:    request.To.AddDate(0, 0, 1),
+	}
+
+	if format, ok := request.exportFormat(ctx.GetHeader("Accept")); ok {
+		server.exportStatement(ctx, format, account, request, arg)
+		return
+	}
+
+	var balances db.StatementBalances
+	rows := []db.ListStatementLinesRow{}
+	err := server.store.StatementTx(ctx, arg, func(read db.StatementBalances) error {
+		balances = read
+		return nil
+	}, func(row db.ListStatementLinesRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newStatementResponse(account, request, balances.Opening, rows))
+}
+
+// exportStatement streams the statement lines straight from the database to
+// the client. The balances and the lines are read in one snapshot so that
+// they reconcile. Once the first byte is sent the status can't change
+// anymore, so a failure midway is only logged and the truncated body is left
+// to the client.
+func (server *Server) exportStatement(ctx *gin.Context, format export.Format, account db.Account, request StatementRequest, arg db.ListStatementLinesParams) {
+	writer := export.NewWriter(format, ctx.Writer)
+	var openingBalance int64
+	started := false
+
+	err := server.store.StatementTx(ctx, arg, func(balances db.StatementBalances) error {
+		filename := fmt.Sprintf("statement-%d-%s-%s.%s", account.ID,
+			request.From.Format(statementDateFormat), request.To.Format(statementDateFormat), format.Extension())
+		ctx.Header("Content-Type", format.ContentType())
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		ctx.Status(http.StatusOK)
+		started = true
+
+		// formats such as camt.053 print the closing balance before the entries
+		openingBalance = balances.Opening
+		return writer.Begin(export.Statement{
+			AccountID:      account.ID,
+			Owner:          account.Owner,
+			Currency:       account.Currency,
+			From:           request.From,
+			To:             request.To,
+			OpeningBalance: balances.Opening,
+			ClosingBalance: balances.Closing,
+			GeneratedAt:    time.Now(),
+		})
+	}, func(row db.ListStatementLinesRow) error {
+		return writer.WriteLine(newExportLine(row, openingBalance))
+	})
+	if err == nil {
+		err = writer.End()
+	}
+	if err != nil {
+		if !started {
+			abortWithError(ctx, err)
+			return
+		}
+		log.Printf("cannot export statement of account %d: %v", account.ID, err)
+		ctx.Abort()
+	}
+}
+
+func newExportLine(row db.ListStatementLinesRow, openingBalance int64) export.Line {
+	return export.Line{
+		EntryID:               row.ID,
+		TransferID:            row.TransferID.Int64,
+		CounterpartyAccountID: row.CounterpartyAccountID,
+		CounterpartyOwner:     row.CounterpartyOwner,
+		Amount:                row.Amount,
+		RunningBalance:        openingBalance + row.RunningTotal,
+		BookedAt:              row.CreatedAt,
+	}
+}
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStatementAPI(t *testing.T) {
+	account := RandomAccount()
+	account.Currency = utils.USD
+	counterparty := RandomAccount()
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	rows := []db.ListStatementLinesRow{
+		{
+			ID:                    1,
+			AccountID:             account.ID,
+			Amount:                -250,
+			CreatedAt:             from.Add(time.Hour),
+			TransferID:            sql.NullInt64{Int64: 7, Valid: true},
+			CounterpartyAccountID: counterparty.ID,
+			CounterpartyOwner:     counterparty.Owner,
+			RunningTotal:          -250,
+		},
+		{
+			ID:           2,
+			AccountID:    account.ID,
+			Amount:       1000,
+			CreatedAt:    from.Add(2 * time.Hour),
+			RunningTotal: 750,
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		query         string
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			query:    "from=2024-03-01&to=2024-03-31",
+			username: account.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().StatementTx(gomock.Any(), gomock.Eq(db.ListStatementLinesParams{
+					AccountID: account.ID,
+					FromTime:  from,
+					ToTime:    to.AddDate(0, 0, 1),
+				}), gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(statementTx(db.StatementBalances{Opening: 500, Closing: 1250}, rows))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var statement struct {
+					OpeningBalance string `json:"opening_balance"`
+					ClosingBalance string `json:"closing_balance"`
+					Lines          []struct {
+						RunningBalance        string `json:"running_balance"`
+						CounterpartyAccountID *int64 `json:"counterparty_account_id"`
+					} `json:"lines"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &statement))
+
+				require.Equal(t, "5.00", statement.OpeningBalance)
+				require.Equal(t, "12.50", statement.ClosingBalance)
+				require.Len(t, statement.Lines, 2)
+				require.Equal(t, "2.50", statement.Lines[0].RunningBalance)
+				require.Equal(t, counterparty.ID, *statement.Lines[0].CounterpartyAccountID)
+				require.Nil(t, statement.Lines[1].CounterpartyAccountID)
+			},
+		},
+		{
+			name:     "NoEntries",
+			query:    "from=2024-03-01&to=2024-03-31",
+			username: account.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().StatementTx(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(statementTx(db.StatementBalances{Opening: 500, Closing: 500}, nil))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"closing_balance":"5.00"`)
+			},
+		},
+		{
+			name:     "NotOwner",
+			query:    "from=2024-03-01&to=2024-03-31",
+			username: counterparty.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().StatementTx(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "InvertedRange",
+			query:    "from=2024-03-31&to=2024-03-01",
+			username: account.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "RangeTooLong",
+			query:    "from=2023-01-01&to=2024-03-01",
+			username: account.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "InvalidDate",
+			query:    "from=2024-03-01T00:00:00Z&to=2024-03-31",
+			username: account.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%d/statement?%s", account.ID, tc.query)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, utils.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestExportStatementAPI(t *testing.T) {
+	account := RandomAccount()
+	account.Currency = utils.USD
+
+	rows := []db.ListStatementLinesRow{
+		{ID: 1, AccountID: account.ID, Amount: -250, RunningTotal: -250},
+		{ID: 2, AccountID: account.ID, Amount: 1000, RunningTotal: 750},
+	}
+
+	testCases := []struct {
+		name        string
+		query       string
+		accept      string
+		contentType string
+		contains    string
+	}{
+		{
+			name:        "FormatParam",
+			query:       "format=csv",
+			accept:      "application/json",
+			contentType: "text/csv",
+			contains:    "1,,,,-2.50,2.50,USD",
+		},
+		{
+			name:        "AcceptHeader",
+			accept:      "application/x-ofx",
+			contentType: "application/x-ofx",
+			contains:    "<BALAMT>12.50</BALAMT>",
+		},
+		{
+			name:        "Camt053",
+			query:       "format=camt053",
+			contentType: "application/xml",
+			contains:    "<Cd>CLBD</Cd>",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			store.EXPECT().StatementTx(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+				DoAndReturn(statementTx(db.StatementBalances{Opening: 500, Closing: 1250}, rows))
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%d/statement?from=2024-03-01&to=2024-03-31&%s", account.ID, tc.query)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			if tc.accept != "" {
+				request.Header.Set("Accept", tc.accept)
+			}
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account.Owner, utils.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			require.Equal(t, tc.contentType, recorder.Header().Get("Content-Type"))
+			require.Contains(t, recorder.Header().Get("Content-Disposition"), "attachment")
+			require.Contains(t, recorder.Body.String(), tc.contains)
+		})
+	}
+}
+
+func TestExportStatementAPIError(t *testing.T) {
+	account := RandomAccount()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	// nothing was sent yet, the client gets a proper error
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+	store.EXPECT().StatementTx(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(sql.ErrConnDone)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	url := fmt.Sprintf("/accounts/%d/statement?from=2024-03-01&to=2024-03-31&format=csv", account.ID)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, account.Owner, utils.DepositorRole, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusInternalServerError, recorder.Code)
+	require.Contains(t, recorder.Header().Get("Content-Type"), "application/json")
+}
+
+// statementTx stubs Store.StatementTx with a snapshot of balances and rows
+func statementTx(balances db.StatementBalances, rows []db.ListStatementLinesRow) func(context.Context, db.ListStatementLinesParams, func(db.StatementBalances) error, func(db.ListStatementLinesRow) error) error {
+	return func(_ context.Context, _ db.ListStatementLinesParams, begin func(db.StatementBalances) error, fn func(db.ListStatementLinesRow) error) error {
+		if err := begin(balances); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestListAccountEntriesAPI(t *testing.T) {
+	account := RandomAccount()
+	entries := []db.Entry{
+		{ID: 1, AccountID: account.ID, Amount: 10, TransferID: sql.NullInt64{Int64: 3, Valid: true}},
+		{ID: 2, AccountID: account.ID, Amount: -5},
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: account.Owner,
+			role:     utils.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListEntries(gomock.Any(), gomock.Eq(db.ListEntriesParams{
+					AccountID: account.ID,
+					AfterID:   0,
+					Limit:     6,
+				})).Times(1).Return(entries, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var page Page[struct {
+					Amount     string `json:"amount"`
+					TransferID *int64 `json:"transfer_id"`
+				}]
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &page))
+				got := page.Items
+				require.Len(t, got, 2)
+				require.Equal(t, utils.NewMoney(10, account.Currency).String(), got[0].Amount)
+				require.Equal(t, int64(3), *got[0].TransferID)
+				require.Nil(t, got[1].TransferID)
+			},
+		},
+		{
+			name:     "Banker",
+			username: utils.RandomOwner(),
+			role:     utils.BankerRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListEntries(gomock.Any(), gomock.Any()).Times(1).Return(entries, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "NotOwner",
+			username: utils.RandomOwner(),
+			role:     utils.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().ListEntries(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%d/entries?page_size=5", account.ID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
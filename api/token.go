@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minhdang2803/simple_bank/token"
+)
+
+type RenewAccessTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type RenewAccessTokenResponse struct {
+	AccessToken          string    `json:"access_token"`
+	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+}
+
+func (server *Server) RenewAccessToken(ctx *gin.Context) {
+	var request RenewAccessTokenRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	refreshPayload, err := server.tokenMaker.VerifyToken(request.RefreshToken, token.TokenTypeRefresh)
+	if err != nil {
+		abortWithError(ctx, errUnauthorized(err.Error()))
+		return
+	}
+
+	session, err := server.store.GetSession(ctx, refreshPayload.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	if session.IsBlocked {
+		abortWithError(ctx, errUnauthorized("blocked session"))
+		return
+	}
+
+	if session.Username != refreshPayload.Username {
+		abortWithError(ctx, errUnauthorized("incorrect session user"))
+		return
+	}
+
+	if session.RefreshToken != request.RefreshToken {
+		abortWithError(ctx, errUnauthorized("mismatched session token"))
+		return
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		abortWithError(ctx, errUnauthorized("expired session"))
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(refreshPayload.Username, refreshPayload.Role, token.TokenTypeAccess, server.config.AccessTokenDuration)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	response := RenewAccessTokenResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessPayload.ExpiredAt,
+	}
+	ctx.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/token"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenewAccessTokenAPI(t *testing.T) {
+	username := utils.RandomOwner()
+
+	testCases := []struct {
+		name          string
+		session       func(refreshToken string, payload *token.Payload) db.Session
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			session: func(refreshToken string, payload *token.Payload) db.Session {
+				return randomSession(refreshToken, payload)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response RenewAccessTokenResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				require.NoError(t, err)
+				require.NotEmpty(t, response.AccessToken)
+			},
+		},
+		{
+			name: "BlockedSession",
+			session: func(refreshToken string, payload *token.Payload) db.Session {
+				session := randomSession(refreshToken, payload)
+				session.IsBlocked = true
+				return session
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "IncorrectSessionUser",
+			session: func(refreshToken string, payload *token.Payload) db.Session {
+				session := randomSession(refreshToken, payload)
+				session.Username = "someone"
+				return session
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "MismatchedSessionToken",
+			session: func(refreshToken string, payload *token.Payload) db.Session {
+				session := randomSession(refreshToken, payload)
+				session.RefreshToken = "mismatched"
+				return session
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "ExpiredSession",
+			session: func(refreshToken string, payload *token.Payload) db.Session {
+				session := randomSession(refreshToken, payload)
+				session.ExpiresAt = time.Now().Add(-time.Minute)
+				return session
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			server := newTestServer(t, store)
+			refreshToken, payload, err := server.tokenMaker.CreateToken(username, utils.DepositorRole, token.TokenTypeRefresh, time.Hour)
+			require.NoError(t, err)
+
+			store.EXPECT().
+				GetSession(gomock.Any(), gomock.Eq(payload.ID)).
+				Times(1).Return(tc.session(refreshToken, payload), nil)
+
+			data, err := json.Marshal(gin.H{"refresh_token": refreshToken})
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/tokens/renew_access", bytes.NewReader(data))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRenewAccessTokenSessionNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	server := newTestServer(t, store)
+	refreshToken, _, err := server.tokenMaker.CreateToken(utils.RandomOwner(), utils.DepositorRole, token.TokenTypeRefresh, time.Hour)
+	require.NoError(t, err)
+
+	store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(1).Return(db.Session{}, sql.ErrNoRows)
+
+	data, err := json.Marshal(gin.H{"refresh_token": refreshToken})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/tokens/renew_access", bytes.NewReader(data))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestRenewAccessTokenWithAccessToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
+
+	server := newTestServer(t, store)
+	accessToken, _, err := server.tokenMaker.CreateToken(utils.RandomOwner(), utils.DepositorRole, token.TokenTypeAccess, time.Hour)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(gin.H{"refresh_token": accessToken})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/tokens/renew_access", bytes.NewReader(data))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+// TestRevokedSessionRefreshToken makes sure that once a session is revoked
+// its refresh token opens nothing: it isn't an access token and can't be
+// renewed anymore
+func TestRevokedSessionRefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	server := newTestServer(t, store)
+	username := utils.RandomOwner()
+	refreshToken, payload, err := server.tokenMaker.CreateToken(username, utils.DepositorRole, token.TokenTypeRefresh, time.Hour)
+	require.NoError(t, err)
+
+	session := randomSession(refreshToken, payload)
+	store.EXPECT().BlockUserSessions(gomock.Any(), gomock.Eq(username)).Times(1).Return(nil)
+	store.EXPECT().ListAccountsByOwner(gomock.Any(), gomock.Any()).Times(0)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/sessions/revoke_all", nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, username, utils.DepositorRole, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	session.IsBlocked = true
+
+	// used as a bearer token on a protected route
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, "/accounts", nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, authorizationTypeBearer+" "+refreshToken)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	// used to renew an access token
+	store.EXPECT().GetSession(gomock.Any(), gomock.Eq(payload.ID)).Times(1).Return(session, nil)
+	data, err := json.Marshal(gin.H{"refresh_token": refreshToken})
+	require.NoError(t, err)
+
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodPost, "/tokens/renew_access", bytes.NewReader(data))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func randomSession(refreshToken string, payload *token.Payload) db.Session {
+	return db.Session{
+		ID:           payload.ID,
+		Username:     payload.Username,
+		RefreshToken: refreshToken,
+		ExpiresAt:    payload.ExpiredAt,
+	}
+}
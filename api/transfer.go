@@ -2,63 +2,457 @@ package api
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/token"
+	"github.com/minhdang2803/simple_bank/utils"
 )
 
 type TransferRequest struct {
-	FromAcountID int64  `json:"from_account_id" binding:"required,min=1"`
-	ToAcountID   int64  `json:"to_account_id" binding:"required,min=1"`
-	Amount       int64  `json:"amount" binding:"required,gt=0"`
-	Currency     string `json:"currency" binding:"required,currency"`
+	FromAcountID int64 `json:"from_account_id" binding:"required,min=1"`
+	ToAcountID   int64 `json:"to_account_id" binding:"required,min=1"`
+	// Amount is a decimal string in Currency such as "12.50"
+	Amount   string `json:"amount" binding:"required"`
+	Currency string `json:"currency" binding:"required,currency"`
+}
+
+type TransferResponse struct {
+	ID            int64       `json:"id"`
+	FromAccountID int64       `json:"from_account_id"`
+	ToAccountID   int64       `json:"to_account_id"`
+	Amount        utils.Money `json:"amount"`
+	FromCurrency  string      `json:"from_currency"`
+	ToAmount      utils.Money `json:"to_amount"`
+	ToCurrency    string      `json:"to_currency"`
+	ExchangeRate  string      `json:"exchange_rate"`
+	ReversalOf    *int64      `json:"reversal_of,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+type EntryResponse struct {
+	ID         int64       `json:"id"`
+	AccountID  int64       `json:"account_id"`
+	Amount     utils.Money `json:"amount"`
+	TransferID *int64      `json:"transfer_id,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+type TransferTxResponse struct {
+	Transfer    TransferResponse `json:"transfer"`
+	FromAccount AccountResponse  `json:"from_account"`
+	ToAccount   AccountResponse  `json:"to_account"`
+	FromEntry   EntryResponse    `json:"from_entry"`
+	ToEntry     EntryResponse    `json:"to_entry"`
+}
+
+func newTransferResponse(transfer db.Transfer) TransferResponse {
+	return TransferResponse{
+		ID:            transfer.ID,
+		FromAccountID: transfer.FromAccountID,
+		ToAccountID:   transfer.ToAccountID,
+		Amount:        utils.NewMoney(transfer.Amount, transfer.FromCurrency),
+		FromCurrency:  transfer.FromCurrency,
+		ToAmount:      utils.NewMoney(transfer.ToAmount, transfer.ToCurrency),
+		ToCurrency:    transfer.ToCurrency,
+		ExchangeRate:  transfer.ExchangeRate,
+		ReversalOf:    nullInt64(transfer.ReversalOf),
+		CreatedAt:     transfer.CreatedAt,
+	}
+}
+
+// newEntryResponse needs the currency of the entry's account, entries don't store it
+func newEntryResponse(entry db.Entry, currency string) EntryResponse {
+	return EntryResponse{
+		ID:         entry.ID,
+		AccountID:  entry.AccountID,
+		Amount:     utils.NewMoney(entry.Amount, currency),
+		TransferID: nullInt64(entry.TransferID),
+		CreatedAt:  entry.CreatedAt,
+	}
+}
+
+func nullInt64(value sql.NullInt64) *int64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Int64
+}
+
+func newTransferTxResponse(result db.TransferTxResult) TransferTxResponse {
+	return TransferTxResponse{
+		Transfer:    newTransferResponse(result.Transfer),
+		FromAccount: newAccountResponse(result.FromAccount),
+		ToAccount:   newAccountResponse(result.ToAccount),
+		FromEntry:   newEntryResponse(result.FromEntry, result.Transfer.FromCurrency),
+		ToEntry:     newEntryResponse(result.ToEntry, result.Transfer.ToCurrency),
+	}
+}
+
+// replayTransfer renders the transfer stored with an idempotency key
+func replayTransfer(body []byte) (interface{}, error) {
+	var result db.TransferTxResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return newTransferTxResponse(result), nil
 }
 
 func (server *Server) CreateTransfer(ctx *gin.Context) {
 	var request TransferRequest
 	err := ctx.ShouldBindJSON(&request)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	amount, err := utils.ParseMoney(request.Amount, request.Currency)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	if amount.Amount <= 0 {
+		abortWithError(ctx, errBadRequest("amount must be positive"))
 		return
 	}
-	if !server.validAccount(ctx, request.FromAcountID, request.Currency) {
+	if request.FromAcountID == request.ToAcountID {
+		abortWithError(ctx, errBadRequest("cannot transfer to the same account"))
 		return
 	}
 
-	if !server.validAccount(ctx, request.ToAcountID, request.Currency) {
+	idempotency, proceed := server.checkIdempotency(ctx, request, replayTransfer)
+	if !proceed {
+		return
+	}
+	defer server.saveIdempotentError(ctx, idempotency)
+
+	fromAccount, valid := server.validAccount(ctx, request.FromAcountID, request.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if fromAccount.Owner != authPayload.Username {
+		abortWithError(ctx, errForbidden("from account doesn't belong to the authenticated user"))
+		return
+	}
+
+	// the destination may hold any supported currency, it is credited after conversion
+	toAccount, err := server.store.GetAccount(ctx, request.ToAcountID)
+	if err != nil {
+		abortWithError(ctx, err)
 		return
 	}
 
 	arg := db.TransferTxParams{
 		FromAccountID: request.FromAcountID,
 		ToAccountID:   request.ToAcountID,
-		Amount:        request.Amount,
+		Amount:        amount.Amount,
+		Idempotency:   idempotency,
+	}
+	if toAccount.Currency != fromAccount.Currency {
+		rate, err := server.rates.Rate(ctx, fromAccount.Currency, toAccount.Currency)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		arg.ToAmount, err = rate.Convert(amount.Amount)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		arg.ExchangeRate = rate.String()
+		if arg.ToAmount <= 0 {
+			abortWithError(ctx, errBadRequest("amount is too small to be converted"))
+			return
+		}
 	}
 
 	result, err := server.store.TransferTx(ctx, arg)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		if isIdempotencyConflict(err) {
+			server.replayConcurrent(ctx, idempotency, replayTransfer)
+			return
+		}
+		abortWithError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, result)
+	ctx.JSON(http.StatusOK, newTransferTxResponse(result))
 }
 
-func (server *Server) validAccount(ctx *gin.Context, accountID int64, currency string) bool {
+func (server *Server) validAccount(ctx *gin.Context, accountID int64, currency string) (db.Account, bool) {
 	account, err := server.store.GetAccount(ctx, accountID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return false
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return false
+		abortWithError(ctx, err)
+		return account, false
 	}
 	if account.Currency != currency {
-		err := fmt.Errorf("account [%d] currency mismatch %s vs %s", accountID, account.Currency, currency)
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return false
+		abortWithError(ctx, errBadRequest(fmt.Sprintf("account [%d] currency mismatch %s vs %s", accountID, account.Currency, currency)))
+		return account, false
+	}
+	return account, true
+}
+
+type GetTransferRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// ReverseTransferRequest takes back all or part of a transfer. Amount is a
+// decimal string in the currency the recipient was credited in, it defaults
+// to whatever hasn't been reversed yet.
+type ReverseTransferRequest struct {
+	TransferID int64  `json:"transfer_id"`
+	Amount     string `json:"amount"`
+}
+
+// ReverseTransfer books a compensating transfer from the recipient back to
+// the sender. Only the recipient, a banker or an admin can reverse a transfer.
+func (server *Server) ReverseTransfer(ctx *gin.Context) {
+	var uri GetTransferRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	var request ReverseTransferRequest
+	// the body is optional, an empty one reverses the whole transfer
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			abortWithError(ctx, errInvalidRequest(err))
+			return
+		}
+	}
+	// the id is part of the fingerprint of the idempotency key
+	request.TransferID = uri.ID
+
+	transfer, err := server.store.GetTransfer(ctx, uri.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	arg := db.ReverseTransferTxParams{TransferID: transfer.ID}
+	if request.Amount != "" {
+		amount, err := utils.ParseMoney(request.Amount, transfer.ToCurrency)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		if amount.Amount <= 0 {
+			abortWithError(ctx, errBadRequest("amount must be positive"))
+			return
+		}
+		arg.Amount = amount.Amount
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !hasRole(authPayload, utils.BankerRole, utils.AdminRole) {
+		owns, err := server.ownsAnyAccount(ctx, authPayload.Username, transfer.ToAccountID)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		if !owns {
+			abortWithError(ctx, errForbidden("only the recipient of a transfer can reverse it"))
+			return
+		}
+	}
+
+	idempotency, proceed := server.checkIdempotency(ctx, request, replayTransfer)
+	if !proceed {
+		return
+	}
+	defer server.saveIdempotentError(ctx, idempotency)
+	arg.Idempotency = idempotency
+
+	result, err := server.store.ReverseTransferTx(ctx, arg)
+	if err != nil {
+		if isIdempotencyConflict(err) {
+			server.replayConcurrent(ctx, idempotency, replayTransfer)
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newTransferTxResponse(result))
+}
+
+// GetTransfer returns a transfer touching one of the caller's accounts
+func (server *Server) GetTransfer(ctx *gin.Context) {
+	var request GetTransferRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	transfer, err := server.store.GetTransfer(ctx, request.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !hasRole(authPayload, utils.BankerRole, utils.AdminRole) {
+		owns, err := server.ownsAnyAccount(ctx, authPayload.Username, transfer.FromAccountID, transfer.ToAccountID)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		if !owns {
+			abortWithError(ctx, errForbidden("transfer doesn't involve an account of the authenticated user"))
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, newTransferResponse(transfer))
+}
+
+func (server *Server) ownsAnyAccount(ctx *gin.Context, owner string, accountIDs ...int64) (bool, error) {
+	for _, id := range accountIDs {
+		account, err := server.store.GetAccount(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		if account.Owner == owner {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// directionBoth is the default direction filter, transfers in and out of the account
+const directionBoth = "both"
+
+// ListTransfersRequest filters the transfer history of an account. Amounts
+// are decimal strings in the account's currency, dates are whole days in UTC
+// with both ends included. Transfers come newest first, see PageRequest.
+type ListTransfersRequest struct {
+	PageRequest
+	Direction      string    `form:"direction" binding:"omitempty,oneof=incoming outgoing both"`
+	MinAmount      string    `form:"min_amount"`
+	MaxAmount      string    `form:"max_amount"`
+	From           time.Time `form:"from" time_format:"2006-01-02" time_utc:"1"`
+	To             time.Time `form:"to" time_format:"2006-01-02" time_utc:"1"`
+	CounterpartyID int64     `form:"counterparty_id" binding:"omitempty,min=1"`
+}
+
+// filters converts the request into the filters shared by both list queries
+func (request ListTransfersRequest) filters(account db.Account) (db.ListTransfersParams, error) {
+	arg := db.ListTransfersParams{
+		Direction: request.Direction,
+		AccountID: account.ID,
+	}
+	if arg.Direction == "" {
+		arg.Direction = directionBoth
+	}
+	if request.CounterpartyID != 0 {
+		arg.CounterpartyID = sql.NullInt64{Int64: request.CounterpartyID, Valid: true}
+	}
+
+	if request.MinAmount != "" {
+		amount, err := utils.ParseMoney(request.MinAmount, account.Currency)
+		if err != nil {
+			return arg, err
+		}
+		arg.MinAmount = sql.NullInt64{Int64: amount.Amount, Valid: true}
+	}
+	if request.MaxAmount != "" {
+		amount, err := utils.ParseMoney(request.MaxAmount, account.Currency)
+		if err != nil {
+			return arg, err
+		}
+		arg.MaxAmount = sql.NullInt64{Int64: amount.Amount, Valid: true}
+	}
+	if arg.MinAmount.Valid && arg.MaxAmount.Valid && arg.MinAmount.Int64 > arg.MaxAmount.Int64 {
+		return arg, errBadRequest("min_amount must not be above max_amount")
+	}
+
+	if !request.From.IsZero() {
+		arg.FromTime = sql.NullTime{Time: request.From, Valid: true}
+	}
+	if !request.To.IsZero() {
+		arg.ToTime = sql.NullTime{Time: request.To.AddDate(0, 0, 1), Valid: true}
+	}
+	if arg.FromTime.Valid && arg.ToTime.Valid && request.To.Before(request.From) {
+		return arg, errBadRequest("to must not be before from")
+	}
+	return arg, nil
+}
+
+// ListAccountTransfers returns the transfers of an account, newest first
+func (server *Server) ListAccountTransfers(ctx *gin.Context) {
+	var uri GetAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	var request ListTransfersRequest
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	page, err := server.newPageQuery(request.PageRequest)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	account, ok := server.readableAccount(ctx, uri.Id)
+	if !ok {
+		return
+	}
+
+	arg, err := request.filters(account)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	// the history is walked from the newest transfer, so a forward cursor
+	// moves towards smaller ids and a backward one towards larger ids
+	var transfers []db.Transfer
+	if page.Backward {
+		transfers, err = server.store.ListTransfersAfter(ctx, db.ListTransfersAfterParams{
+			Direction:      arg.Direction,
+			AccountID:      arg.AccountID,
+			CounterpartyID: arg.CounterpartyID,
+			MinAmount:      arg.MinAmount,
+			MaxAmount:      arg.MaxAmount,
+			FromTime:       arg.FromTime,
+			ToTime:         arg.ToTime,
+			AfterID:        page.ID,
+			Limit:          page.limit(),
+		})
+	} else {
+		arg.BeforeID = page.ID
+		if arg.BeforeID == 0 {
+			arg.BeforeID = math.MaxInt64
+		}
+		arg.Limit = page.limit()
+		transfers, err = server.store.ListTransfers(ctx, arg)
+	}
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	transfers, next, prev := paginate(page, transfers, func(transfer db.Transfer) int64 {
+		return transfer.ID
+	})
+	response := Page[TransferResponse]{
+		Items:      make([]TransferResponse, 0, len(transfers)),
+		NextCursor: next,
+		PrevCursor: prev,
+	}
+	for _, transfer := range transfers {
+		response.Items = append(response.Items, newTransferResponse(transfer))
 	}
-	return true
+	ctx.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/fx"
+	"github.com/minhdang2803/simple_bank/token"
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+// TransferBatchRequest sends up to 500 transfers from the accounts of the
+// authenticated user in one call. In atomic mode all of them are booked or
+// none, in best_effort mode each item reports whether it was booked. An
+// invalid item, such as an account that doesn't exist, rejects the whole
+// request in both modes.
+type TransferBatchRequest struct {
+	Mode      string            `json:"mode" binding:"required,oneof=atomic best_effort"`
+	Transfers []TransferRequest `json:"transfers" binding:"required,min=1,max=500,dive"`
+}
+
+type TransferBatchItemResponse struct {
+	Position      int32       `json:"position"`
+	FromAccountID int64       `json:"from_account_id"`
+	ToAccountID   int64       `json:"to_account_id"`
+	Amount        utils.Money `json:"amount"`
+	Currency      string      `json:"currency"`
+	Status        string      `json:"status"`
+	TransferID    *int64      `json:"transfer_id,omitempty"`
+	Error         string      `json:"error,omitempty"`
+}
+
+type TransferBatchResponse struct {
+	ID          int64                       `json:"id"`
+	Mode        string                      `json:"mode"`
+	Status      string                      `json:"status"`
+	ItemCount   int32                       `json:"item_count"`
+	FailedCount int32                       `json:"failed_count"`
+	Items       []TransferBatchItemResponse `json:"items"`
+	CreatedAt   time.Time                   `json:"created_at"`
+}
+
+func newTransferBatchResponse(batch db.TransferBatch, items []db.TransferBatchItem) TransferBatchResponse {
+	response := TransferBatchResponse{
+		ID:          batch.ID,
+		Mode:        batch.Mode,
+		Status:      batch.Status,
+		ItemCount:   batch.ItemCount,
+		FailedCount: batch.FailedCount,
+		Items:       make([]TransferBatchItemResponse, 0, len(items)),
+		CreatedAt:   batch.CreatedAt,
+	}
+	for _, item := range items {
+		response.Items = append(response.Items, TransferBatchItemResponse{
+			Position:      item.Position,
+			FromAccountID: item.FromAccountID,
+			ToAccountID:   item.ToAccountID,
+			Amount:        utils.NewMoney(item.Amount, item.Currency),
+			Currency:      item.Currency,
+			Status:        item.Status,
+			TransferID:    nullInt64(item.TransferID),
+			Error:         item.Error,
+		})
+	}
+	return response
+}
+
+// replayTransferBatch renders the batch stored with an idempotency key
+func replayTransferBatch(body []byte) (interface{}, error) {
+	var result db.TransferBatchTxResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return newTransferBatchResponse(result.Batch, result.Items), nil
+}
+
+// CreateTransferBatch books a batch of transfers, see TransferBatchRequest
+func (server *Server) CreateTransferBatch(ctx *gin.Context) {
+	var request TransferBatchRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	amounts := make([]int64, len(request.Transfers))
+	for i, transfer := range request.Transfers {
+		amount, err := utils.ParseMoney(transfer.Amount, transfer.Currency)
+		if err != nil {
+			abortWithError(ctx, fmt.Errorf("transfers[%d]: %w", i, err))
+			return
+		}
+		if amount.Amount <= 0 {
+			abortWithError(ctx, errBadRequest(fmt.Sprintf("transfers[%d]: amount must be positive", i)))
+			return
+		}
+		if transfer.FromAcountID == transfer.ToAcountID {
+			abortWithError(ctx, errBadRequest(fmt.Sprintf("transfers[%d]: cannot transfer to the same account", i)))
+			return
+		}
+		amounts[i] = amount.Amount
+	}
+
+	idempotency, proceed := server.checkIdempotency(ctx, request, replayTransferBatch)
+	if !proceed {
+		return
+	}
+	defer server.saveIdempotentError(ctx, idempotency)
+
+	// a payroll sends from one account to many, each account and rate is looked up once
+	accounts := map[int64]db.Account{}
+	account := func(id int64) (db.Account, error) {
+		if account, ok := accounts[id]; ok {
+			return account, nil
+		}
+		account, err := server.store.GetAccount(ctx, id)
+		if err == nil {
+			accounts[id] = account
+		}
+		return account, err
+	}
+	rates := map[string]fx.Rate{}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	arg := db.TransferBatchTxParams{
+		Owner:       authPayload.Username,
+		Mode:        request.Mode,
+		Transfers:   make([]db.TransferTxParams, 0, len(request.Transfers)),
+		Idempotency: idempotency,
+	}
+	for i, transfer := range request.Transfers {
+		fromAccount, err := account(transfer.FromAcountID)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		if fromAccount.Owner != authPayload.Username {
+			abortWithError(ctx, errForbidden(fmt.Sprintf("transfers[%d]: from account doesn't belong to the authenticated user", i)))
+			return
+		}
+		if fromAccount.Currency != transfer.Currency {
+			abortWithError(ctx, errBadRequest(fmt.Sprintf("transfers[%d]: account [%d] currency mismatch %s vs %s",
+				i, fromAccount.ID, fromAccount.Currency, transfer.Currency)))
+			return
+		}
+
+		toAccount, err := account(transfer.ToAcountID)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+
+		params := db.TransferTxParams{
+			FromAccountID: fromAccount.ID,
+			ToAccountID:   toAccount.ID,
+			Amount:        amounts[i],
+		}
+		if toAccount.Currency != fromAccount.Currency {
+			pair := fromAccount.Currency + "/" + toAccount.Currency
+			rate, ok := rates[pair]
+			if !ok {
+				rate, err = server.rates.Rate(ctx, fromAccount.Currency, toAccount.Currency)
+				if err != nil {
+					abortWithError(ctx, err)
+					return
+				}
+				rates[pair] = rate
+			}
+			params.ToAmount, err = rate.Convert(params.Amount)
+			if err != nil {
+				abortWithError(ctx, fmt.Errorf("transfers[%d]: %w", i, err))
+				return
+			}
+			params.ExchangeRate = rate.String()
+			if params.ToAmount <= 0 {
+				abortWithError(ctx, errBadRequest(fmt.Sprintf("transfers[%d]: amount is too small to be converted", i)))
+				return
+			}
+		}
+		arg.Transfers = append(arg.Transfers, params)
+	}
+
+	result, err := server.store.TransferBatchTx(ctx, arg)
+	if err != nil {
+		if isIdempotencyConflict(err) {
+			server.replayConcurrent(ctx, idempotency, replayTransferBatch)
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newTransferBatchResponse(result.Batch, result.Items))
+}
+
+// GetTransferBatch returns a batch of the authenticated user with the
+// status of each item
+func (server *Server) GetTransferBatch(ctx *gin.Context) {
+	var request GetTransferRequest
+	if err := ctx.ShouldBindUri(&request); err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	batch, err := server.store.GetTransferBatch(ctx, request.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if batch.Owner != authPayload.Username && !hasRole(authPayload, utils.BankerRole, utils.AdminRole) {
+		abortWithError(ctx, errForbidden("batch doesn't belong to the authenticated user"))
+		return
+	}
+
+	items, err := server.store.ListTransferBatchItems(ctx, batch.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, newTransferBatchResponse(batch, items))
+}
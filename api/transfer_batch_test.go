@@ -0,0 +1,233 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateTransferBatchAPI(t *testing.T) {
+	payer := RandomAccount()
+	payee1 := RandomAccount()
+	payee2 := RandomAccount()
+	payer.Currency = utils.USD
+	payee1.Currency = utils.USD
+	payee2.Currency = utils.EUR
+	payer.ID, payee1.ID, payee2.ID = 1, 2, 3
+
+	transfers := []gin.H{
+		{"from_account_id": payer.ID, "to_account_id": payee1.ID, "amount": "10.00", "currency": utils.USD},
+		{"from_account_id": payer.ID, "to_account_id": payee2.ID, "amount": "20.00", "currency": utils.USD},
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Best effort",
+			body:     gin.H{"mode": db.BatchBestEffort, "transfers": transfers},
+			username: payer.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				// the payer is looked up once for both items
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(payer.ID)).Times(1).Return(payer, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(payee1.ID)).Times(1).Return(payee1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(payee2.ID)).Times(1).Return(payee2, nil)
+				store.EXPECT().TransferBatchTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, arg db.TransferBatchTxParams) (db.TransferBatchTxResult, error) {
+						require.Equal(t, payer.Owner, arg.Owner)
+						require.Equal(t, db.BatchBestEffort, arg.Mode)
+						require.Len(t, arg.Transfers, 2)
+						require.Equal(t, int64(1000), arg.Transfers[0].Amount)
+						require.Zero(t, arg.Transfers[0].ToAmount)
+						require.Equal(t, int64(2000), arg.Transfers[1].Amount)
+						require.Positive(t, arg.Transfers[1].ToAmount)
+						require.NotEmpty(t, arg.Transfers[1].ExchangeRate)
+						return db.TransferBatchTxResult{
+							Batch: db.TransferBatch{ID: 1, Owner: arg.Owner, Mode: arg.Mode, Status: db.TransferBatchPartial, ItemCount: 2, FailedCount: 1},
+							Items: []db.TransferBatchItem{
+								{Position: 0, FromAccountID: payer.ID, ToAccountID: payee1.ID, Amount: 1000, Currency: utils.USD,
+									Status: db.TransferBatchItemCompleted, TransferID: sql.NullInt64{Int64: 7, Valid: true}},
+								{Position: 1, FromAccountID: payer.ID, ToAccountID: payee2.ID, Amount: 2000, Currency: utils.USD,
+									Status: db.TransferBatchItemFailed, Error: db.ErrInsufficientFunds.Error()},
+							},
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got struct {
+					Status string `json:"status"`
+					Items  []struct {
+						Status     string `json:"status"`
+						TransferID *int64 `json:"transfer_id"`
+						Error      string `json:"error"`
+					} `json:"items"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, db.TransferBatchPartial, got.Status)
+				require.Len(t, got.Items, 2)
+				require.Equal(t, int64(7), *got.Items[0].TransferID)
+				require.Nil(t, got.Items[1].TransferID)
+				require.Equal(t, db.ErrInsufficientFunds.Error(), got.Items[1].Error)
+			},
+		},
+		{
+			name:     "Atomic batch fails",
+			body:     gin.H{"mode": db.BatchAtomic, "transfers": transfers[:1]},
+			username: payer.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(payer.ID)).Times(1).Return(payer, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(payee1.ID)).Times(1).Return(payee1, nil)
+				store.EXPECT().TransferBatchTx(gomock.Any(), gomock.Any()).Times(1).
+					Return(db.TransferBatchTxResult{}, fmt.Errorf("transfer 0: %w", db.ErrInsufficientFunds))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			name:     "Invalid mode",
+			body:     gin.H{"mode": "eventually", "transfers": transfers},
+			username: payer.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().TransferBatchTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Invalid item",
+			body: gin.H{"mode": db.BatchAtomic, "transfers": []gin.H{
+				transfers[0],
+				{"from_account_id": payer.ID, "to_account_id": payee1.ID, "amount": "-1.00", "currency": utils.USD},
+			}},
+			username: payer.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().TransferBatchTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "transfers[1]")
+			},
+		},
+		{
+			name:     "Not the owner",
+			body:     gin.H{"mode": db.BatchAtomic, "transfers": transfers},
+			username: payee1.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(payer.ID)).Times(1).Return(payer, nil)
+				store.EXPECT().TransferBatchTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers/batch", bytes.NewReader(data))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/json")
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, utils.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetTransferBatchAPI(t *testing.T) {
+	batch := db.TransferBatch{ID: 4, Owner: utils.RandomOwner(), Mode: db.BatchAtomic, Status: db.TransferBatchCompleted, ItemCount: 1}
+	items := []db.TransferBatchItem{
+		{BatchID: batch.ID, FromAccountID: 1, ToAccountID: 2, Amount: 500, Currency: utils.USD,
+			Status: db.TransferBatchItemCompleted, TransferID: sql.NullInt64{Int64: 9, Valid: true}},
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: batch.Owner,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferBatch(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(batch, nil)
+				store.EXPECT().ListTransferBatchItems(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(items, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `"amount":"5.00"`)
+				require.Contains(t, recorder.Body.String(), `"transfer_id":9`)
+			},
+		},
+		{
+			name:     "Not the owner",
+			username: "someone",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetTransferBatch(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(batch, nil)
+				store.EXPECT().ListTransferBatchItems(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/transfers/batch/%d", batch.ID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, utils.DepositorRole, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -1,13 +1,17 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
+	"github.com/google/uuid"
 	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/metrics"
+	"github.com/minhdang2803/simple_bank/token"
 	"github.com/minhdang2803/simple_bank/utils"
 )
 
@@ -20,12 +24,24 @@ type CreateUserParams struct {
 	Email    string `json:"email" binding:"required,email"`
 }
 
-type CreateUserResponse struct {
+type UserResponse struct {
 	Username          string     `json:"username"`
 	FullName          string     `json:"fullname"`
 	Email             string     `json:"email"`
+	Role              string     `json:"role"`
 	PasswordChangedAt *time.Time `json:"password_changed_at"`
-	CreateAt          *time.Time `json: "create_at"`
+	CreateAt          *time.Time `json:"create_at"`
+}
+
+func newUserResponse(user db.User) UserResponse {
+	return UserResponse{
+		Username:          user.Username,
+		FullName:          user.FullName,
+		Email:             user.Email,
+		Role:              user.Role,
+		PasswordChangedAt: &(user.PasswordChangedAt),
+		CreateAt:          &(user.CreatedAt),
+	}
 }
 
 func (server *Server) CreateUser(ctx *gin.Context) {
@@ -34,13 +50,14 @@ func (server *Server) CreateUser(ctx *gin.Context) {
 
 	err := ctx.ShouldBindBodyWithJSON(&request)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, errInvalidRequest(err))
 		return
 	}
 
 	hashedPassword, err := utils.HashedPassword(request.Password)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
+		return
 	}
 	user, err := server.store.CreateUser(ctx, db.CreateUserParams{
 		Username:       request.Username,
@@ -50,24 +67,100 @@ func (server *Server) CreateUser(ctx *gin.Context) {
 	})
 
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code.Name() {
-			case "unique_violation":
-				ctx.JSON(http.StatusForbidden, errorResponse(err))
-				return
-			}
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
+
+var errIncorrectCredentials = errUnauthorized("incorrect username or password")
+
+// unknownUserPasswordHash is checked against when the username doesn't
+// exist, so that the login takes as long as with a wrong password
+var unknownUserPasswordHash = sync.OnceValue(func() string {
+	hash, _ := utils.HashedPassword(utils.RandomString(16))
+	return hash
+})
+
+type LoginUserRequest struct {
+	Username string `json:"username" binding:"required,alphanum"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type LoginUserResponse struct {
+	SessionID             uuid.UUID    `json:"session_id"`
+	AccessToken           string       `json:"access_token"`
+	AccessTokenExpiresAt  time.Time    `json:"access_token_expires_at"`
+	RefreshToken          string       `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+	User                  UserResponse `json:"user"`
+}
+
+func (server *Server) LoginUser(ctx *gin.Context) {
+	var request LoginUserRequest
+
+	err := ctx.ShouldBindJSON(&request)
+	if err != nil {
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, request.Username)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			// same answer, and about the same time, as a wrong password so
+			// that logins can't tell which usernames exist
+			utils.CheckPassword(request.Password, unknownUserPasswordHash())
+			server.metrics.LoginFailed(metrics.LoginUnknownUser)
+			abortWithError(ctx, errIncorrectCredentials)
+			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
-	response := CreateUserResponse{
-		Username:          user.Username,
-		FullName:          user.FullName,
-		Email:             user.Email,
-		PasswordChangedAt: &(user.PasswordChangedAt),
-		CreateAt:          &(user.CreatedAt),
+
+	err = utils.CheckPassword(request.Password, user.HashedPassword)
+	if err != nil {
+		server.metrics.LoginFailed(metrics.LoginWrongPassword)
+		abortWithError(ctx, errIncorrectCredentials)
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(user.Username, user.Role, token.TokenTypeAccess, server.config.AccessTokenDuration)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(user.Username, user.Role, token.TokenTypeRefresh, server.config.RefreshTokenDuration)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
 	}
 
+	session, err := server.store.CreateSession(ctx, db.CreateSessionParams{
+		ID:           refreshPayload.ID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    ctx.Request.UserAgent(),
+		ClientIp:     ctx.ClientIP(),
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	response := LoginUserResponse{
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+		User:                  newUserResponse(user),
+	}
 	ctx.JSON(http.StatusOK, response)
 }
 
@@ -80,33 +173,50 @@ func (server *Server) GetUser(ctx *gin.Context) {
 
 	err := ctx.ShouldBindQuery(&request)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, errInvalidRequest(err))
+		return
+	}
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if request.Username != authPayload.Username {
+		abortWithError(ctx, errForbidden("cannot get other user's infomations"))
 		return
 	}
+
 	user, err := server.store.GetUser(ctx, request.Username)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, user)
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
+
+type UpdateUserRequest struct {
+	FullName string `json:"full_name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
 }
 
 func (server *Server) UpdateUser(ctx *gin.Context) {
-	var request *db.UpdateUserParams
+	var request *UpdateUserRequest
 
 	err := ctx.ShouldBindBodyWithJSON(&request)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(ctx, errInvalidRequest(err))
 		return
 	}
 
-	err = server.store.UpdateUser(ctx, *request)
+	// the username always comes from the token so users can only update themselves
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	err = server.store.UpdateUser(ctx, db.UpdateUserParams{
+		Username: authPayload.Username,
+		FullName: request.FullName,
+		Email:    request.Email,
+	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("Succesfully update %s infomations", request.Username),
+		"message": fmt.Sprintf("Succesfully update %s infomations", authPayload.Username),
 	})
 }
@@ -1,17 +1,42 @@
 package api
 
 import (
-	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/minhdang2803/simple_bank/utils"
 )
 
+// fieldName reports fields in validation errors by the name clients use
+// (json, query or uri key) instead of the Go struct field name
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// validatorCurrency accepts currencies registered in utils.Currencies, even
+// disabled ones, so that the accounts already held in them keep working
 var validatorCurrency validator.Func = func(fieldLevel validator.FieldLevel) bool {
-	if currenct, isOk := fieldLevel.Field().Interface().(string); isOk {
-		isSupport := utils.IsSupportedCurrency(currenct)
-		fmt.Print(isSupport)
-		return isSupport
+	if currency, isOk := fieldLevel.Field().Interface().(string); isOk {
+		_, known := utils.Currencies.Lookup(currency)
+		return known
+	}
+	return false
+}
+
+// validatorEnabledCurrency only accepts enabled currencies, for new accounts
+var validatorEnabledCurrency validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	if currency, isOk := fieldLevel.Field().Interface().(string); isOk {
+		return utils.IsSupportedCurrency(currency)
 	}
 	return false
 }
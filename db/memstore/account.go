@@ -0,0 +1,149 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+// checkAccount enforces the constraints of the accounts table on a new or
+// updated row
+func (t *tables) checkAccount(account db.Account) error {
+	return constraints(
+		checkConstraint(account.OverdraftLimit >= 0, "accounts", "accounts_overdraft_limit_check"),
+		checkConstraint(account.Balance >= -account.OverdraftLimit, "accounts", "accounts_balance_check"),
+		checkConstraint(account.Status == db.AccountActive || account.Status == db.AccountFrozen ||
+			account.Status == db.AccountClosed, "accounts", "accounts_status_check"),
+	)
+}
+
+func (t *tables) updateAccount(id int64, where func(db.Account) bool, change func(account *db.Account)) (db.Account, error) {
+	return update(t.accounts, id, where, func(account *db.Account) error {
+		change(account)
+		return t.checkAccount(*account)
+	})
+}
+
+func (q queries) CreateAccount(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
+	return query(q, func(t *tables, now time.Time) (db.Account, error) {
+		account := db.Account{
+			ID:        next(&t.sequences.accounts),
+			Owner:     arg.Owner,
+			Balance:   arg.Balance,
+			Currency:  arg.Currency,
+			CreatedAt: now,
+			Status:    db.AccountActive,
+		}
+
+		taken := false
+		for _, other := range t.accounts.rows {
+			if other.Owner == account.Owner && other.Currency == account.Currency {
+				taken = true
+				break
+			}
+		}
+		if err := constraints(
+			t.checkAccount(account),
+			uniqueConstraint(!taken, "accounts", "owner_current_key"),
+			foreignKey(t.users.has(account.Owner), "accounts", "accounts_owner_fkey"),
+			foreignKey(t.currencies.has(account.Currency), "accounts", "accounts_currency_fkey"),
+		); err != nil {
+			return db.Account{}, err
+		}
+
+		t.accounts.insert(account)
+		return account, nil
+	})
+}
+
+func (q queries) GetAccount(ctx context.Context, id int64) (db.Account, error) {
+	return query(q, func(t *tables, now time.Time) (db.Account, error) {
+		return found(t.accounts.get(id))
+	})
+}
+
+// GetAccountForUpdate needs no lock, the transaction has the backend to itself
+func (q queries) GetAccountForUpdate(ctx context.Context, id int64) (db.Account, error) {
+	return q.GetAccount(ctx, id)
+}
+
+func (q queries) GetAccountsForUpdate(ctx context.Context, ids []int64) ([]db.Account, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Account, error) {
+		wanted := make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			wanted[id] = true
+		}
+		return t.accounts.all(func(account db.Account) bool {
+			return wanted[account.ID]
+		}), nil
+	})
+}
+
+func (q queries) ListAccounts(ctx context.Context, arg db.ListAccountsParams) ([]db.Account, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Account, error) {
+		return t.accounts.after(arg.AfterID, arg.Limit, func(db.Account) bool { return true }), nil
+	})
+}
+
+func (q queries) ListAccountsBefore(ctx context.Context, arg db.ListAccountsBeforeParams) ([]db.Account, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Account, error) {
+		return t.accounts.before(arg.BeforeID, arg.Limit, func(db.Account) bool { return true }), nil
+	})
+}
+
+func (q queries) ListAccountsByOwner(ctx context.Context, arg db.ListAccountsByOwnerParams) ([]db.Account, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Account, error) {
+		return t.accounts.after(arg.AfterID, arg.Limit, func(account db.Account) bool {
+			return account.Owner == arg.Owner
+		}), nil
+	})
+}
+
+func (q queries) ListAccountsByOwnerBefore(ctx context.Context, arg db.ListAccountsByOwnerBeforeParams) ([]db.Account, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Account, error) {
+		return t.accounts.before(arg.BeforeID, arg.Limit, func(account db.Account) bool {
+			return account.Owner == arg.Owner
+		}), nil
+	})
+}
+
+func (q queries) AddAccountBalance(ctx context.Context, arg db.AddAccountBalanceParams) (db.Account, error) {
+	return query(q, func(t *tables, now time.Time) (db.Account, error) {
+		return t.updateAccount(arg.ID, nil, func(account *db.Account) {
+			account.Balance += arg.Amount
+		})
+	})
+}
+
+func (q queries) UpdateAccountOverdraftLimit(ctx context.Context, arg db.UpdateAccountOverdraftLimitParams) (db.Account, error) {
+	return query(q, func(t *tables, now time.Time) (db.Account, error) {
+		return t.updateAccount(arg.ID, nil, func(account *db.Account) {
+			account.OverdraftLimit = arg.OverdraftLimit
+		})
+	})
+}
+
+func (q queries) FreezeAccount(ctx context.Context, id int64) (db.Account, error) {
+	return q.setAccountStatus(id, db.AccountActive, db.AccountFrozen)
+}
+
+func (q queries) UnfreezeAccount(ctx context.Context, id int64) (db.Account, error) {
+	return q.setAccountStatus(id, db.AccountFrozen, db.AccountActive)
+}
+
+func (q queries) SetAccountClosed(ctx context.Context, id int64) (db.Account, error) {
+	return q.setAccountStatus(id, "", db.AccountClosed)
+}
+
+// setAccountStatus moves an account from one status to another, an empty
+// from matches any status
+func (q queries) setAccountStatus(id int64, from string, to string) (db.Account, error) {
+	return query(q, func(t *tables, now time.Time) (db.Account, error) {
+		return t.updateAccount(id, func(account db.Account) bool {
+			return from == "" || account.Status == from
+		}, func(account *db.Account) {
+			account.Status = to
+		})
+	})
+}
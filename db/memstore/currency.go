@@ -0,0 +1,42 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+func (q queries) GetCurrency(ctx context.Context, code string) (db.Currency, error) {
+	return query(q, func(t *tables, now time.Time) (db.Currency, error) {
+		return found(t.currencies.get(code))
+	})
+}
+
+func (q queries) ListCurrencies(ctx context.Context) ([]db.Currency, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Currency, error) {
+		currencies := make([]db.Currency, 0, len(t.currencies.rows))
+		for _, currency := range t.currencies.rows {
+			currencies = append(currencies, currency)
+		}
+		sort.Slice(currencies, func(i, j int) bool {
+			return currencies[i].Code < currencies[j].Code
+		})
+		return currencies, nil
+	})
+}
+
+func (q queries) UpdateCurrencyEnabled(ctx context.Context, arg db.UpdateCurrencyEnabledParams) (db.Currency, error) {
+	return query(q, func(t *tables, now time.Time) (db.Currency, error) {
+		currency, ok := t.currencies.get(arg.Code)
+		if !ok {
+			return db.Currency{}, sql.ErrNoRows
+		}
+
+		currency.Enabled = arg.Enabled
+		t.currencies.put(currency.Code, currency)
+		return currency, nil
+	})
+}
@@ -0,0 +1,130 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+func (q queries) CreateEntry(ctx context.Context, arg db.CreateEntryParams) (db.Entry, error) {
+	return query(q, func(t *tables, now time.Time) (db.Entry, error) {
+		entry := db.Entry{
+			ID:         next(&t.sequences.entries),
+			AccountID:  arg.AccountID,
+			Amount:     arg.Amount,
+			CreatedAt:  now,
+			TransferID: arg.TransferID,
+		}
+		if err := constraints(
+			foreignKey(t.accounts.has(entry.AccountID), "entries", "entries_account_id_fkey"),
+			foreignKey(!entry.TransferID.Valid || t.transfers.has(entry.TransferID.Int64), "entries", "entries_transfer_id_fkey"),
+		); err != nil {
+			return db.Entry{}, err
+		}
+
+		t.entries.insert(entry)
+		return entry, nil
+	})
+}
+
+func (q queries) GetEntry(ctx context.Context, id int64) (db.Entry, error) {
+	return query(q, func(t *tables, now time.Time) (db.Entry, error) {
+		return found(t.entries.get(id))
+	})
+}
+
+func (q queries) ListEntries(ctx context.Context, arg db.ListEntriesParams) ([]db.Entry, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Entry, error) {
+		return t.entries.after(arg.AfterID, arg.Limit, func(entry db.Entry) bool {
+			return entry.AccountID == arg.AccountID
+		}), nil
+	})
+}
+
+func (q queries) ListEntriesBefore(ctx context.Context, arg db.ListEntriesBeforeParams) ([]db.Entry, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Entry, error) {
+		return t.entries.before(arg.BeforeID, arg.Limit, func(entry db.Entry) bool {
+			return entry.AccountID == arg.AccountID
+		}), nil
+	})
+}
+
+func (q queries) GetOpeningBalance(ctx context.Context, arg db.GetOpeningBalanceParams) (int64, error) {
+	return query(q, func(t *tables, now time.Time) (int64, error) {
+		account, err := found(t.accounts.get(arg.AccountID))
+		if err != nil {
+			return 0, err
+		}
+
+		since := sum(t.entries.rows, func(entry db.Entry) bool {
+			return entry.AccountID == account.ID && !entry.CreatedAt.Before(arg.FromTime)
+		}, func(entry db.Entry) int64 { return entry.Amount })
+		return account.Balance - since, nil
+	})
+}
+
+func (q queries) ListStatementLines(ctx context.Context, arg db.ListStatementLinesParams) ([]db.ListStatementLinesRow, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.ListStatementLinesRow, error) {
+		entries := t.entries.all(func(entry db.Entry) bool {
+			return entry.AccountID == arg.AccountID &&
+				!entry.CreatedAt.Before(arg.FromTime) && entry.CreatedAt.Before(arg.ToTime)
+		})
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+		})
+
+		lines := make([]db.ListStatementLinesRow, 0, len(entries))
+		var total int64
+		for _, entry := range entries {
+			total += entry.Amount
+			line := db.ListStatementLinesRow{
+				ID:           entry.ID,
+				AccountID:    entry.AccountID,
+				Amount:       entry.Amount,
+				CreatedAt:    entry.CreatedAt,
+				TransferID:   entry.TransferID,
+				RunningTotal: total,
+			}
+			if counterparty, ok := t.counterparty(entry); ok {
+				line.CounterpartyAccountID = counterparty.ID
+				line.CounterpartyOwner = counterparty.Owner
+			}
+			lines = append(lines, line)
+		}
+		return lines, nil
+	})
+}
+
+// StreamStatementLines hands the lines to fn once they are all read, fn runs
+// without holding the backend
+func (q queries) StreamStatementLines(ctx context.Context, arg db.ListStatementLinesParams, fn func(db.ListStatementLinesRow) error) error {
+	lines, err := q.ListStatementLines(ctx, arg)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// counterparty returns the other account of the transfer that booked entry
+func (t *tables) counterparty(entry db.Entry) (db.Account, bool) {
+	if !entry.TransferID.Valid {
+		return db.Account{}, false
+	}
+	transfer, ok := t.transfers.get(entry.TransferID.Int64)
+	if !ok {
+		return db.Account{}, false
+	}
+
+	counterpartyID := transfer.FromAccountID
+	if transfer.FromAccountID == entry.AccountID {
+		counterpartyID = transfer.ToAccountID
+	}
+	return t.accounts.get(counterpartyID)
+}
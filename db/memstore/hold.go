@@ -0,0 +1,132 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+func checkHold(hold db.Hold) error {
+	return constraints(
+		checkConstraint(hold.Amount > 0, "holds", "holds_amount_check"),
+		checkConstraint(hold.CapturedAmount >= 0 && hold.CapturedAmount <= hold.Amount, "holds", "holds_captured_amount_check"),
+		checkConstraint(hold.Status == db.HoldActive || hold.Status == db.HoldCaptured ||
+			hold.Status == db.HoldVoided || hold.Status == db.HoldExpired, "holds", "holds_status_check"),
+	)
+}
+
+func isActiveHold(hold db.Hold) bool {
+	return hold.Status == db.HoldActive
+}
+
+func (q queries) InsertHold(ctx context.Context, arg db.InsertHoldParams) (db.Hold, error) {
+	return query(q, func(t *tables, now time.Time) (db.Hold, error) {
+		hold := db.Hold{
+			ID:          next(&t.sequences.holds),
+			AccountID:   arg.AccountID,
+			ToAccountID: arg.ToAccountID,
+			Amount:      arg.Amount,
+			Currency:    arg.Currency,
+			Status:      db.HoldActive,
+			ExpiresAt:   timestamp(arg.ExpiresAt),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := constraints(
+			checkHold(hold),
+			foreignKey(t.accounts.has(hold.AccountID), "holds", "holds_account_id_fkey"),
+			foreignKey(t.accounts.has(hold.ToAccountID), "holds", "holds_to_account_id_fkey"),
+		); err != nil {
+			return db.Hold{}, err
+		}
+
+		t.holds.insert(hold)
+		return hold, nil
+	})
+}
+
+func (q queries) GetHold(ctx context.Context, id int64) (db.Hold, error) {
+	return query(q, func(t *tables, now time.Time) (db.Hold, error) {
+		return found(t.holds.get(id))
+	})
+}
+
+func (q queries) GetHoldForUpdate(ctx context.Context, id int64) (db.Hold, error) {
+	return q.GetHold(ctx, id)
+}
+
+func (q queries) GetHeldAmount(ctx context.Context, accountID int64) (int64, error) {
+	return query(q, func(t *tables, now time.Time) (int64, error) {
+		return sum(t.holds.rows, func(hold db.Hold) bool {
+			return hold.AccountID == accountID && isActiveHold(hold) && hold.ExpiresAt.After(now)
+		}, func(hold db.Hold) int64 { return hold.Amount }), nil
+	})
+}
+
+func (q queries) ListHoldsByAccount(ctx context.Context, arg db.ListHoldsByAccountParams) ([]db.Hold, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Hold, error) {
+		return t.holds.before(arg.BeforeID, arg.Limit, func(hold db.Hold) bool {
+			return hold.AccountID == arg.AccountID
+		}), nil
+	})
+}
+
+func (q queries) ListHoldsByAccountAfter(ctx context.Context, arg db.ListHoldsByAccountAfterParams) ([]db.Hold, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Hold, error) {
+		return t.holds.after(arg.AfterID, arg.Limit, func(hold db.Hold) bool {
+			return hold.AccountID == arg.AccountID
+		}), nil
+	})
+}
+
+func (q queries) SetHoldCaptured(ctx context.Context, arg db.SetHoldCapturedParams) (db.Hold, error) {
+	return query(q, func(t *tables, now time.Time) (db.Hold, error) {
+		return update(t.holds, arg.ID, isActiveHold, func(hold *db.Hold) error {
+			hold.Status = db.HoldCaptured
+			hold.CapturedAmount = arg.CapturedAmount
+			hold.UpdatedAt = now
+			return checkHold(*hold)
+		})
+	})
+}
+
+func (q queries) SetHoldTransfer(ctx context.Context, arg db.SetHoldTransferParams) (db.Hold, error) {
+	return query(q, func(t *tables, now time.Time) (db.Hold, error) {
+		return update(t.holds, arg.ID, nil, func(hold *db.Hold) error {
+			hold.TransferID = arg.TransferID
+			hold.UpdatedAt = now
+			return foreignKey(!hold.TransferID.Valid || t.transfers.has(hold.TransferID.Int64), "holds", "holds_transfer_id_fkey")
+		})
+	})
+}
+
+func (q queries) SetHoldVoided(ctx context.Context, id int64) (db.Hold, error) {
+	return query(q, func(t *tables, now time.Time) (db.Hold, error) {
+		return update(t.holds, id, isActiveHold, func(hold *db.Hold) error {
+			hold.Status = db.HoldVoided
+			hold.UpdatedAt = now
+			return nil
+		})
+	})
+}
+
+func (q queries) ExpireHolds(ctx context.Context, limit int32) ([]db.Hold, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Hold, error) {
+		due := t.holds.all(func(hold db.Hold) bool {
+			return isActiveHold(hold) && !hold.ExpiresAt.After(now)
+		})
+		sort.SliceStable(due, func(i, j int) bool {
+			return due[i].ExpiresAt.Before(due[j].ExpiresAt)
+		})
+
+		expired := page(due, 0, limit)
+		for i := range expired {
+			expired[i].Status = db.HoldExpired
+			expired[i].UpdatedAt = now
+			t.holds.update(expired[i])
+		}
+		return expired, nil
+	})
+}
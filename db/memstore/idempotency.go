@@ -0,0 +1,45 @@
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+func (q queries) CreateIdempotencyKey(ctx context.Context, arg db.CreateIdempotencyKeyParams) (db.IdempotencyKey, error) {
+	return query(q, func(t *tables, now time.Time) (db.IdempotencyKey, error) {
+		if !json.Valid(arg.ResponseBody) {
+			return db.IdempotencyKey{}, invalidInput("json", string(arg.ResponseBody))
+		}
+
+		key := db.IdempotencyKey{
+			Username:     arg.Username,
+			Key:          arg.Key,
+			RequestPath:  arg.RequestPath,
+			RequestHash:  arg.RequestHash,
+			ResponseCode: arg.ResponseCode,
+			ResponseBody: append(json.RawMessage(nil), arg.ResponseBody...),
+			CreatedAt:    now,
+		}
+		if err := constraints(
+			uniqueConstraint(!t.idempotencyKeys.has(idempotencyKey{key.Username, key.Key}), "idempotency_keys", "idempotency_keys_pkey"),
+			foreignKey(t.users.has(key.Username), "idempotency_keys", "idempotency_keys_username_fkey"),
+		); err != nil {
+			return db.IdempotencyKey{}, err
+		}
+
+		t.idempotencyKeys.put(idempotencyKey{key.Username, key.Key}, key)
+		return key, nil
+	})
+}
+
+func (q queries) GetIdempotencyKey(ctx context.Context, arg db.GetIdempotencyKeyParams) (db.IdempotencyKey, error) {
+	return query(q, func(t *tables, now time.Time) (db.IdempotencyKey, error) {
+		key, err := found(t.idempotencyKeys.get(idempotencyKey{arg.Username, arg.Key}))
+		// the stored body is never handed out, callers may change theirs
+		key.ResponseBody = append(json.RawMessage(nil), key.ResponseBody...)
+		return key, err
+	})
+}
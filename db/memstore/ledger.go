@@ -0,0 +1,80 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+func (q queries) GetLedgerCounts(ctx context.Context) (db.GetLedgerCountsRow, error) {
+	return query(q, func(t *tables, now time.Time) (db.GetLedgerCountsRow, error) {
+		return db.GetLedgerCountsRow{
+			Accounts:  int64(len(t.accounts.rows)),
+			Entries:   int64(len(t.entries.rows)),
+			Transfers: int64(len(t.transfers.rows)),
+		}, nil
+	})
+}
+
+func (q queries) ListBalanceMismatches(ctx context.Context) ([]db.ListBalanceMismatchesRow, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.ListBalanceMismatchesRow, error) {
+		totals := map[int64]int64{}
+		for _, entry := range t.entries.rows {
+			totals[entry.AccountID] += entry.Amount
+		}
+
+		mismatches := []db.ListBalanceMismatchesRow{}
+		for _, account := range t.accounts.rows {
+			if account.Balance != totals[account.ID] {
+				mismatches = append(mismatches, db.ListBalanceMismatchesRow{
+					ID:           account.ID,
+					Owner:        account.Owner,
+					Currency:     account.Currency,
+					Balance:      account.Balance,
+					EntriesTotal: totals[account.ID],
+				})
+			}
+		}
+		return mismatches, nil
+	})
+}
+
+func (q queries) ListTransferMismatches(ctx context.Context) ([]db.ListTransferMismatchesRow, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.ListTransferMismatchesRow, error) {
+		entries := map[int64][]db.Entry{}
+		for _, entry := range t.entries.rows {
+			if entry.TransferID.Valid {
+				entries[entry.TransferID.Int64] = append(entries[entry.TransferID.Int64], entry)
+			}
+		}
+
+		mismatches := []db.ListTransferMismatchesRow{}
+		for _, transfer := range t.transfers.rows {
+			row := db.ListTransferMismatchesRow{
+				ID:            transfer.ID,
+				FromAccountID: transfer.FromAccountID,
+				ToAccountID:   transfer.ToAccountID,
+				Amount:        transfer.Amount,
+				ToAmount:      transfer.ToAmount,
+				FromCurrency:  transfer.FromCurrency,
+				ToCurrency:    transfer.ToCurrency,
+				EntryCount:    int64(len(entries[transfer.ID])),
+			}
+			for _, entry := range entries[transfer.ID] {
+				if entry.AccountID == transfer.FromAccountID {
+					row.Debited += entry.Amount
+				}
+				if entry.AccountID == transfer.ToAccountID {
+					row.Credited += entry.Amount
+				}
+			}
+
+			if row.EntryCount != 2 || row.Debited != -transfer.Amount || row.Credited != transfer.ToAmount ||
+				(transfer.FromCurrency == transfer.ToCurrency && transfer.Amount != transfer.ToAmount) {
+				mismatches = append(mismatches, row)
+			}
+		}
+		return mismatches, nil
+	})
+}
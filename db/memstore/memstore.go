@@ -0,0 +1,265 @@
+// Package memstore keeps the data of a db.Store in memory, for demos and for
+// tests that shouldn't need Postgres. The business rules are those of
+// db.SQLStore, only the queries are emulated: constraints, foreign keys and
+// sql.ErrNoRows behave as with the migrations applied, errors are *pq.Error
+// with the codes and constraint names Postgres would report.
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+// New returns an empty store with the default options, its data is lost
+// with the process
+func New() db.Store {
+	return NewWithOptions(db.DefaultStoreOptions())
+}
+
+// NewWithOptions returns an empty store, the isolation levels of options are
+// ignored since every transaction is serializable
+func NewWithOptions(options db.StoreOptions) db.Store {
+	return db.NewBackendStore(NewBackend(), options)
+}
+
+// Backend is a db.Backend in memory. Transactions run one at a time: a
+// transaction holds the backend from BeginTx until it commits or rolls back
+// and queries from outside of it wait meanwhile. Queries must therefore not
+// be run on the backend from inside a transaction.
+type Backend struct {
+	queries
+	mu     sync.Mutex
+	tables *tables
+}
+
+var _ db.Backend = (*Backend)(nil)
+
+// NewBackend returns a backend with the seeded currencies and nothing else
+func NewBackend() *Backend {
+	backend := &Backend{tables: newTables()}
+	backend.queries = queries{run: backend.run}
+	return backend
+}
+
+// run runs a single statement, its writes are kept only if it succeeds
+func (backend *Backend) run(statement func(t *tables, now time.Time) error) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	t := backend.tables.fork()
+	if err := statement(t, timestamp(time.Now())); err != nil {
+		return err
+	}
+	backend.tables = t
+	return nil
+}
+
+// BeginTx waits for the running transaction, if any, and starts a new one.
+// As in Postgres, now() is the start time of the transaction for all its
+// statements.
+func (backend *Backend) BeginTx(ctx context.Context, opts *sql.TxOptions) (db.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	backend.mu.Lock()
+
+	tx := &tx{
+		backend: backend,
+		tables:  backend.tables.fork(),
+		now:     timestamp(time.Now()),
+	}
+	tx.queries = queries{run: tx.run}
+	return tx, nil
+}
+
+type tx struct {
+	queries
+	backend *Backend
+	tables  *tables
+	now     time.Time
+	done    bool
+	// failed is set by the first statement that fails, like Postgres the
+	// transaction then refuses further statements and can only roll back
+	failed bool
+}
+
+func (tx *tx) run(statement func(t *tables, now time.Time) error) error {
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	if tx.failed {
+		return &pq.Error{
+			Severity: "ERROR",
+			Code:     "25P02",
+			Message:  "current transaction is aborted, commands ignored until end of transaction block",
+		}
+	}
+
+	err := statement(tx.tables, tx.now)
+	if _, ok := err.(*pq.Error); ok {
+		tx.failed = true
+	}
+	return err
+}
+
+func (tx *tx) Commit() error {
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	defer tx.backend.mu.Unlock()
+
+	if tx.failed {
+		return pq.ErrInFailedTransaction
+	}
+	tx.backend.tables = tx.tables
+	return nil
+}
+
+func (tx *tx) Rollback() error {
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	tx.backend.mu.Unlock()
+	return nil
+}
+
+// queries implements db.Querier on the tables handed over by run, those of
+// the backend or of a transaction
+type queries struct {
+	run func(statement func(t *tables, now time.Time) error) error
+}
+
+var _ db.Querier = queries{}
+
+// query runs a statement that returns a value, the zero value when it fails
+func query[T any](q queries, statement func(t *tables, now time.Time) (T, error)) (T, error) {
+	var result T
+	err := q.run(func(t *tables, now time.Time) error {
+		var err error
+		result, err = statement(t, now)
+		return err
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// update applies change to the row with id when it matches where, like
+// UPDATE ... WHERE id = $1 AND ... RETURNING *. No matching row is sql.ErrNoRows.
+func update[T any](rows *table[T], id int64, where func(T) bool, change func(row *T) error) (T, error) {
+	row, ok := rows.get(id)
+	if !ok || (where != nil && !where(row)) {
+		var zero T
+		return zero, sql.ErrNoRows
+	}
+	if err := change(&row); err != nil {
+		var zero T
+		return zero, err
+	}
+	rows.update(row)
+	return row, nil
+}
+
+// found returns the row of a lookup, sql.ErrNoRows if there was none
+func found[T any](row T, ok bool) (T, error) {
+	if !ok {
+		return row, sql.ErrNoRows
+	}
+	return row, nil
+}
+
+// sum adds up amount over the rows matching filter
+func sum[T any](rows []T, filter func(T) bool, amount func(T) int64) int64 {
+	var total int64
+	for _, row := range rows {
+		if filter(row) {
+			total += amount(row)
+		}
+	}
+	return total
+}
+
+func next(sequence *int64) int64 {
+	*sequence++
+	return *sequence
+}
+
+// timestamp truncates t to the microseconds a timestamptz column keeps
+func timestamp(t time.Time) time.Time {
+	return t.Round(time.Microsecond)
+}
+
+func nullTimestamp(t sql.NullTime) sql.NullTime {
+	if t.Valid {
+		t.Time = timestamp(t.Time)
+	}
+	return t
+}
+
+// constraints returns the first violation, nil if every constraint holds
+func constraints(violations ...error) error {
+	for _, err := range violations {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkConstraint(ok bool, table string, constraint string) error {
+	if ok {
+		return nil
+	}
+	return &pq.Error{
+		Severity:   "ERROR",
+		Code:       db.CheckViolation,
+		Message:    fmt.Sprintf("new row for relation %q violates check constraint %q", table, constraint),
+		Table:      table,
+		Constraint: constraint,
+	}
+}
+
+func uniqueConstraint(ok bool, table string, constraint string) error {
+	if ok {
+		return nil
+	}
+	return &pq.Error{
+		Severity:   "ERROR",
+		Code:       db.UniqueViolation,
+		Message:    fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		Table:      table,
+		Constraint: constraint,
+	}
+}
+
+func foreignKey(ok bool, table string, constraint string) error {
+	if ok {
+		return nil
+	}
+	return &pq.Error{
+		Severity:   "ERROR",
+		Code:       db.ForeignKeyViolation,
+		Message:    fmt.Sprintf("insert or update on table %q violates foreign key constraint %q", table, constraint),
+		Table:      table,
+		Constraint: constraint,
+	}
+}
+
+// invalidInput is the error of a value that doesn't parse as the type of its column
+func invalidInput(typ string, value string) error {
+	return &pq.Error{
+		Severity: "ERROR",
+		Code:     "22P02",
+		Message:  fmt.Sprintf("invalid input syntax for type %s: %q", typ, value),
+	}
+}
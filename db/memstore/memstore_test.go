@@ -0,0 +1,11 @@
+package memstore
+
+import (
+	"testing"
+
+	"github.com/minhdang2803/simple_bank/db/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, New())
+}
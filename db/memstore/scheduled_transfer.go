@@ -0,0 +1,128 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+func checkScheduledTransfer(transfer db.ScheduledTransfer) error {
+	return constraints(
+		checkConstraint(transfer.Amount > 0, "scheduled_transfers", "scheduled_transfers_amount_check"),
+		checkConstraint(transfer.Status == db.ScheduledTransferPending || transfer.Status == db.ScheduledTransferRunning ||
+			transfer.Status == db.ScheduledTransferCompleted || transfer.Status == db.ScheduledTransferFailed ||
+			transfer.Status == db.ScheduledTransferCancelled, "scheduled_transfers", "scheduled_transfers_status_check"),
+	)
+}
+
+func (q queries) CreateScheduledTransfer(ctx context.Context, arg db.CreateScheduledTransferParams) (db.ScheduledTransfer, error) {
+	return query(q, func(t *tables, now time.Time) (db.ScheduledTransfer, error) {
+		transfer := db.ScheduledTransfer{
+			ID:            next(&t.sequences.scheduledTransfers),
+			Owner:         arg.Owner,
+			FromAccountID: arg.FromAccountID,
+			ToAccountID:   arg.ToAccountID,
+			Amount:        arg.Amount,
+			Currency:      arg.Currency,
+			ExecuteAt:     timestamp(arg.ExecuteAt),
+			Status:        db.ScheduledTransferPending,
+			NextAttemptAt: timestamp(arg.ExecuteAt),
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := constraints(
+			checkScheduledTransfer(transfer),
+			foreignKey(t.users.has(transfer.Owner), "scheduled_transfers", "scheduled_transfers_owner_fkey"),
+			foreignKey(t.accounts.has(transfer.FromAccountID), "scheduled_transfers", "scheduled_transfers_from_account_id_fkey"),
+			foreignKey(t.accounts.has(transfer.ToAccountID), "scheduled_transfers", "scheduled_transfers_to_account_id_fkey"),
+		); err != nil {
+			return db.ScheduledTransfer{}, err
+		}
+
+		t.scheduledTransfers.insert(transfer)
+		return transfer, nil
+	})
+}
+
+func (q queries) GetScheduledTransfer(ctx context.Context, id int64) (db.ScheduledTransfer, error) {
+	return query(q, func(t *tables, now time.Time) (db.ScheduledTransfer, error) {
+		return found(t.scheduledTransfers.get(id))
+	})
+}
+
+func (q queries) ListScheduledTransfersByOwner(ctx context.Context, arg db.ListScheduledTransfersByOwnerParams) ([]db.ScheduledTransfer, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.ScheduledTransfer, error) {
+		return t.scheduledTransfers.after(arg.AfterID, arg.Limit, func(transfer db.ScheduledTransfer) bool {
+			return transfer.Owner == arg.Owner
+		}), nil
+	})
+}
+
+func (q queries) ListScheduledTransfersByOwnerBefore(ctx context.Context, arg db.ListScheduledTransfersByOwnerBeforeParams) ([]db.ScheduledTransfer, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.ScheduledTransfer, error) {
+		return t.scheduledTransfers.before(arg.BeforeID, arg.Limit, func(transfer db.ScheduledTransfer) bool {
+			return transfer.Owner == arg.Owner
+		}), nil
+	})
+}
+
+func (q queries) CancelScheduledTransfer(ctx context.Context, id int64) (db.ScheduledTransfer, error) {
+	return query(q, func(t *tables, now time.Time) (db.ScheduledTransfer, error) {
+		return update(t.scheduledTransfers, id, func(transfer db.ScheduledTransfer) bool {
+			return transfer.Status == db.ScheduledTransferPending
+		}, func(transfer *db.ScheduledTransfer) error {
+			transfer.Status = db.ScheduledTransferCancelled
+			transfer.UpdatedAt = now
+			return nil
+		})
+	})
+}
+
+func (q queries) ClaimDueScheduledTransfers(ctx context.Context, arg db.ClaimDueScheduledTransfersParams) ([]db.ScheduledTransfer, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.ScheduledTransfer, error) {
+		due := t.scheduledTransfers.all(func(transfer db.ScheduledTransfer) bool {
+			return (transfer.Status == db.ScheduledTransferPending || transfer.Status == db.ScheduledTransferRunning) &&
+				!transfer.NextAttemptAt.After(now)
+		})
+		sort.SliceStable(due, func(i, j int) bool {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		})
+
+		claimed := page(due, 0, arg.Limit)
+		for i := range claimed {
+			claimed[i].Status = db.ScheduledTransferRunning
+			claimed[i].Attempts++
+			claimed[i].NextAttemptAt = now.Add(time.Duration(arg.LeaseSeconds) * time.Second)
+			claimed[i].UpdatedAt = now
+			t.scheduledTransfers.update(claimed[i])
+		}
+		return claimed, nil
+	})
+}
+
+func (q queries) CompleteScheduledTransfer(ctx context.Context, arg db.CompleteScheduledTransferParams) (db.ScheduledTransfer, error) {
+	return query(q, func(t *tables, now time.Time) (db.ScheduledTransfer, error) {
+		return update(t.scheduledTransfers, arg.ID, nil, func(transfer *db.ScheduledTransfer) error {
+			transfer.Status = db.ScheduledTransferCompleted
+			transfer.TransferID = arg.TransferID
+			transfer.LastError = ""
+			transfer.UpdatedAt = now
+			return foreignKey(!transfer.TransferID.Valid || t.transfers.has(transfer.TransferID.Int64),
+				"scheduled_transfers", "scheduled_transfers_transfer_id_fkey")
+		})
+	})
+}
+
+func (q queries) FailScheduledTransfer(ctx context.Context, arg db.FailScheduledTransferParams) (db.ScheduledTransfer, error) {
+	return query(q, func(t *tables, now time.Time) (db.ScheduledTransfer, error) {
+		return update(t.scheduledTransfers, arg.ID, nil, func(transfer *db.ScheduledTransfer) error {
+			transfer.Status = arg.Status
+			transfer.LastError = arg.LastError
+			transfer.NextAttemptAt = timestamp(arg.NextAttemptAt)
+			transfer.UpdatedAt = now
+			return checkScheduledTransfer(*transfer)
+		})
+	})
+}
@@ -0,0 +1,65 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+func (q queries) CreateSession(ctx context.Context, arg db.CreateSessionParams) (db.Session, error) {
+	return query(q, func(t *tables, now time.Time) (db.Session, error) {
+		session := db.Session{
+			ID:           arg.ID,
+			Username:     arg.Username,
+			RefreshToken: arg.RefreshToken,
+			UserAgent:    arg.UserAgent,
+			ClientIp:     arg.ClientIp,
+			IsBlocked:    arg.IsBlocked,
+			ExpiresAt:    timestamp(arg.ExpiresAt),
+			CreatedAt:    now,
+		}
+		if err := constraints(
+			uniqueConstraint(!t.sessions.has(session.ID), "sessions", "sessions_pkey"),
+			foreignKey(t.users.has(session.Username), "sessions", "sessions_username_fkey"),
+		); err != nil {
+			return db.Session{}, err
+		}
+
+		t.sessions.put(session.ID, session)
+		return session, nil
+	})
+}
+
+func (q queries) GetSession(ctx context.Context, id uuid.UUID) (db.Session, error) {
+	return query(q, func(t *tables, now time.Time) (db.Session, error) {
+		return found(t.sessions.get(id))
+	})
+}
+
+func (q queries) BlockSession(ctx context.Context, id uuid.UUID) (db.Session, error) {
+	return query(q, func(t *tables, now time.Time) (db.Session, error) {
+		session, ok := t.sessions.get(id)
+		if !ok {
+			return db.Session{}, sql.ErrNoRows
+		}
+
+		session.IsBlocked = true
+		t.sessions.put(session.ID, session)
+		return session, nil
+	})
+}
+
+func (q queries) BlockUserSessions(ctx context.Context, username string) error {
+	return q.run(func(t *tables, now time.Time) error {
+		for _, session := range t.sessions.rows {
+			if session.Username == username && !session.IsBlocked {
+				session.IsBlocked = true
+				t.sessions.put(session.ID, session)
+			}
+		}
+		return nil
+	})
+}
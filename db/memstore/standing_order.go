@@ -0,0 +1,200 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+func checkStandingOrder(order db.StandingOrder) error {
+	return constraints(
+		checkConstraint(order.Amount > 0, "standing_orders", "standing_orders_amount_check"),
+		checkConstraint(!order.MaxOccurrences.Valid || order.MaxOccurrences.Int32 > 0,
+			"standing_orders", "standing_orders_max_occurrences_check"),
+		checkConstraint(order.CatchUp == db.CatchUpAll || order.CatchUp == db.CatchUpLatest || order.CatchUp == db.CatchUpSkip,
+			"standing_orders", "standing_orders_catch_up_check"),
+		checkConstraint(order.Status == db.StandingOrderActive || order.Status == db.StandingOrderPaused ||
+			order.Status == db.StandingOrderCompleted || order.Status == db.StandingOrderCancelled,
+			"standing_orders", "standing_orders_status_check"),
+	)
+}
+
+func (q queries) CreateStandingOrder(ctx context.Context, arg db.CreateStandingOrderParams) (db.StandingOrder, error) {
+	return query(q, func(t *tables, now time.Time) (db.StandingOrder, error) {
+		order := db.StandingOrder{
+			ID:             next(&t.sequences.standingOrders),
+			Owner:          arg.Owner,
+			FromAccountID:  arg.FromAccountID,
+			ToAccountID:    arg.ToAccountID,
+			Amount:         arg.Amount,
+			Currency:       arg.Currency,
+			Schedule:       arg.Schedule,
+			CatchUp:        arg.CatchUp,
+			StartAt:        timestamp(arg.StartAt),
+			EndAt:          nullTimestamp(arg.EndAt),
+			MaxOccurrences: arg.MaxOccurrences,
+			Status:         db.StandingOrderActive,
+			NextRunAt:      timestamp(arg.NextRunAt),
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		if err := constraints(
+			checkStandingOrder(order),
+			foreignKey(t.users.has(order.Owner), "standing_orders", "standing_orders_owner_fkey"),
+			foreignKey(t.accounts.has(order.FromAccountID), "standing_orders", "standing_orders_from_account_id_fkey"),
+			foreignKey(t.accounts.has(order.ToAccountID), "standing_orders", "standing_orders_to_account_id_fkey"),
+		); err != nil {
+			return db.StandingOrder{}, err
+		}
+
+		t.standingOrders.insert(order)
+		return order, nil
+	})
+}
+
+func (q queries) GetStandingOrder(ctx context.Context, id int64) (db.StandingOrder, error) {
+	return query(q, func(t *tables, now time.Time) (db.StandingOrder, error) {
+		return found(t.standingOrders.get(id))
+	})
+}
+
+func (q queries) ListStandingOrdersByOwner(ctx context.Context, arg db.ListStandingOrdersByOwnerParams) ([]db.StandingOrder, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.StandingOrder, error) {
+		return t.standingOrders.after(arg.AfterID, arg.Limit, func(order db.StandingOrder) bool {
+			return order.Owner == arg.Owner
+		}), nil
+	})
+}
+
+func (q queries) ListStandingOrdersByOwnerBefore(ctx context.Context, arg db.ListStandingOrdersByOwnerBeforeParams) ([]db.StandingOrder, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.StandingOrder, error) {
+		return t.standingOrders.before(arg.BeforeID, arg.Limit, func(order db.StandingOrder) bool {
+			return order.Owner == arg.Owner
+		}), nil
+	})
+}
+
+func (q queries) PauseStandingOrder(ctx context.Context, id int64) (db.StandingOrder, error) {
+	return query(q, func(t *tables, now time.Time) (db.StandingOrder, error) {
+		return update(t.standingOrders, id, func(order db.StandingOrder) bool {
+			return order.Status == db.StandingOrderActive
+		}, func(order *db.StandingOrder) error {
+			order.Status = db.StandingOrderPaused
+			order.UpdatedAt = now
+			return nil
+		})
+	})
+}
+
+func (q queries) ResumeStandingOrder(ctx context.Context, arg db.ResumeStandingOrderParams) (db.StandingOrder, error) {
+	return query(q, func(t *tables, now time.Time) (db.StandingOrder, error) {
+		return update(t.standingOrders, arg.ID, func(order db.StandingOrder) bool {
+			return order.Status == db.StandingOrderPaused
+		}, func(order *db.StandingOrder) error {
+			order.Status = db.StandingOrderActive
+			order.NextRunAt = timestamp(arg.NextRunAt)
+			order.UpdatedAt = now
+			return nil
+		})
+	})
+}
+
+func (q queries) CancelStandingOrder(ctx context.Context, id int64) (db.StandingOrder, error) {
+	return query(q, func(t *tables, now time.Time) (db.StandingOrder, error) {
+		return update(t.standingOrders, id, func(order db.StandingOrder) bool {
+			return order.Status == db.StandingOrderActive || order.Status == db.StandingOrderPaused
+		}, func(order *db.StandingOrder) error {
+			order.Status = db.StandingOrderCancelled
+			order.UpdatedAt = now
+			return nil
+		})
+	})
+}
+
+func (q queries) ClaimDueStandingOrders(ctx context.Context, arg db.ClaimDueStandingOrdersParams) ([]db.StandingOrder, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.StandingOrder, error) {
+		due := t.standingOrders.all(func(order db.StandingOrder) bool {
+			return order.Status == db.StandingOrderActive && !order.NextRunAt.After(now) &&
+				(!order.LockedUntil.Valid || !order.LockedUntil.Time.After(now))
+		})
+		sort.SliceStable(due, func(i, j int) bool {
+			return due[i].NextRunAt.Before(due[j].NextRunAt)
+		})
+
+		claimed := page(due, 0, arg.Limit)
+		for i := range claimed {
+			claimed[i].LockedUntil.Time = now.Add(time.Duration(arg.LeaseSeconds) * time.Second)
+			claimed[i].LockedUntil.Valid = true
+			t.standingOrders.update(claimed[i])
+		}
+		return claimed, nil
+	})
+}
+
+func (q queries) AdvanceStandingOrder(ctx context.Context, arg db.AdvanceStandingOrderParams) (db.StandingOrder, error) {
+	return query(q, func(t *tables, now time.Time) (db.StandingOrder, error) {
+		return update(t.standingOrders, arg.ID, nil, func(order *db.StandingOrder) error {
+			order.NextRunAt = timestamp(arg.NextRunAt)
+			order.Occurrences = arg.Occurrences
+			if order.Status == db.StandingOrderActive {
+				order.Status = arg.Status
+			}
+			order.LockedUntil = nullTimestamp(arg.LockedUntil)
+			order.UpdatedAt = now
+			return checkStandingOrder(*order)
+		})
+	})
+}
+
+func (q queries) CreateStandingOrderRun(ctx context.Context, arg db.CreateStandingOrderRunParams) error {
+	return q.run(func(t *tables, now time.Time) error {
+		run := db.StandingOrderRun{
+			ID:              next(&t.sequences.standingOrderRuns),
+			StandingOrderID: arg.StandingOrderID,
+			ScheduledFor:    timestamp(arg.ScheduledFor),
+			Status:          arg.Status,
+			TransferID:      arg.TransferID,
+			Error:           arg.Error,
+			CreatedAt:       now,
+		}
+		if err := checkConstraint(run.Status == db.StandingOrderRunCompleted || run.Status == db.StandingOrderRunFailed ||
+			run.Status == db.StandingOrderRunSkipped, "standing_order_runs", "standing_order_runs_status_check"); err != nil {
+			return err
+		}
+
+		// ON CONFLICT (standing_order_id, scheduled_for) DO NOTHING
+		for _, other := range t.standingOrderRuns.rows {
+			if other.StandingOrderID == run.StandingOrderID && other.ScheduledFor.Equal(run.ScheduledFor) {
+				return nil
+			}
+		}
+
+		if err := constraints(
+			foreignKey(t.standingOrders.has(run.StandingOrderID), "standing_order_runs", "standing_order_runs_standing_order_id_fkey"),
+			foreignKey(!run.TransferID.Valid || t.transfers.has(run.TransferID.Int64), "standing_order_runs", "standing_order_runs_transfer_id_fkey"),
+		); err != nil {
+			return err
+		}
+
+		t.standingOrderRuns.insert(run)
+		return nil
+	})
+}
+
+func (q queries) ListStandingOrderRuns(ctx context.Context, arg db.ListStandingOrderRunsParams) ([]db.StandingOrderRun, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.StandingOrderRun, error) {
+		return t.standingOrderRuns.before(arg.BeforeID, arg.Limit, func(run db.StandingOrderRun) bool {
+			return run.StandingOrderID == arg.StandingOrderID
+		}), nil
+	})
+}
+
+func (q queries) ListStandingOrderRunsAfter(ctx context.Context, arg db.ListStandingOrderRunsAfterParams) ([]db.StandingOrderRun, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.StandingOrderRun, error) {
+		return t.standingOrderRuns.after(arg.AfterID, arg.Limit, func(run db.StandingOrderRun) bool {
+			return run.StandingOrderID == arg.StandingOrderID
+		}), nil
+	})
+}
@@ -0,0 +1,226 @@
+package memstore
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+// table holds the rows of a table with a bigserial primary key in id order.
+// A forked table shares its rows with the table it was forked from until the
+// first update, inserts only append past the rows the original can see.
+type table[T any] struct {
+	rows  []T
+	id    func(T) int64
+	owned bool
+}
+
+func newTable[T any](id func(T) int64) *table[T] {
+	return &table[T]{id: id, owned: true}
+}
+
+func (t *table[T]) fork() *table[T] {
+	return &table[T]{rows: t.rows, id: t.id}
+}
+
+func (t *table[T]) index(id int64) (int, bool) {
+	i := sort.Search(len(t.rows), func(i int) bool { return t.id(t.rows[i]) >= id })
+	return i, i < len(t.rows) && t.id(t.rows[i]) == id
+}
+
+func (t *table[T]) get(id int64) (T, bool) {
+	var row T
+	i, ok := t.index(id)
+	if ok {
+		row = t.rows[i]
+	}
+	return row, ok
+}
+
+func (t *table[T]) has(id int64) bool {
+	_, ok := t.index(id)
+	return ok
+}
+
+// insert appends row, its id comes from a sequence and is above every other
+func (t *table[T]) insert(row T) {
+	t.rows = append(t.rows, row)
+}
+
+func (t *table[T]) update(row T) {
+	if !t.owned {
+		t.rows = append([]T(nil), t.rows...)
+		t.owned = true
+	}
+	i, _ := t.index(t.id(row))
+	t.rows[i] = row
+}
+
+// after returns up to limit rows matching filter with an id above afterID, in id order
+func (t *table[T]) after(afterID int64, limit int32, filter func(T) bool) []T {
+	items := []T{}
+	i, ok := t.index(afterID)
+	if ok {
+		i++
+	}
+	for ; i < len(t.rows) && len(items) < int(limit); i++ {
+		if filter(t.rows[i]) {
+			items = append(items, t.rows[i])
+		}
+	}
+	return items
+}
+
+// before returns up to limit rows matching filter with an id below beforeID, newest first
+func (t *table[T]) before(beforeID int64, limit int32, filter func(T) bool) []T {
+	items := []T{}
+	i, _ := t.index(beforeID)
+	for i--; i >= 0 && len(items) < int(limit); i-- {
+		if filter(t.rows[i]) {
+			items = append(items, t.rows[i])
+		}
+	}
+	return items
+}
+
+// all returns the rows matching filter in id order
+func (t *table[T]) all(filter func(T) bool) []T {
+	items := []T{}
+	for _, row := range t.rows {
+		if filter(row) {
+			items = append(items, row)
+		}
+	}
+	return items
+}
+
+// keyedTable holds the rows of a table with another primary key, it is
+// copied on the first write after a fork
+type keyedTable[K comparable, T any] struct {
+	rows  map[K]T
+	owned bool
+}
+
+func newKeyedTable[K comparable, T any]() *keyedTable[K, T] {
+	return &keyedTable[K, T]{rows: map[K]T{}, owned: true}
+}
+
+func (t *keyedTable[K, T]) fork() *keyedTable[K, T] {
+	return &keyedTable[K, T]{rows: t.rows}
+}
+
+func (t *keyedTable[K, T]) get(key K) (T, bool) {
+	row, ok := t.rows[key]
+	return row, ok
+}
+
+func (t *keyedTable[K, T]) has(key K) bool {
+	_, ok := t.rows[key]
+	return ok
+}
+
+func (t *keyedTable[K, T]) put(key K, row T) {
+	if !t.owned {
+		rows := make(map[K]T, len(t.rows)+1)
+		for k, v := range t.rows {
+			rows[k] = v
+		}
+		t.rows = rows
+		t.owned = true
+	}
+	t.rows[key] = row
+}
+
+type idempotencyKey struct {
+	username string
+	key      string
+}
+
+// sequences hand out the ids of the bigserial columns. Like in Postgres they
+// are not rolled back with the transaction that used them.
+type sequences struct {
+	accounts           int64
+	entries            int64
+	transfers          int64
+	scheduledTransfers int64
+	standingOrders     int64
+	standingOrderRuns  int64
+	holds              int64
+	transferBatches    int64
+	transferBatchItems int64
+}
+
+// tables is the content of the database at one point in time
+type tables struct {
+	users              *keyedTable[string, db.User]
+	sessions           *keyedTable[uuid.UUID, db.Session]
+	currencies         *keyedTable[string, db.Currency]
+	idempotencyKeys    *keyedTable[idempotencyKey, db.IdempotencyKey]
+	accounts           *table[db.Account]
+	entries            *table[db.Entry]
+	transfers          *table[db.Transfer]
+	scheduledTransfers *table[db.ScheduledTransfer]
+	standingOrders     *table[db.StandingOrder]
+	standingOrderRuns  *table[db.StandingOrderRun]
+	holds              *table[db.Hold]
+	transferBatches    *table[db.TransferBatch]
+	transferBatchItems *table[db.TransferBatchItem]
+	sequences          *sequences
+}
+
+// newTables returns the tables as the migrations leave them, with the
+// seeded currencies
+func newTables() *tables {
+	t := &tables{
+		users:              newKeyedTable[string, db.User](),
+		sessions:           newKeyedTable[uuid.UUID, db.Session](),
+		currencies:         newKeyedTable[string, db.Currency](),
+		idempotencyKeys:    newKeyedTable[idempotencyKey, db.IdempotencyKey](),
+		accounts:           newTable(func(row db.Account) int64 { return row.ID }),
+		entries:            newTable(func(row db.Entry) int64 { return row.ID }),
+		transfers:          newTable(func(row db.Transfer) int64 { return row.ID }),
+		scheduledTransfers: newTable(func(row db.ScheduledTransfer) int64 { return row.ID }),
+		standingOrders:     newTable(func(row db.StandingOrder) int64 { return row.ID }),
+		standingOrderRuns:  newTable(func(row db.StandingOrderRun) int64 { return row.ID }),
+		holds:              newTable(func(row db.Hold) int64 { return row.ID }),
+		transferBatches:    newTable(func(row db.TransferBatch) int64 { return row.ID }),
+		transferBatchItems: newTable(func(row db.TransferBatchItem) int64 { return row.ID }),
+		sequences:          &sequences{},
+	}
+
+	now := timestamp(time.Now())
+	for _, currency := range utils.DefaultCurrencies {
+		t.currencies.put(currency.Code, db.Currency{
+			Code:        currency.Code,
+			NumericCode: int32(currency.NumericCode),
+			MinorUnits:  int16(currency.MinorUnits),
+			Symbol:      currency.Symbol,
+			Enabled:     currency.Enabled,
+			CreatedAt:   now,
+		})
+	}
+	return t
+}
+
+// fork returns tables that a transaction can write without changing t
+func (t *tables) fork() *tables {
+	return &tables{
+		users:              t.users.fork(),
+		sessions:           t.sessions.fork(),
+		currencies:         t.currencies.fork(),
+		idempotencyKeys:    t.idempotencyKeys.fork(),
+		accounts:           t.accounts.fork(),
+		entries:            t.entries.fork(),
+		transfers:          t.transfers.fork(),
+		scheduledTransfers: t.scheduledTransfers.fork(),
+		standingOrders:     t.standingOrders.fork(),
+		standingOrderRuns:  t.standingOrderRuns.fork(),
+		holds:              t.holds.fork(),
+		transferBatches:    t.transferBatches.fork(),
+		transferBatchItems: t.transferBatchItems.fork(),
+		sequences:          t.sequences,
+	}
+}
@@ -0,0 +1,148 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+func (q queries) CreateTransfer(ctx context.Context, arg db.CreateTransferParams) (db.Transfer, error) {
+	return query(q, func(t *tables, now time.Time) (db.Transfer, error) {
+		transfer := db.Transfer{
+			ID:            next(&t.sequences.transfers),
+			FromAccountID: arg.FromAccountID,
+			ToAccountID:   arg.ToAccountID,
+			Amount:        arg.Amount,
+			CreatedAt:     now,
+			FromCurrency:  arg.FromCurrency,
+			ToCurrency:    arg.ToCurrency,
+			ToAmount:      arg.ToAmount,
+			ReversalOf:    arg.ReversalOf,
+		}
+
+		// exchange_rate is numeric(20,10), it reads back with its ten decimals
+		rate, ok := new(big.Rat).SetString(arg.ExchangeRate)
+		if !ok {
+			return db.Transfer{}, invalidInput("numeric", arg.ExchangeRate)
+		}
+		transfer.ExchangeRate = rate.FloatString(10)
+
+		if err := constraints(
+			foreignKey(t.accounts.has(transfer.FromAccountID), "transfers", "transfers_from_account_id_fkey"),
+			foreignKey(t.accounts.has(transfer.ToAccountID), "transfers", "transfers_to_account_id_fkey"),
+			foreignKey(!transfer.ReversalOf.Valid || t.transfers.has(transfer.ReversalOf.Int64), "transfers", "transfers_reversal_of_fkey"),
+		); err != nil {
+			return db.Transfer{}, err
+		}
+
+		t.transfers.insert(transfer)
+		return transfer, nil
+	})
+}
+
+func (q queries) GetTransfer(ctx context.Context, id int64) (db.Transfer, error) {
+	return query(q, func(t *tables, now time.Time) (db.Transfer, error) {
+		return found(t.transfers.get(id))
+	})
+}
+
+func (q queries) GetTransferForUpdate(ctx context.Context, id int64) (db.Transfer, error) {
+	return q.GetTransfer(ctx, id)
+}
+
+func (q queries) GetReversedTotals(ctx context.Context, reversalOf sql.NullInt64) (db.GetReversedTotalsRow, error) {
+	return query(q, func(t *tables, now time.Time) (db.GetReversedTotalsRow, error) {
+		// reversal_of = NULL matches nothing
+		reverses := func(transfer db.Transfer) bool {
+			return reversalOf.Valid && transfer.ReversalOf.Valid && transfer.ReversalOf.Int64 == reversalOf.Int64
+		}
+		return db.GetReversedTotalsRow{
+			ReversedAmount: sum(t.transfers.rows, reverses, func(transfer db.Transfer) int64 { return transfer.Amount }),
+			RefundedAmount: sum(t.transfers.rows, reverses, func(transfer db.Transfer) int64 { return transfer.ToAmount }),
+		}, nil
+	})
+}
+
+func (q queries) ListTransfers(ctx context.Context, arg db.ListTransfersParams) ([]db.Transfer, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Transfer, error) {
+		return t.transfers.before(arg.BeforeID, arg.Limit, transferFilter{
+			direction:      arg.Direction,
+			accountID:      arg.AccountID,
+			counterpartyID: arg.CounterpartyID,
+			minAmount:      arg.MinAmount,
+			maxAmount:      arg.MaxAmount,
+			fromTime:       arg.FromTime,
+			toTime:         arg.ToTime,
+		}.match), nil
+	})
+}
+
+func (q queries) ListTransfersAfter(ctx context.Context, arg db.ListTransfersAfterParams) ([]db.Transfer, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.Transfer, error) {
+		return t.transfers.after(arg.AfterID, arg.Limit, transferFilter{
+			direction:      arg.Direction,
+			accountID:      arg.AccountID,
+			counterpartyID: arg.CounterpartyID,
+			minAmount:      arg.MinAmount,
+			maxAmount:      arg.MaxAmount,
+			fromTime:       arg.FromTime,
+			toTime:         arg.ToTime,
+		}.match), nil
+	})
+}
+
+// transferFilter is the WHERE clause shared by ListTransfers and ListTransfersAfter
+type transferFilter struct {
+	direction      string
+	accountID      int64
+	counterpartyID sql.NullInt64
+	minAmount      sql.NullInt64
+	maxAmount      sql.NullInt64
+	fromTime       sql.NullTime
+	toTime         sql.NullTime
+}
+
+func (filter transferFilter) match(transfer db.Transfer) bool {
+	switch filter.direction {
+	case "incoming":
+		if transfer.ToAccountID != filter.accountID {
+			return false
+		}
+	case "outgoing":
+		if transfer.FromAccountID != filter.accountID {
+			return false
+		}
+	default:
+		if transfer.FromAccountID != filter.accountID && transfer.ToAccountID != filter.accountID {
+			return false
+		}
+	}
+
+	if filter.counterpartyID.Valid && transfer.FromAccountID != filter.counterpartyID.Int64 &&
+		transfer.ToAccountID != filter.counterpartyID.Int64 {
+		return false
+	}
+
+	// amounts are compared in the currency of the account
+	amount := transfer.Amount
+	if transfer.ToAccountID == filter.accountID {
+		amount = transfer.ToAmount
+	}
+	if filter.minAmount.Valid && amount < filter.minAmount.Int64 {
+		return false
+	}
+	if filter.maxAmount.Valid && amount > filter.maxAmount.Int64 {
+		return false
+	}
+
+	if filter.fromTime.Valid && transfer.CreatedAt.Before(filter.fromTime.Time) {
+		return false
+	}
+	if filter.toTime.Valid && !transfer.CreatedAt.Before(filter.toTime.Time) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+func (q queries) CreateTransferBatch(ctx context.Context, arg db.CreateTransferBatchParams) (db.TransferBatch, error) {
+	return query(q, func(t *tables, now time.Time) (db.TransferBatch, error) {
+		batch := db.TransferBatch{
+			ID:          next(&t.sequences.transferBatches),
+			Owner:       arg.Owner,
+			Mode:        arg.Mode,
+			Status:      arg.Status,
+			ItemCount:   arg.ItemCount,
+			FailedCount: arg.FailedCount,
+			CreatedAt:   now,
+		}
+		if err := constraints(
+			checkConstraint(batch.Mode == db.BatchAtomic || batch.Mode == db.BatchBestEffort,
+				"transfer_batches", "transfer_batches_mode_check"),
+			checkConstraint(batch.Status == db.TransferBatchCompleted || batch.Status == db.TransferBatchPartial ||
+				batch.Status == db.TransferBatchFailed, "transfer_batches", "transfer_batches_status_check"),
+			foreignKey(t.users.has(batch.Owner), "transfer_batches", "transfer_batches_owner_fkey"),
+		); err != nil {
+			return db.TransferBatch{}, err
+		}
+
+		t.transferBatches.insert(batch)
+		return batch, nil
+	})
+}
+
+func (q queries) GetTransferBatch(ctx context.Context, id int64) (db.TransferBatch, error) {
+	return query(q, func(t *tables, now time.Time) (db.TransferBatch, error) {
+		return found(t.transferBatches.get(id))
+	})
+}
+
+func (q queries) CreateTransferBatchItem(ctx context.Context, arg db.CreateTransferBatchItemParams) (db.TransferBatchItem, error) {
+	return query(q, func(t *tables, now time.Time) (db.TransferBatchItem, error) {
+		item := db.TransferBatchItem{
+			ID:            next(&t.sequences.transferBatchItems),
+			BatchID:       arg.BatchID,
+			Position:      arg.Position,
+			FromAccountID: arg.FromAccountID,
+			ToAccountID:   arg.ToAccountID,
+			Amount:        arg.Amount,
+			Currency:      arg.Currency,
+			Status:        arg.Status,
+			TransferID:    arg.TransferID,
+			Error:         arg.Error,
+			CreatedAt:     now,
+		}
+
+		taken := false
+		for _, other := range t.transferBatchItems.rows {
+			if other.BatchID == item.BatchID && other.Position == item.Position {
+				taken = true
+				break
+			}
+		}
+		if err := constraints(
+			checkConstraint(item.Amount > 0, "transfer_batch_items", "transfer_batch_items_amount_check"),
+			checkConstraint(item.Status == db.TransferBatchItemCompleted || item.Status == db.TransferBatchItemFailed,
+				"transfer_batch_items", "transfer_batch_items_status_check"),
+			uniqueConstraint(!taken, "transfer_batch_items", "transfer_batch_items_batch_id_position_idx"),
+			foreignKey(t.transferBatches.has(item.BatchID), "transfer_batch_items", "transfer_batch_items_batch_id_fkey"),
+			foreignKey(t.accounts.has(item.FromAccountID), "transfer_batch_items", "transfer_batch_items_from_account_id_fkey"),
+			foreignKey(t.accounts.has(item.ToAccountID), "transfer_batch_items", "transfer_batch_items_to_account_id_fkey"),
+			foreignKey(!item.TransferID.Valid || t.transfers.has(item.TransferID.Int64),
+				"transfer_batch_items", "transfer_batch_items_transfer_id_fkey"),
+		); err != nil {
+			return db.TransferBatchItem{}, err
+		}
+
+		t.transferBatchItems.insert(item)
+		return item, nil
+	})
+}
+
+func (q queries) ListTransferBatchItems(ctx context.Context, batchID int64) ([]db.TransferBatchItem, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.TransferBatchItem, error) {
+		items := t.transferBatchItems.all(func(item db.TransferBatchItem) bool {
+			return item.BatchID == batchID
+		})
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Position < items[j].Position
+		})
+		return items, nil
+	})
+}
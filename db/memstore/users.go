@@ -0,0 +1,117 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+// emailTaken reports whether a user other than username has email
+func (t *tables) emailTaken(email string, username string) bool {
+	for _, user := range t.users.rows {
+		if user.Email == email && user.Username != username {
+			return true
+		}
+	}
+	return false
+}
+
+func (q queries) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	return query(q, func(t *tables, now time.Time) (db.User, error) {
+		user := db.User{
+			Username:          arg.Username,
+			HashedPassword:    arg.HashedPassword,
+			FullName:          arg.FullName,
+			Email:             arg.Email,
+			PasswordChangedAt: time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+			CreatedAt:         now,
+			Role:              utils.DepositorRole,
+		}
+		if err := constraints(
+			uniqueConstraint(!t.users.has(user.Username), "users", "users_pkey"),
+			uniqueConstraint(!t.emailTaken(user.Email, user.Username), "users", "users_email_key"),
+		); err != nil {
+			return db.User{}, err
+		}
+
+		t.users.put(user.Username, user)
+		return user, nil
+	})
+}
+
+func (q queries) GetUser(ctx context.Context, username string) (db.User, error) {
+	return query(q, func(t *tables, now time.Time) (db.User, error) {
+		return found(t.users.get(username))
+	})
+}
+
+func (q queries) UpdateUser(ctx context.Context, arg db.UpdateUserParams) error {
+	return q.run(func(t *tables, now time.Time) error {
+		user, ok := t.users.get(arg.Username)
+		if !ok {
+			return nil
+		}
+		if err := uniqueConstraint(!t.emailTaken(arg.Email, user.Username), "users", "users_email_key"); err != nil {
+			return err
+		}
+
+		user.FullName = arg.FullName
+		user.Email = arg.Email
+		t.users.put(user.Username, user)
+		return nil
+	})
+}
+
+func (q queries) ChangePassword(ctx context.Context, arg db.ChangePasswordParams) error {
+	return q.run(func(t *tables, now time.Time) error {
+		user, ok := t.users.get(arg.Username)
+		if !ok {
+			return nil
+		}
+
+		user.HashedPassword = arg.HashedPassword
+		user.PasswordChangedAt = now
+		t.users.put(user.Username, user)
+		return nil
+	})
+}
+
+func (q queries) ListUsers(ctx context.Context, arg db.ListUsersParams) ([]db.User, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.User, error) {
+		users := t.sortedUsers(func(user db.User) bool { return user.Username > arg.AfterUsername })
+		return page(users, 0, arg.Limit), nil
+	})
+}
+
+func (q queries) ListUsersBefore(ctx context.Context, arg db.ListUsersBeforeParams) ([]db.User, error) {
+	return query(q, func(t *tables, now time.Time) ([]db.User, error) {
+		users := t.sortedUsers(func(user db.User) bool { return user.Username < arg.BeforeUsername })
+		slices.Reverse(users)
+		return page(users, 0, arg.Limit), nil
+	})
+}
+
+// sortedUsers returns the users matching filter in username order
+func (t *tables) sortedUsers(filter func(db.User) bool) []db.User {
+	users := []db.User{}
+	for _, user := range t.users.rows {
+		if filter(user) {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users
+}
+
+// page returns the rows of LIMIT limit OFFSET offset
+func page[T any](rows []T, offset int32, limit int32) []T {
+	start := min(max(int(offset), 0), len(rows))
+	end := min(start+max(int(limit), 0), len(rows))
+	return rows[start:end]
+}
@@ -0,0 +1,17 @@
+ALTER TABLE "transfers" ADD COLUMN "from_currency" varchar;
+ALTER TABLE "transfers" ADD COLUMN "to_currency" varchar;
+ALTER TABLE "transfers" ADD COLUMN "to_amount" bigint;
+ALTER TABLE "transfers" ADD COLUMN "exchange_rate" numeric(20,10) NOT NULL DEFAULT 1;
+
+-- transfers booked before this migration were always same-currency
+UPDATE "transfers" t SET "from_currency" = a."currency" FROM "accounts" a WHERE a."id" = t."from_account_id";
+UPDATE "transfers" t SET "to_currency" = a."currency" FROM "accounts" a WHERE a."id" = t."to_account_id";
+UPDATE "transfers" SET "to_amount" = "amount";
+
+ALTER TABLE "transfers" ALTER COLUMN "from_currency" SET NOT NULL;
+ALTER TABLE "transfers" ALTER COLUMN "to_currency" SET NOT NULL;
+ALTER TABLE "transfers" ALTER COLUMN "to_amount" SET NOT NULL;
+
+COMMENT ON COLUMN "transfers"."amount" IS 'must be positive, in from_currency';
+COMMENT ON COLUMN "transfers"."to_amount" IS 'must be positive, in to_currency';
+COMMENT ON COLUMN "transfers"."exchange_rate" IS 'to_currency units per from_currency unit';
@@ -0,0 +1,24 @@
+CREATE TABLE "currencies" (
+  "code" varchar(3) PRIMARY KEY,
+  "numeric_code" int UNIQUE NOT NULL,
+  "minor_units" smallint NOT NULL,
+  "symbol" varchar NOT NULL,
+  "enabled" boolean NOT NULL DEFAULT true,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "currencies" ADD CONSTRAINT "currencies_minor_units_check" CHECK ("minor_units" BETWEEN 0 AND 4);
+
+COMMENT ON COLUMN "currencies"."code" IS 'ISO 4217 alphabetic code';
+COMMENT ON COLUMN "currencies"."minor_units" IS 'number of decimal places, amounts are stored in these units';
+
+-- keep in sync with utils.DefaultCurrencies
+INSERT INTO "currencies" ("code", "numeric_code", "minor_units", "symbol", "enabled") VALUES
+  ('USD', 840, 2, '$', true),
+  ('EUR', 978, 2, '€', true),
+  ('CAD', 124, 2, 'CA$', true),
+  ('JPY', 392, 0, '¥', false),
+  ('BHD', 48, 3, 'BD', false);
+
+-- NOT VALID keeps accounts opened in a currency that was never registered
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_currency_fkey" FOREIGN KEY ("currency") REFERENCES "currencies" ("code") NOT VALID;
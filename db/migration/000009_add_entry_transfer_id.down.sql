@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "entries_account_id_created_at_idx";
+DROP INDEX IF EXISTS "entries_transfer_id_idx";
+ALTER TABLE IF EXISTS "entries" DROP COLUMN IF EXISTS "transfer_id";
@@ -0,0 +1,4 @@
+-- keyset pagination filters on the owner or account and walks the primary key
+CREATE INDEX ON "accounts" ("owner", "id");
+
+CREATE INDEX ON "entries" ("account_id", "id");
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "transfers_reversal_of_idx";
+ALTER TABLE IF EXISTS "transfers" DROP COLUMN IF EXISTS "reversal_of";
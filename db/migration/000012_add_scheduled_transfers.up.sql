@@ -0,0 +1,39 @@
+CREATE TABLE "scheduled_transfers" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "from_account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "execute_at" timestamptz NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "attempts" int NOT NULL DEFAULT 0,
+  "next_attempt_at" timestamptz NOT NULL,
+  "last_error" varchar NOT NULL DEFAULT '',
+  "transfer_id" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD CONSTRAINT "scheduled_transfers_amount_check" CHECK ("amount" > 0);
+
+ALTER TABLE "scheduled_transfers" ADD CONSTRAINT "scheduled_transfers_status_check"
+  CHECK ("status" IN ('pending', 'running', 'completed', 'failed', 'cancelled'));
+
+-- the executor polls pending rows by due date
+CREATE INDEX ON "scheduled_transfers" ("status", "next_attempt_at");
+
+CREATE INDEX ON "scheduled_transfers" ("owner", "id");
+
+COMMENT ON COLUMN "scheduled_transfers"."amount" IS 'must be positive, in currency';
+COMMENT ON COLUMN "scheduled_transfers"."status" IS 'pending, running, completed, failed or cancelled';
+COMMENT ON COLUMN "scheduled_transfers"."next_attempt_at" IS 'when the executor picks the row up, pushed back on retries and while running';
+COMMENT ON COLUMN "scheduled_transfers"."transfer_id" IS 'transfer booked by the executor once completed';
@@ -0,0 +1,69 @@
+CREATE TABLE "standing_orders" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "from_account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "schedule" varchar NOT NULL,
+  "catch_up" varchar NOT NULL DEFAULT 'latest',
+  "start_at" timestamptz NOT NULL,
+  "end_at" timestamptz,
+  "max_occurrences" int,
+  "occurrences" int NOT NULL DEFAULT 0,
+  "status" varchar NOT NULL DEFAULT 'active',
+  "next_run_at" timestamptz NOT NULL,
+  "locked_until" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "standing_order_runs" (
+  "id" bigserial PRIMARY KEY,
+  "standing_order_id" bigint NOT NULL,
+  "scheduled_for" timestamptz NOT NULL,
+  "status" varchar NOT NULL,
+  "transfer_id" bigint,
+  "error" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "standing_orders" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "standing_orders" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "standing_orders" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "standing_orders" ADD CONSTRAINT "standing_orders_amount_check" CHECK ("amount" > 0);
+
+ALTER TABLE "standing_orders" ADD CONSTRAINT "standing_orders_max_occurrences_check" CHECK ("max_occurrences" > 0);
+
+ALTER TABLE "standing_orders" ADD CONSTRAINT "standing_orders_catch_up_check"
+  CHECK ("catch_up" IN ('all', 'latest', 'skip'));
+
+ALTER TABLE "standing_orders" ADD CONSTRAINT "standing_orders_status_check"
+  CHECK ("status" IN ('active', 'paused', 'completed', 'cancelled'));
+
+ALTER TABLE "standing_order_runs" ADD FOREIGN KEY ("standing_order_id") REFERENCES "standing_orders" ("id");
+
+ALTER TABLE "standing_order_runs" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "standing_order_runs" ADD CONSTRAINT "standing_order_runs_status_check"
+  CHECK ("status" IN ('completed', 'failed', 'skipped'));
+
+-- the executor polls active orders by due date
+CREATE INDEX ON "standing_orders" ("status", "next_run_at");
+
+CREATE INDEX ON "standing_orders" ("owner", "id");
+
+-- an occurrence is recorded once, however many executors see it
+CREATE UNIQUE INDEX ON "standing_order_runs" ("standing_order_id", "scheduled_for");
+
+COMMENT ON COLUMN "standing_orders"."amount" IS 'must be positive, in currency';
+COMMENT ON COLUMN "standing_orders"."schedule" IS 'cron expression evaluated in UTC';
+COMMENT ON COLUMN "standing_orders"."catch_up" IS 'what happens to occurrences missed while the executor was down: all, latest or skip';
+COMMENT ON COLUMN "standing_orders"."occurrences" IS 'occurrences that came due so far, whatever their outcome';
+COMMENT ON COLUMN "standing_orders"."status" IS 'active, paused, completed or cancelled';
+COMMENT ON COLUMN "standing_orders"."next_run_at" IS 'next occurrence of the schedule';
+COMMENT ON COLUMN "standing_orders"."locked_until" IS 'set while an executor works on the order';
+COMMENT ON COLUMN "standing_order_runs"."status" IS 'completed, failed or skipped';
@@ -0,0 +1,42 @@
+CREATE TABLE "holds" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'active',
+  "captured_amount" bigint NOT NULL DEFAULT 0,
+  "transfer_id" bigint,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "holds" ADD CONSTRAINT "holds_amount_check" CHECK ("amount" > 0);
+
+ALTER TABLE "holds" ADD CONSTRAINT "holds_captured_amount_check"
+  CHECK ("captured_amount" >= 0 AND "captured_amount" <= "amount");
+
+ALTER TABLE "holds" ADD CONSTRAINT "holds_status_check"
+  CHECK ("status" IN ('active', 'captured', 'voided', 'expired'));
+
+-- the available balance sums the active holds of an account
+CREATE INDEX ON "holds" ("account_id") WHERE "status" = 'active';
+
+-- the executor expires stale holds by date
+CREATE INDEX ON "holds" ("status", "expires_at");
+
+CREATE INDEX ON "holds" ("account_id", "id");
+
+COMMENT ON COLUMN "holds"."account_id" IS 'account whose funds are reserved';
+COMMENT ON COLUMN "holds"."to_account_id" IS 'account credited when the hold is captured';
+COMMENT ON COLUMN "holds"."amount" IS 'must be positive, in currency of account_id';
+COMMENT ON COLUMN "holds"."status" IS 'active, captured, voided or expired';
+COMMENT ON COLUMN "holds"."captured_amount" IS 'what the capture took, the rest is released';
+COMMENT ON COLUMN "holds"."expires_at" IS 'an active hold stops reserving funds at that time';
@@ -0,0 +1,55 @@
+CREATE TABLE "transfer_batches" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "mode" varchar NOT NULL,
+  "status" varchar NOT NULL,
+  "item_count" int NOT NULL,
+  "failed_count" int NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "transfer_batch_items" (
+  "id" bigserial PRIMARY KEY,
+  "batch_id" bigint NOT NULL,
+  "position" int NOT NULL,
+  "from_account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "currency" varchar NOT NULL,
+  "status" varchar NOT NULL,
+  "transfer_id" bigint,
+  "error" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "transfer_batches" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "transfer_batch_items" ADD FOREIGN KEY ("batch_id") REFERENCES "transfer_batches" ("id");
+
+ALTER TABLE "transfer_batch_items" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "transfer_batch_items" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "transfer_batch_items" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+ALTER TABLE "transfer_batches" ADD CONSTRAINT "transfer_batches_mode_check"
+  CHECK ("mode" IN ('atomic', 'best_effort'));
+
+ALTER TABLE "transfer_batches" ADD CONSTRAINT "transfer_batches_status_check"
+  CHECK ("status" IN ('completed', 'partial', 'failed'));
+
+ALTER TABLE "transfer_batch_items" ADD CONSTRAINT "transfer_batch_items_amount_check" CHECK ("amount" > 0);
+
+ALTER TABLE "transfer_batch_items" ADD CONSTRAINT "transfer_batch_items_status_check"
+  CHECK ("status" IN ('completed', 'failed'));
+
+CREATE UNIQUE INDEX ON "transfer_batch_items" ("batch_id", "position");
+
+CREATE INDEX ON "transfer_batches" ("owner", "id");
+
+COMMENT ON COLUMN "transfer_batches"."mode" IS 'atomic books every item or none, best_effort books the items that can be';
+COMMENT ON COLUMN "transfer_batches"."status" IS 'completed, partial or failed';
+COMMENT ON COLUMN "transfer_batch_items"."position" IS 'index of the item in the request';
+COMMENT ON COLUMN "transfer_batch_items"."amount" IS 'must be positive, in currency';
+COMMENT ON COLUMN "transfer_batch_items"."transfer_id" IS 'transfer booked for the item once completed';
+COMMENT ON COLUMN "transfer_batch_items"."error" IS 'why a failed item was not booked';
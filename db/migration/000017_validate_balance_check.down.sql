@@ -0,0 +1,3 @@
+-- the backfilled overdraft limits are kept, the original ones are unknown
+ALTER TABLE IF EXISTS "accounts" DROP CONSTRAINT IF EXISTS "accounts_balance_check";
+ALTER TABLE IF EXISTS "accounts" ADD CONSTRAINT "accounts_balance_check" CHECK ("balance" >= -"overdraft_limit") NOT VALID;
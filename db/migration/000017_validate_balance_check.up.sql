@@ -0,0 +1,5 @@
+-- accounts already below zero before 000006 get an overdraft limit that covers
+-- their debt, otherwise the check refuses every update of them, even a credit
+UPDATE "accounts" SET "overdraft_limit" = -"balance" WHERE "balance" < -"overdraft_limit";
+
+ALTER TABLE "accounts" VALIDATE CONSTRAINT "accounts_balance_check";
@@ -0,0 +1,313 @@
+// Package migration applies the schema migrations of this directory, which
+// are embedded in the binary. It keeps its state in the schema_migrations
+// table of the migrate CLI, so databases migrated with either are picked up
+// by the other.
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockID is the key of the advisory lock held while migrating, so that
+// replicas starting together apply each migration once
+const lockID int64 = 7_413_862_901
+
+// ErrDirty is returned when a previous migration failed halfway. The schema
+// has to be repaired by hand and the version set with Force.
+var ErrDirty = errors.New("database is dirty")
+
+// Migration is a version of the schema with the scripts to reach it and to
+// go back to the previous one
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is the schema version of a database, 0 before any migration
+type Status struct {
+	Version int64 `json:"version"`
+	Dirty   bool  `json:"dirty"`
+	Latest  int64 `json:"latest"`
+}
+
+// Current reports whether the schema has every migration of the binary,
+// applied cleanly. A newer schema, migrated by a newer release, counts.
+func (status Status) Current() bool {
+	return !status.Dirty && status.Version >= status.Latest
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads the migrations of fsys sorted by version. Every version needs
+// an up and a down script.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, name := range names {
+		match := fileName.FindStringSubmatch(name)
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", name)
+		}
+		script, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d %s needs both an up and a down script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Latest returns the version of the last embedded migration
+func Latest() int64 {
+	migrations, err := Load(files)
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// Migrator applies migrations to a Postgres database
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New returns a migrator for the embedded migrations
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithMigrations(db, migrations), nil
+}
+
+// NewWithMigrations returns a migrator for migrations, sorted by version
+func NewWithMigrations(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+func (migrator *Migrator) latest() int64 {
+	if len(migrator.migrations) == 0 {
+		return 0
+	}
+	return migrator.migrations[len(migrator.migrations)-1].Version
+}
+
+func (migrator *Migrator) find(version int64) (int, bool) {
+	i := sort.Search(len(migrator.migrations), func(i int) bool {
+		return migrator.migrations[i].Version >= version
+	})
+	return i, i < len(migrator.migrations) && migrator.migrations[i].Version == version
+}
+
+// Status returns the version of the database without waiting for a
+// migration in progress
+func (migrator *Migrator) Status(ctx context.Context) (Status, error) {
+	status := Status{Latest: migrator.latest()}
+
+	err := migrator.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").
+		Scan(&status.Version, &status.Dirty)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) && !isUndefinedTable(err) {
+		return Status{}, fmt.Errorf("cannot read schema version: %w", err)
+	}
+	return status, nil
+}
+
+// Up applies the migrations the database doesn't have yet and returns the
+// versions it applied
+func (migrator *Migrator) Up(ctx context.Context) ([]int64, error) {
+	var applied []int64
+	err := migrator.locked(ctx, func(conn *sql.Conn, status Status) error {
+		i, _ := migrator.find(status.Version + 1)
+		for _, migration := range migrator.migrations[i:] {
+			if err := migrator.apply(ctx, conn, migration.Version, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("migration %d %s failed: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration.Version)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps migrations, all of them when steps is
+// negative, and returns the versions it reverted
+func (migrator *Migrator) Down(ctx context.Context, steps int) ([]int64, error) {
+	var reverted []int64
+	err := migrator.locked(ctx, func(conn *sql.Conn, status Status) error {
+		if status.Version == 0 {
+			return nil
+		}
+		i, ok := migrator.find(status.Version)
+		if !ok {
+			return fmt.Errorf("database is at version %d which has no migration", status.Version)
+		}
+
+		for ; i >= 0 && steps != 0; i, steps = i-1, steps-1 {
+			migration := migrator.migrations[i]
+			var previous int64
+			if i > 0 {
+				previous = migrator.migrations[i-1].Version
+			}
+			if err := migrator.apply(ctx, conn, migration.Version, migration.Down, previous); err != nil {
+				return fmt.Errorf("migration %d %s failed to revert: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration.Version)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Force sets the version of the database and clears its dirty flag without
+// running any script, once a failed migration has been repaired by hand
+func (migrator *Migrator) Force(ctx context.Context, version int64) error {
+	if _, ok := migrator.find(version); !ok && version != 0 {
+		return fmt.Errorf("no migration has version %d", version)
+	}
+
+	conn, err := migrator.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer migrator.unlock(conn)
+
+	return setVersion(ctx, conn, version, false)
+}
+
+// locked runs fn holding the migration lock, with the status of a database
+// that isn't dirty
+func (migrator *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, status Status) error) error {
+	conn, err := migrator.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer migrator.unlock(conn)
+
+	// read again now that no other replica can be migrating
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Dirty {
+		return fmt.Errorf("%w at version %d", ErrDirty, status.Version)
+	}
+	return fn(conn, status)
+}
+
+// lock takes the advisory lock on a connection of its own, the lock belongs
+// to the session and has to be released on the same connection
+func (migrator *Migrator) lock(ctx context.Context) (*sql.Conn, error) {
+	conn, err := migrator.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot lock migrations: %w", err)
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint NOT NULL PRIMARY KEY,
+		dirty boolean NOT NULL
+	)`)
+	if err != nil {
+		migrator.unlock(conn)
+		return nil, fmt.Errorf("cannot create schema_migrations: %w", err)
+	}
+	return conn, nil
+}
+
+func (migrator *Migrator) unlock(conn *sql.Conn) {
+	// closing the session would release the lock too, unlocking lets the
+	// connection go back to the pool
+	conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID)
+	conn.Close()
+}
+
+// apply runs the script that takes the database from version to target.
+// The version stays dirty if the script fails, like with the migrate CLI the
+// script isn't wrapped in a transaction so it may manage its own.
+func (migrator *Migrator) apply(ctx context.Context, conn *sql.Conn, version int64, script string, target int64) error {
+	if err := setVersion(ctx, conn, version, true); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	return setVersion(ctx, conn, target, false)
+}
+
+// setVersion replaces the only row of schema_migrations, version 0 leaves
+// the table empty
+func setVersion(ctx context.Context, conn *sql.Conn, version int64, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "TRUNCATE schema_migrations"); err != nil {
+		return fmt.Errorf("cannot set schema version: %w", err)
+	}
+	if version != 0 {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)", version, dirty)
+		if err != nil {
+			return fmt.Errorf("cannot set schema version: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// isUndefinedTable reports whether err is Postgres' undefined_table, the
+// error of a database that was never migrated
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "42P01"
+}
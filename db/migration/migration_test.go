@@ -0,0 +1,100 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load(files)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	// versions are contiguous from 1, as the migrate CLI numbers them
+	for i, migration := range migrations {
+		require.Equal(t, int64(i+1), migration.Version)
+		require.NotEmpty(t, migration.Up)
+		require.NotEmpty(t, migration.Down)
+	}
+	require.Equal(t, "init_schema", migrations[0].Name)
+	require.Equal(t, migrations[len(migrations)-1].Version, Latest())
+}
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files fstest.MapFS
+		check func(t *testing.T, migrations []Migration, err error)
+	}{
+		{
+			name: "SortedByVersion",
+			files: fstest.MapFS{
+				"000010_b.up.sql":   {Data: []byte("up b")},
+				"000010_b.down.sql": {Data: []byte("down b")},
+				"000002_a.up.sql":   {Data: []byte("up a")},
+				"000002_a.down.sql": {Data: []byte("down a")},
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []Migration{
+					{Version: 2, Name: "a", Up: "up a", Down: "down a"},
+					{Version: 10, Name: "b", Up: "up b", Down: "down b"},
+				}, migrations)
+			},
+		},
+		{
+			name: "MissingDown",
+			files: fstest.MapFS{
+				"000001_a.up.sql": {Data: []byte("up a")},
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.EqualError(t, err, "migration 1 a needs both an up and a down script")
+			},
+		},
+		{
+			name: "ConflictingNames",
+			files: fstest.MapFS{
+				"000001_a.up.sql":   {Data: []byte("up a")},
+				"000001_b.down.sql": {Data: []byte("down b")},
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.Error(t, err)
+			},
+		},
+		{
+			name: "InvalidName",
+			files: fstest.MapFS{
+				"init.sql": {Data: []byte("up")},
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.EqualError(t, err, `invalid migration file name "init.sql"`)
+			},
+		},
+		{
+			name: "VersionZero",
+			files: fstest.MapFS{
+				"0_a.up.sql":   {Data: []byte("up a")},
+				"0_a.down.sql": {Data: []byte("down a")},
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.Error(t, err)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			migrations, err := Load(tc.files)
+			tc.check(t, migrations, err)
+		})
+	}
+}
+
+func TestStatusCurrent(t *testing.T) {
+	require.True(t, Status{Version: 3, Latest: 3}.Current())
+	require.True(t, Status{Version: 4, Latest: 3}.Current())
+	require.False(t, Status{Version: 2, Latest: 3}.Current())
+	require.False(t, Status{Version: 3, Dirty: true, Latest: 3}.Current())
+}
@@ -19,22 +19,62 @@ Where id = $1 LIMIT 1;
 
 -- name: ListAccounts :many
 SELECT * FROM accounts
+WHERE id > sqlc.arg(after_id)
 ORDER BY id
-LIMIT $1
-OFFSET $2;
+LIMIT sqlc.arg('limit');
 
--- name: UpadateAccount :one
-UPDATE accounts
-SET balance = $2
-WHERE id = $1
-RETURNING *;
+-- name: ListAccountsBefore :many
+SELECT * FROM accounts
+WHERE id < sqlc.arg(before_id)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
 
--- name: DeleteAccount :exec
-DELETE FROM accounts WHERE id = $1;
+-- name: ListAccountsByOwner :many
+SELECT * FROM accounts
+WHERE owner = sqlc.arg(owner) AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg('limit');
 
+-- name: ListAccountsByOwnerBefore :many
+SELECT * FROM accounts
+WHERE owner = sqlc.arg(owner) AND id < sqlc.arg(before_id)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
 
 -- name: AddAccountBalance :one
 UPDATE accounts
 SET balance = balance + sqlc.arg(amount)
 WHERE id = sqlc.arg(id)
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: UpdateAccountOverdraftLimit :one
+UPDATE accounts
+SET overdraft_limit = sqlc.arg(overdraft_limit)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: FreezeAccount :one
+UPDATE accounts
+SET status = 'frozen'
+WHERE id = sqlc.arg(id) AND status = 'active'
+RETURNING *;
+
+-- name: UnfreezeAccount :one
+UPDATE accounts
+SET status = 'active'
+WHERE id = sqlc.arg(id) AND status = 'frozen'
+RETURNING *;
+
+-- name: SetAccountClosed :one
+UPDATE accounts
+SET status = 'closed'
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetAccountsForUpdate :many
+-- locks the accounts in id order, concurrent callers locking overlapping
+-- sets can't deadlock.
+SELECT * FROM accounts
+WHERE id = ANY(sqlc.arg(ids)::bigint[])
+ORDER BY id
+FOR NO KEY UPDATE;
@@ -1,9 +1,10 @@
 -- name: CreateEntry :one
 INSERT INTO entries (
   account_id,
-  amount
+  amount,
+  transfer_id
 ) VALUES (
-  $1, $2
+  $1, $2, $3
 ) RETURNING *;
 
 -- name: GetEntry :one
@@ -12,7 +13,44 @@ WHERE id = $1 LIMIT 1;
 
 -- name: ListEntries :many
 SELECT * FROM entries
-WHERE account_id = $1
+WHERE account_id = sqlc.arg(account_id) AND id > sqlc.arg(after_id)
 ORDER BY id
-LIMIT $2
-OFFSET $3;
\ No newline at end of file
+LIMIT sqlc.arg('limit');
+
+-- name: ListEntriesBefore :many
+SELECT * FROM entries
+WHERE account_id = sqlc.arg(account_id) AND id < sqlc.arg(before_id)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetOpeningBalance :one
+-- The balance right before from_time, derived from the current balance so
+-- accounts opened with a non-zero balance are handled.
+SELECT (a.balance - COALESCE(SUM(e.amount), 0))::bigint AS opening_balance
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id AND e.created_at >= sqlc.arg(from_time)
+WHERE a.id = sqlc.arg(account_id)
+GROUP BY a.id;
+
+-- name: ListStatementLines :many
+-- running_total is the sum of the entries up to and including each line,
+-- add the opening balance to get the running balance.
+SELECT
+  e.id,
+  e.account_id,
+  e.amount,
+  e.created_at,
+  e.transfer_id,
+  COALESCE(c.id, 0)::bigint AS counterparty_account_id,
+  COALESCE(c.owner, '')::varchar AS counterparty_owner,
+  (SUM(e.amount) OVER (ORDER BY e.created_at, e.id))::bigint AS running_total
+FROM entries e
+LEFT JOIN transfers t ON t.id = e.transfer_id
+LEFT JOIN accounts c ON c.id = CASE
+  WHEN t.from_account_id = e.account_id THEN t.to_account_id
+  ELSE t.from_account_id
+END
+WHERE e.account_id = sqlc.arg(account_id)
+  AND e.created_at >= sqlc.arg(from_time)
+  AND e.created_at < sqlc.arg(to_time)
+ORDER BY e.created_at, e.id;
@@ -0,0 +1,71 @@
+-- name: InsertHold :one
+INSERT INTO holds (
+    account_id,
+    to_account_id,
+    amount,
+    currency,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetHold :one
+SELECT * FROM holds
+WHERE id = $1 LIMIT 1;
+
+-- name: GetHoldForUpdate :one
+SELECT * FROM holds
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: GetHeldAmount :one
+-- sums the funds reserved on an account, a hold past its expiry doesn't
+-- count even before the executor marks it expired.
+SELECT COALESCE(SUM(amount), 0)::bigint AS held
+FROM holds
+WHERE account_id = $1 AND status = 'active' AND expires_at > now();
+
+-- name: ListHoldsByAccount :many
+SELECT * FROM holds
+WHERE account_id = sqlc.arg(account_id) AND id < sqlc.arg(before_id)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListHoldsByAccountAfter :many
+SELECT * FROM holds
+WHERE account_id = sqlc.arg(account_id) AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg('limit');
+
+-- name: SetHoldCaptured :one
+UPDATE holds
+SET status = 'captured', captured_amount = $2, updated_at = now()
+WHERE id = $1 AND status = 'active'
+RETURNING *;
+
+-- name: SetHoldTransfer :one
+UPDATE holds
+SET transfer_id = $2, updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: SetHoldVoided :one
+UPDATE holds
+SET status = 'voided', updated_at = now()
+WHERE id = $1 AND status = 'active'
+RETURNING *;
+
+-- name: ExpireHolds :many
+-- releases the active holds past their expiry, rows locked by a capture or
+-- another executor are left for the next run.
+UPDATE holds
+SET status = 'expired', updated_at = now()
+WHERE id IN (
+    SELECT id FROM holds
+    WHERE status = 'active' AND expires_at <= now()
+    ORDER BY expires_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
@@ -0,0 +1,43 @@
+-- name: GetLedgerCounts :one
+SELECT
+  (SELECT COUNT(*) FROM accounts)::bigint AS accounts,
+  (SELECT COUNT(*) FROM entries)::bigint AS entries,
+  (SELECT COUNT(*) FROM transfers)::bigint AS transfers;
+
+-- name: ListBalanceMismatches :many
+-- accounts whose balance isn't the sum of their entries.
+SELECT
+  a.id,
+  a.owner,
+  a.currency,
+  a.balance,
+  COALESCE(SUM(e.amount), 0)::bigint AS entries_total
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id;
+
+-- name: ListTransferMismatches :many
+-- transfers that don't have exactly one debit of amount on the sender and
+-- one credit of to_amount on the recipient, or whose amounts differ
+-- although both sides share a currency.
+SELECT
+  t.id,
+  t.from_account_id,
+  t.to_account_id,
+  t.amount,
+  t.to_amount,
+  t.from_currency,
+  t.to_currency,
+  COUNT(e.id)::bigint AS entry_count,
+  COALESCE(SUM(e.amount) FILTER (WHERE e.account_id = t.from_account_id), 0)::bigint AS debited,
+  COALESCE(SUM(e.amount) FILTER (WHERE e.account_id = t.to_account_id), 0)::bigint AS credited
+FROM transfers t
+LEFT JOIN entries e ON e.transfer_id = t.id
+GROUP BY t.id
+HAVING COUNT(e.id) <> 2
+  OR COALESCE(SUM(e.amount) FILTER (WHERE e.account_id = t.from_account_id), 0) <> -t.amount
+  OR COALESCE(SUM(e.amount) FILTER (WHERE e.account_id = t.to_account_id), 0) <> t.to_amount
+  OR (t.from_currency = t.to_currency AND t.amount <> t.to_amount)
+ORDER BY t.id;
@@ -0,0 +1,67 @@
+-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (
+    owner,
+    from_account_id,
+    to_account_id,
+    amount,
+    currency,
+    execute_at,
+    next_attempt_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $6
+)
+RETURNING *;
+
+-- name: GetScheduledTransfer :one
+SELECT * FROM scheduled_transfers
+WHERE id = $1 LIMIT 1;
+
+-- name: ListScheduledTransfersByOwner :many
+SELECT * FROM scheduled_transfers
+WHERE owner = sqlc.arg(owner) AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg('limit');
+
+-- name: ListScheduledTransfersByOwnerBefore :many
+SELECT * FROM scheduled_transfers
+WHERE owner = sqlc.arg(owner) AND id < sqlc.arg(before_id)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: CancelScheduledTransfer :one
+-- only pending transfers can be cancelled, no row means the executor got there first.
+UPDATE scheduled_transfers
+SET status = 'cancelled', updated_at = now()
+WHERE id = $1 AND status = 'pending'
+RETURNING *;
+
+-- name: ClaimDueScheduledTransfers :many
+-- marks due transfers as running for lease_seconds, rows locked by another
+-- executor are skipped. Running rows whose lease expired are picked up again.
+UPDATE scheduled_transfers
+SET
+    status = 'running',
+    attempts = attempts + 1,
+    next_attempt_at = now() + sqlc.arg(lease_seconds)::int * interval '1 second',
+    updated_at = now()
+WHERE id IN (
+    SELECT id FROM scheduled_transfers
+    WHERE status IN ('pending', 'running') AND next_attempt_at <= now()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteScheduledTransfer :one
+UPDATE scheduled_transfers
+SET status = 'completed', transfer_id = $2, last_error = '', updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: FailScheduledTransfer :one
+-- records a failed attempt, status is pending to retry at next_attempt_at or failed to give up.
+UPDATE scheduled_transfers
+SET status = $2, last_error = $3, next_attempt_at = $4, updated_at = now()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,106 @@
+-- name: CreateStandingOrder :one
+INSERT INTO standing_orders (
+    owner,
+    from_account_id,
+    to_account_id,
+    amount,
+    currency,
+    schedule,
+    catch_up,
+    start_at,
+    end_at,
+    max_occurrences,
+    next_run_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+)
+RETURNING *;
+
+-- name: GetStandingOrder :one
+SELECT * FROM standing_orders
+WHERE id = $1 LIMIT 1;
+
+-- name: ListStandingOrdersByOwner :many
+SELECT * FROM standing_orders
+WHERE owner = sqlc.arg(owner) AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg('limit');
+
+-- name: ListStandingOrdersByOwnerBefore :many
+SELECT * FROM standing_orders
+WHERE owner = sqlc.arg(owner) AND id < sqlc.arg(before_id)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: PauseStandingOrder :one
+UPDATE standing_orders
+SET status = 'paused', updated_at = now()
+WHERE id = $1 AND status = 'active'
+RETURNING *;
+
+-- name: ResumeStandingOrder :one
+-- occurrences that came due while paused are not caught up, next_run_at is
+-- the first one after the order is resumed.
+UPDATE standing_orders
+SET status = 'active', next_run_at = $2, updated_at = now()
+WHERE id = $1 AND status = 'paused'
+RETURNING *;
+
+-- name: CancelStandingOrder :one
+UPDATE standing_orders
+SET status = 'cancelled', updated_at = now()
+WHERE id = $1 AND status IN ('active', 'paused')
+RETURNING *;
+
+-- name: ClaimDueStandingOrders :many
+-- locks due orders for lease_seconds, rows locked by another executor are
+-- skipped. Orders whose lease expired are picked up again.
+UPDATE standing_orders
+SET locked_until = now() + sqlc.arg(lease_seconds)::int * interval '1 second'
+WHERE id IN (
+    SELECT id FROM standing_orders
+    WHERE status = 'active' AND next_run_at <= now()
+        AND (locked_until IS NULL OR locked_until <= now())
+    ORDER BY next_run_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: AdvanceStandingOrder :one
+-- records the progress of the executor. An order paused or cancelled in the
+-- meantime keeps its status, locked_until is null once the executor is done.
+UPDATE standing_orders
+SET
+    next_run_at = sqlc.arg(next_run_at),
+    occurrences = sqlc.arg(occurrences),
+    status = CASE WHEN status = 'active' THEN sqlc.arg(status) ELSE status END,
+    locked_until = sqlc.narg(locked_until),
+    updated_at = now()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CreateStandingOrderRun :exec
+-- an occurrence already recorded by an earlier attempt is left untouched.
+INSERT INTO standing_order_runs (
+    standing_order_id,
+    scheduled_for,
+    status,
+    transfer_id,
+    error
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+ON CONFLICT (standing_order_id, scheduled_for) DO NOTHING;
+
+-- name: ListStandingOrderRuns :many
+SELECT * FROM standing_order_runs
+WHERE standing_order_id = sqlc.arg(standing_order_id) AND id < sqlc.arg(before_id)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListStandingOrderRunsAfter :many
+SELECT * FROM standing_order_runs
+WHERE standing_order_id = sqlc.arg(standing_order_id) AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg('limit');
@@ -2,31 +2,75 @@
 SELECT * FROM transfers
 WHERE id = $1 LIMIT 1;
 
+-- name: GetTransferForUpdate :one
+SELECT * FROM transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: GetReversedTotals :one
+-- sums the reversals of a transfer, amount is in the original to_currency
+-- and to_amount in the original from_currency.
+SELECT
+  COALESCE(SUM(amount), 0)::bigint AS reversed_amount,
+  COALESCE(SUM(to_amount), 0)::bigint AS refunded_amount
+FROM transfers
+WHERE reversal_of = $1;
+
 -- name: ListTransfers :many
+-- walks the history of an account newest first, amounts are compared
+-- in the account's own currency and to_time is exclusive.
 SELECT * FROM transfers
-WHERE 
-    from_account_id = $1 OR
-    to_account_id = $2
+WHERE
+    CASE sqlc.arg(direction)::text
+        WHEN 'incoming' THEN to_account_id = sqlc.arg(account_id)
+        WHEN 'outgoing' THEN from_account_id = sqlc.arg(account_id)
+        ELSE from_account_id = sqlc.arg(account_id) OR to_account_id = sqlc.arg(account_id)
+    END
+    AND (sqlc.narg(counterparty_id)::bigint IS NULL
+        OR from_account_id = sqlc.narg(counterparty_id) OR to_account_id = sqlc.narg(counterparty_id))
+    AND (sqlc.narg(min_amount)::bigint IS NULL
+        OR CASE WHEN to_account_id = sqlc.arg(account_id) THEN to_amount ELSE amount END >= sqlc.narg(min_amount))
+    AND (sqlc.narg(max_amount)::bigint IS NULL
+        OR CASE WHEN to_account_id = sqlc.arg(account_id) THEN to_amount ELSE amount END <= sqlc.narg(max_amount))
+    AND (sqlc.narg(from_time)::timestamptz IS NULL OR created_at >= sqlc.narg(from_time))
+    AND (sqlc.narg(to_time)::timestamptz IS NULL OR created_at < sqlc.narg(to_time))
+    AND id < sqlc.arg(before_id)
+ORDER BY id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListTransfersAfter :many
+-- walks the same history back towards the newest transfer, oldest first.
+SELECT * FROM transfers
+WHERE
+    CASE sqlc.arg(direction)::text
+        WHEN 'incoming' THEN to_account_id = sqlc.arg(account_id)
+        WHEN 'outgoing' THEN from_account_id = sqlc.arg(account_id)
+        ELSE from_account_id = sqlc.arg(account_id) OR to_account_id = sqlc.arg(account_id)
+    END
+    AND (sqlc.narg(counterparty_id)::bigint IS NULL
+        OR from_account_id = sqlc.narg(counterparty_id) OR to_account_id = sqlc.narg(counterparty_id))
+    AND (sqlc.narg(min_amount)::bigint IS NULL
+        OR CASE WHEN to_account_id = sqlc.arg(account_id) THEN to_amount ELSE amount END >= sqlc.narg(min_amount))
+    AND (sqlc.narg(max_amount)::bigint IS NULL
+        OR CASE WHEN to_account_id = sqlc.arg(account_id) THEN to_amount ELSE amount END <= sqlc.narg(max_amount))
+    AND (sqlc.narg(from_time)::timestamptz IS NULL OR created_at >= sqlc.narg(from_time))
+    AND (sqlc.narg(to_time)::timestamptz IS NULL OR created_at < sqlc.narg(to_time))
+    AND id > sqlc.arg(after_id)
 ORDER BY id
-LIMIT $3
-OFFSET $4;
+LIMIT sqlc.arg('limit');
 
 -- name: CreateTransfer :one
 INSERT INTO transfers(
     from_account_id,
     to_account_id,
-    amount
+    amount,
+    from_currency,
+    to_currency,
+    to_amount,
+    exchange_rate,
+    reversal_of
 )
 VALUES(
-    $1, $2, $3
+    $1, $2, $3, $4, $5, $6, $7, $8
 )
 RETURNING *;
-
--- name: UpdateTransfer :exec
-UPDATE transfers
-SET amount = $1
-WHERE id = $2;
-
--- name: DeleteTransfer :exec
-DELETE FROM transfers
-WHERE id = $1;
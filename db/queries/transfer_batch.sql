@@ -0,0 +1,36 @@
+-- name: CreateTransferBatch :one
+INSERT INTO transfer_batches (
+    owner,
+    mode,
+    status,
+    item_count,
+    failed_count
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetTransferBatch :one
+SELECT * FROM transfer_batches
+WHERE id = $1 LIMIT 1;
+
+-- name: CreateTransferBatchItem :one
+INSERT INTO transfer_batch_items (
+    batch_id,
+    position,
+    from_account_id,
+    to_account_id,
+    amount,
+    currency,
+    status,
+    transfer_id,
+    error
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING *;
+
+-- name: ListTransferBatchItems :many
+SELECT * FROM transfer_batch_items
+WHERE batch_id = $1
+ORDER BY position;
@@ -26,4 +26,16 @@ UPDATE users AS u
 SET
     hashed_password = $1,
     password_changed_at = NOW()
-WHERE u.username = $2;
\ No newline at end of file
+WHERE u.username = $2;
+
+-- name: ListUsers :many
+SELECT * FROM users
+WHERE username > sqlc.arg(after_username)
+ORDER BY username
+LIMIT sqlc.arg('limit');
+
+-- name: ListUsersBefore :many
+SELECT * FROM users
+WHERE username < sqlc.arg(before_username)
+ORDER BY username DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// Statuses of an Account
+const (
+	AccountActive = "active"
+	AccountFrozen = "frozen"
+	AccountClosed = "closed"
+)
+
+// CloseAccount closes an account for good. The account must be active, with
+// a zero balance and no active hold. Its rows stay so that the entries and
+// transfers that reference it remain readable.
+func (store *SQLStore) CloseAccount(ctx context.Context, accountID int64) (Account, error) {
+	var account Account
+	err := store.execTx(ctx, nil, func(q Querier) error {
+		// locking the account keeps transfers and holds out until it is closed
+		locked, err := q.GetAccountForUpdate(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if err := ensureActive(locked); err != nil {
+			return err
+		}
+		if locked.Balance != 0 {
+			return fmt.Errorf("%w: account [%d] has a balance of %d",
+				ErrAccountNotEmpty, locked.ID, locked.Balance)
+		}
+
+		held, err := q.GetHeldAmount(ctx, locked.ID)
+		if err != nil {
+			return err
+		}
+		if held != 0 {
+			return fmt.Errorf("%w: account [%d] has %d reserved by active holds",
+				ErrAccountNotEmpty, locked.ID, held)
+		}
+
+		account, err = q.SetAccountClosed(ctx, locked.ID)
+		return err
+	})
+	return account, err
+}
+
+// ensureActive fails with ErrAccountFrozen or ErrAccountClosed when money
+// can't move in or out of account
+func ensureActive(account Account) error {
+	switch account.Status {
+	case AccountFrozen:
+		return fmt.Errorf("%w: account [%d]", ErrAccountFrozen, account.ID)
+	case AccountClosed:
+		return fmt.Errorf("%w: account [%d]", ErrAccountClosed, account.ID)
+	}
+	return nil
+}
@@ -7,13 +7,15 @@ package db
 
 import (
 	"context"
+
+	"github.com/lib/pq"
 )
 
 const addAccountBalance = `-- name: AddAccountBalance :one
 UPDATE accounts
 SET balance = balance + $1
 WHERE id = $2
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, status
 `
 
 type AddAccountBalanceParams struct {
@@ -30,6 +32,8 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
 	)
 	return i, err
 }
@@ -42,7 +46,7 @@ INSERT INTO accounts (
 ) VALUES (
     $1, $2, $3
 )
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, status
 `
 
 type CreateAccountParams struct {
@@ -60,21 +64,36 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
 	)
 	return i, err
 }
 
-const deleteAccount = `-- name: DeleteAccount :exec
-DELETE FROM accounts WHERE id = $1
+const freezeAccount = `-- name: FreezeAccount :one
+UPDATE accounts
+SET status = 'frozen'
+WHERE id = $1 AND status = 'active'
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, status
 `
 
-func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deleteAccount, id)
-	return err
+func (q *Queries) FreezeAccount(ctx context.Context, id int64) (Account, error) {
+	row := q.db.QueryRowContext(ctx, freezeAccount, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
+	)
+	return i, err
 }
 
 const getAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, overdraft_limit, status FROM accounts
 Where id = $1 LIMIT 1
 `
 
@@ -87,12 +106,14 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
 	)
 	return i, err
 }
 
 const getAccountForUpdate = `-- name: GetAccountForUpdate :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, overdraft_limit, status FROM accounts
 WHERE id = $1 LIMIT 1
 FOR NO KEY UPDATE
 `
@@ -106,24 +127,153 @@ func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, e
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
 	)
 	return i, err
 }
 
+const getAccountsForUpdate = `-- name: GetAccountsForUpdate :many
+SELECT id, owner, balance, currency, created_at, overdraft_limit, status FROM accounts
+WHERE id = ANY($1::bigint[])
+ORDER BY id
+FOR NO KEY UPDATE
+`
+
+// locks the accounts in id order, concurrent callers locking overlapping
+// sets can't deadlock.
+func (q *Queries) GetAccountsForUpdate(ctx context.Context, ids []int64) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, getAccountsForUpdate, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.OverdraftLimit,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, overdraft_limit, status FROM accounts
+WHERE id > $1
 ORDER BY id
-LIMIT $1
-OFFSET $2
+LIMIT $2
 `
 
 type ListAccountsParams struct {
-	Limit  int32 `db:"limit" json:"limit"`
-	Offset int32 `db:"offset" json:"offset"`
+	AfterID int64 `db:"after_id" json:"after_id"`
+	Limit   int32 `db:"limit" json:"limit"`
 }
 
 func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error) {
-	rows, err := q.db.QueryContext(ctx, listAccounts, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listAccounts, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.OverdraftLimit,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountsBefore = `-- name: ListAccountsBefore :many
+SELECT id, owner, balance, currency, created_at, overdraft_limit, status FROM accounts
+WHERE id < $1
+ORDER BY id DESC
+LIMIT $2
+`
+
+type ListAccountsBeforeParams struct {
+	BeforeID int64 `db:"before_id" json:"before_id"`
+	Limit    int32 `db:"limit" json:"limit"`
+}
+
+func (q *Queries) ListAccountsBefore(ctx context.Context, arg ListAccountsBeforeParams) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountsBefore, arg.BeforeID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.OverdraftLimit,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountsByOwner = `-- name: ListAccountsByOwner :many
+SELECT id, owner, balance, currency, created_at, overdraft_limit, status FROM accounts
+WHERE owner = $1 AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type ListAccountsByOwnerParams struct {
+	Owner   string `db:"owner" json:"owner"`
+	AfterID int64  `db:"after_id" json:"after_id"`
+	Limit   int32  `db:"limit" json:"limit"`
+}
+
+func (q *Queries) ListAccountsByOwner(ctx context.Context, arg ListAccountsByOwnerParams) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountsByOwner, arg.Owner, arg.AfterID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -137,6 +287,8 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.OverdraftLimit,
+			&i.Status,
 		); err != nil {
 			return nil, err
 		}
@@ -151,20 +303,108 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 	return items, nil
 }
 
-const upadateAccount = `-- name: UpadateAccount :one
+const listAccountsByOwnerBefore = `-- name: ListAccountsByOwnerBefore :many
+SELECT id, owner, balance, currency, created_at, overdraft_limit, status FROM accounts
+WHERE owner = $1 AND id < $2
+ORDER BY id DESC
+LIMIT $3
+`
+
+type ListAccountsByOwnerBeforeParams struct {
+	Owner    string `db:"owner" json:"owner"`
+	BeforeID int64  `db:"before_id" json:"before_id"`
+	Limit    int32  `db:"limit" json:"limit"`
+}
+
+func (q *Queries) ListAccountsByOwnerBefore(ctx context.Context, arg ListAccountsByOwnerBeforeParams) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountsByOwnerBefore, arg.Owner, arg.BeforeID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.OverdraftLimit,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setAccountClosed = `-- name: SetAccountClosed :one
 UPDATE accounts
-SET balance = $2
+SET status = 'closed'
 WHERE id = $1
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, status
+`
+
+func (q *Queries) SetAccountClosed(ctx context.Context, id int64) (Account, error) {
+	row := q.db.QueryRowContext(ctx, setAccountClosed, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
+	)
+	return i, err
+}
+
+const unfreezeAccount = `-- name: UnfreezeAccount :one
+UPDATE accounts
+SET status = 'active'
+WHERE id = $1 AND status = 'frozen'
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, status
+`
+
+func (q *Queries) UnfreezeAccount(ctx context.Context, id int64) (Account, error) {
+	row := q.db.QueryRowContext(ctx, unfreezeAccount, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
+	)
+	return i, err
+}
+
+const updateAccountOverdraftLimit = `-- name: UpdateAccountOverdraftLimit :one
+UPDATE accounts
+SET overdraft_limit = $1
+WHERE id = $2
+RETURNING id, owner, balance, currency, created_at, overdraft_limit, status
 `
 
-type UpadateAccountParams struct {
-	ID      int64 `db:"id" json:"id"`
-	Balance int64 `db:"balance" json:"balance"`
+type UpdateAccountOverdraftLimitParams struct {
+	OverdraftLimit int64 `db:"overdraft_limit" json:"overdraft_limit"`
+	ID             int64 `db:"id" json:"id"`
 }
 
-func (q *Queries) UpadateAccount(ctx context.Context, arg UpadateAccountParams) (Account, error) {
-	row := q.db.QueryRowContext(ctx, upadateAccount, arg.ID, arg.Balance)
+func (q *Queries) UpdateAccountOverdraftLimit(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountOverdraftLimit, arg.OverdraftLimit, arg.ID)
 	var i Account
 	err := row.Scan(
 		&i.ID,
@@ -172,6 +412,8 @@ func (q *Queries) UpadateAccount(ctx context.Context, arg UpadateAccountParams)
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.OverdraftLimit,
+		&i.Status,
 	)
 	return i, err
 }
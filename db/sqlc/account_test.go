@@ -11,11 +11,15 @@ import (
 )
 
 func CreateRandomAccount(t *testing.T) Account {
+	return createRandomAccountInCurrency(t, utils.RandomCurrency())
+}
+
+func createRandomAccountInCurrency(t *testing.T, currency string) Account {
 	user, _ := CreateRandomUser()
 	arg := CreateAccountParams{
 		Owner:    user.Username,
 		Balance:  utils.RandomMoney(),
-		Currency: utils.RandomCurrency(),
+		Currency: currency,
 	}
 
 	account, err := testQueries.CreateAccount(context.Background(), arg)
@@ -51,30 +55,114 @@ func TestDeleteAccount(t *testing.T) {
 func TestAccount(t *testing.T) {
 	account1 := CreateRandomAccount(t)
 
-	arg := UpadateAccountParams{
-		ID:      account1.ID,
-		Balance: utils.RandomMoney(),
+	arg := AddAccountBalanceParams{
+		ID:     account1.ID,
+		Amount: utils.RandomMoney(),
 	}
 
-	account2, err := testQueries.UpadateAccount(context.Background(), arg)
+	account2, err := testQueries.AddAccountBalance(context.Background(), arg)
 
 	require.NoError(t, err)
 	require.NotEmpty(t, account2)
 	require.Equal(t, account1.ID, account2.ID)
 	require.Equal(t, account1.Owner, account2.Owner)
-	require.Equal(t, arg.Balance, account2.Balance)
+	require.Equal(t, account1.Balance+arg.Amount, account2.Balance)
 	require.WithinDuration(t, account1.CreatedAt, account2.CreatedAt, time.Second)
 }
 
-func TestDeleteAcccount(t *testing.T) {
-	account1 := CreateRandomAccount(t)
-	err := testQueries.DeleteAccount(context.Background(), account1.ID)
+func TestCloseAccount(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 10)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 0)
+	require.Equal(t, AccountActive, account1.Status)
+
+	_, err := store.CloseAccount(context.Background(), account1.ID)
+	require.ErrorIs(t, err, ErrAccountNotEmpty)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
 	require.NoError(t, err)
 
-	account2, err := testQueries.GetAccount(context.Background(), account1.ID)
-	require.Error(t, err)
-	require.EqualError(t, err, sql.ErrNoRows.Error())
-	require.Empty(t, account2)
+	hold, err := store.CreateHold(context.Background(), CreateHoldParams{
+		AccountID:   account2.ID,
+		ToAccountID: account1.ID,
+		Amount:      5,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	_, err = store.CreateHold(context.Background(), CreateHoldParams{
+		AccountID:   account2.ID,
+		ToAccountID: account1.ID,
+		Amount:      5,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	_, err = store.VoidHold(context.Background(), hold.ID)
+	require.NoError(t, err)
+
+	closed, err := store.CloseAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, AccountClosed, closed.Status)
+
+	// the row stays, no money moves in or out of it anymore
+	account, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, AccountClosed, account.Status)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        1,
+	})
+	require.ErrorIs(t, err, ErrAccountClosed)
+
+	_, err = store.CloseAccount(context.Background(), account1.ID)
+	require.ErrorIs(t, err, ErrAccountClosed)
+
+	// account2 still has an active hold
+	_, err = store.CloseAccount(context.Background(), account2.ID)
+	require.ErrorIs(t, err, ErrAccountNotEmpty)
+}
+
+func TestFreezeAccount(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 100)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 100)
+
+	frozen, err := testQueries.FreezeAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, AccountFrozen, frozen.Status)
+
+	_, err = testQueries.FreezeAccount(context.Background(), account1.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// a frozen account can neither send nor receive
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.ErrorIs(t, err, ErrAccountFrozen)
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        10,
+	})
+	require.ErrorIs(t, err, ErrAccountFrozen)
+
+	active, err := testQueries.UnfreezeAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, AccountActive, active.Status)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
 }
 
 func TestListAccount(t *testing.T) {
@@ -83,8 +171,8 @@ func TestListAccount(t *testing.T) {
 	}
 
 	arg := ListAccountsParams{
-		Limit:  5,
-		Offset: 0,
+		AfterID: 0,
+		Limit:   5,
 	}
 	accounts, err := testQueries.ListAccounts(context.Background(), arg)
 	require.NoError(t, err)
@@ -92,4 +180,42 @@ func TestListAccount(t *testing.T) {
 	for _, account := range accounts {
 		require.NotEmpty(t, account)
 	}
-}
\ No newline at end of file
+
+	// the next page starts right after the last id, without overlap
+	next, err := testQueries.ListAccounts(context.Background(), ListAccountsParams{
+		AfterID: accounts[4].ID,
+		Limit:   5,
+	})
+	require.NoError(t, err)
+	require.Len(t, next, 5)
+	require.Greater(t, next[0].ID, accounts[4].ID)
+
+	// walking back from the second page returns the first one, newest first
+	prev, err := testQueries.ListAccountsBefore(context.Background(), ListAccountsBeforeParams{
+		BeforeID: next[0].ID,
+		Limit:    5,
+	})
+	require.NoError(t, err)
+	require.Len(t, prev, 5)
+	require.Equal(t, accounts[4].ID, prev[0].ID)
+	require.Equal(t, accounts[0].ID, prev[4].ID)
+}
+func TestListAccountsByOwner(t *testing.T) {
+	var lastAccount Account
+	for i := 0; i < 3; i++ {
+		lastAccount = CreateRandomAccount(t)
+	}
+
+	arg := ListAccountsByOwnerParams{
+		Owner:   lastAccount.Owner,
+		AfterID: 0,
+		Limit:   5,
+	}
+	accounts, err := testQueries.ListAccountsByOwner(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, accounts)
+	for _, account := range accounts {
+		require.NotEmpty(t, account)
+		require.Equal(t, lastAccount.Owner, account.Owner)
+	}
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Backend is where a store keeps its data. The store runs the business rules,
+// the backend the queries: directly for single statements and through the
+// transactions it begins for the rest. NewStore uses Postgres, see the
+// memstore package for an in-memory backend.
+type Backend interface {
+	Querier
+	StreamStatementLines(ctx context.Context, arg ListStatementLinesParams, fn func(ListStatementLinesRow) error) error
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error)
+}
+
+// Tx is a transaction begun by a Backend, its queries see the writes of the
+// transaction and nothing is kept until Commit
+type Tx interface {
+	Querier
+	StreamStatementLines(ctx context.Context, arg ListStatementLinesParams, fn func(ListStatementLinesRow) error) error
+	Commit() error
+	Rollback() error
+}
+
+// sqlBackend runs the queries on a Postgres database
+type sqlBackend struct {
+	*Queries
+	db *sql.DB
+}
+
+func (backend sqlBackend) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	tx, err := backend.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return sqlTx{Queries: New(tx), tx: tx}, nil
+}
+
+type sqlTx struct {
+	*Queries
+	tx *sql.Tx
+}
+
+func (tx sqlTx) Commit() error {
+	return tx.tx.Commit()
+}
+
+func (tx sqlTx) Rollback() error {
+	return tx.tx.Rollback()
+}
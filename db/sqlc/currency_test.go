@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCurrency(t *testing.T) {
+	currency, err := testQueries.GetCurrency(context.Background(), utils.USD)
+	require.NoError(t, err)
+	require.Equal(t, int32(840), currency.NumericCode)
+	require.Equal(t, int16(2), currency.MinorUnits)
+
+	_, err = testQueries.GetCurrency(context.Background(), "XXX")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestListCurrencies(t *testing.T) {
+	currencies, err := testQueries.ListCurrencies(context.Background())
+	require.NoError(t, err)
+
+	// the migration seeds the same rows as the built-in registry
+	require.Len(t, currencies, len(utils.DefaultCurrencies))
+	for i := 1; i < len(currencies); i++ {
+		require.Less(t, currencies[i-1].Code, currencies[i].Code)
+	}
+}
+
+func TestUpdateCurrencyEnabled(t *testing.T) {
+	currency, err := testQueries.UpdateCurrencyEnabled(context.Background(), UpdateCurrencyEnabledParams{
+		Code:    utils.JPY,
+		Enabled: true,
+	})
+	require.NoError(t, err)
+	require.True(t, currency.Enabled)
+
+	currency, err = testQueries.UpdateCurrencyEnabled(context.Background(), UpdateCurrencyEnabledParams{
+		Code:    utils.JPY,
+		Enabled: false,
+	})
+	require.NoError(t, err)
+	require.False(t, currency.Enabled)
+}
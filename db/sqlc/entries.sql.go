@@ -7,36 +7,41 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 const createEntry = `-- name: CreateEntry :one
 INSERT INTO entries (
   account_id,
-  amount
+  amount,
+  transfer_id
 ) VALUES (
-  $1, $2
-) RETURNING id, account_id, amount, created_at
+  $1, $2, $3
+) RETURNING id, account_id, amount, created_at, transfer_id
 `
 
 type CreateEntryParams struct {
-	AccountID int64 `db:"account_id" json:"account_id"`
-	Amount    int64 `db:"amount" json:"amount"`
+	AccountID  int64         `db:"account_id" json:"account_id"`
+	Amount     int64         `db:"amount" json:"amount"`
+	TransferID sql.NullInt64 `db:"transfer_id" json:"transfer_id"`
 }
 
 func (q *Queries) CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error) {
-	row := q.db.QueryRowContext(ctx, createEntry, arg.AccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createEntry, arg.AccountID, arg.Amount, arg.TransferID)
 	var i Entry
 	err := row.Scan(
 		&i.ID,
 		&i.AccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.TransferID,
 	)
 	return i, err
 }
 
 const getEntry = `-- name: GetEntry :one
-SELECT id, account_id, amount, created_at FROM entries
+SELECT id, account_id, amount, created_at, transfer_id FROM entries
 WHERE id = $1 LIMIT 1
 `
 
@@ -48,26 +53,48 @@ func (q *Queries) GetEntry(ctx context.Context, id int64) (Entry, error) {
 		&i.AccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.TransferID,
 	)
 	return i, err
 }
 
+const getOpeningBalance = `-- name: GetOpeningBalance :one
+SELECT (a.balance - COALESCE(SUM(e.amount), 0))::bigint AS opening_balance
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id AND e.created_at >= $1
+WHERE a.id = $2
+GROUP BY a.id
+`
+
+type GetOpeningBalanceParams struct {
+	FromTime  time.Time `db:"from_time" json:"from_time"`
+	AccountID int64     `db:"account_id" json:"account_id"`
+}
+
+// The balance right before from_time, derived from the current balance so
+// accounts opened with a non-zero balance are handled.
+func (q *Queries) GetOpeningBalance(ctx context.Context, arg GetOpeningBalanceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getOpeningBalance, arg.FromTime, arg.AccountID)
+	var opening_balance int64
+	err := row.Scan(&opening_balance)
+	return opening_balance, err
+}
+
 const listEntries = `-- name: ListEntries :many
-SELECT id, account_id, amount, created_at FROM entries
-WHERE account_id = $1
+SELECT id, account_id, amount, created_at, transfer_id FROM entries
+WHERE account_id = $1 AND id > $2
 ORDER BY id
-LIMIT $2
-OFFSET $3
+LIMIT $3
 `
 
 type ListEntriesParams struct {
 	AccountID int64 `db:"account_id" json:"account_id"`
+	AfterID   int64 `db:"after_id" json:"after_id"`
 	Limit     int32 `db:"limit" json:"limit"`
-	Offset    int32 `db:"offset" json:"offset"`
 }
 
 func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error) {
-	rows, err := q.db.QueryContext(ctx, listEntries, arg.AccountID, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listEntries, arg.AccountID, arg.AfterID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -80,6 +107,122 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 			&i.AccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.TransferID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEntriesBefore = `-- name: ListEntriesBefore :many
+SELECT id, account_id, amount, created_at, transfer_id FROM entries
+WHERE account_id = $1 AND id < $2
+ORDER BY id DESC
+LIMIT $3
+`
+
+type ListEntriesBeforeParams struct {
+	AccountID int64 `db:"account_id" json:"account_id"`
+	BeforeID  int64 `db:"before_id" json:"before_id"`
+	Limit     int32 `db:"limit" json:"limit"`
+}
+
+func (q *Queries) ListEntriesBefore(ctx context.Context, arg ListEntriesBeforeParams) ([]Entry, error) {
+	rows, err := q.db.QueryContext(ctx, listEntriesBefore, arg.AccountID, arg.BeforeID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Entry{}
+	for rows.Next() {
+		var i Entry
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.TransferID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStatementLines = `-- name: ListStatementLines :many
+SELECT
+  e.id,
+  e.account_id,
+  e.amount,
+  e.created_at,
+  e.transfer_id,
+  COALESCE(c.id, 0)::bigint AS counterparty_account_id,
+  COALESCE(c.owner, '')::varchar AS counterparty_owner,
+  (SUM(e.amount) OVER (ORDER BY e.created_at, e.id))::bigint AS running_total
+FROM entries e
+LEFT JOIN transfers t ON t.id = e.transfer_id
+LEFT JOIN accounts c ON c.id = CASE
+  WHEN t.from_account_id = e.account_id THEN t.to_account_id
+  ELSE t.from_account_id
+END
+WHERE e.account_id = $1
+  AND e.created_at >= $2
+  AND e.created_at < $3
+ORDER BY e.created_at, e.id
+`
+
+type ListStatementLinesParams struct {
+	AccountID int64     `db:"account_id" json:"account_id"`
+	FromTime  time.Time `db:"from_time" json:"from_time"`
+	ToTime    time.Time `db:"to_time" json:"to_time"`
+}
+
+type ListStatementLinesRow struct {
+	ID                    int64         `db:"id" json:"id"`
+	AccountID             int64         `db:"account_id" json:"account_id"`
+	Amount                int64         `db:"amount" json:"amount"`
+	CreatedAt             time.Time     `db:"created_at" json:"created_at"`
+	TransferID            sql.NullInt64 `db:"transfer_id" json:"transfer_id"`
+	CounterpartyAccountID int64         `db:"counterparty_account_id" json:"counterparty_account_id"`
+	CounterpartyOwner     string        `db:"counterparty_owner" json:"counterparty_owner"`
+	RunningTotal          int64         `db:"running_total" json:"running_total"`
+}
+
+// running_total is the sum of the entries up to and including each line,
+// add the opening balance to get the running balance.
+func (q *Queries) ListStatementLines(ctx context.Context, arg ListStatementLinesParams) ([]ListStatementLinesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listStatementLines, arg.AccountID, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListStatementLinesRow{}
+	for rows.Next() {
+		var i ListStatementLinesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.TransferID,
+			&i.CounterpartyAccountID,
+			&i.CounterpartyOwner,
+			&i.RunningTotal,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatement(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+
+	from := time.Now().Add(-time.Minute)
+
+	for _, amount := range []int64{100, 50} {
+		_, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		})
+		require.NoError(t, err)
+	}
+
+	openingBalance, err := testQueries.GetOpeningBalance(context.Background(), GetOpeningBalanceParams{
+		FromTime:  from,
+		AccountID: account1.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), openingBalance)
+
+	lines, err := testQueries.ListStatementLines(context.Background(), ListStatementLinesParams{
+		AccountID: account1.ID,
+		FromTime:  from,
+		ToTime:    time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+
+	require.Equal(t, int64(-100), lines[0].Amount)
+	require.Equal(t, int64(-100), lines[0].RunningTotal)
+	require.Equal(t, int64(-150), lines[1].RunningTotal)
+	for _, line := range lines {
+		require.True(t, line.TransferID.Valid)
+		require.Equal(t, account2.ID, line.CounterpartyAccountID)
+		require.Equal(t, account2.Owner, line.CounterpartyOwner)
+	}
+
+	var streamed []ListStatementLinesRow
+	err = store.StreamStatementLines(context.Background(), ListStatementLinesParams{
+		AccountID: account1.ID,
+		FromTime:  from,
+		ToTime:    time.Now().Add(time.Minute),
+	}, func(row ListStatementLinesRow) error {
+		streamed = append(streamed, row)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, lines, streamed)
+
+	// the window ends before the transfers
+	lines, err = testQueries.ListStatementLines(context.Background(), ListStatementLinesParams{
+		AccountID: account1.ID,
+		FromTime:  from.Add(-time.Hour),
+		ToTime:    from,
+	})
+	require.NoError(t, err)
+	require.Empty(t, lines)
+}
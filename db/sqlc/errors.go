@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Postgres SQLSTATE codes we translate into domain errors
+const (
+	ForeignKeyViolation = "23503"
+	UniqueViolation     = "23505"
+	CheckViolation      = "23514"
+)
+
+// Postgres SQLSTATE codes of transactions that can be retried, see IsRetryable
+const (
+	SerializationFailure = "40001"
+	DeadlockDetected     = "40P01"
+)
+
+var (
+	// ErrRecordNotFound is returned when a query expected a row but got none
+	ErrRecordNotFound = sql.ErrNoRows
+	// ErrUniqueViolation is returned when a write collides with a unique constraint
+	ErrUniqueViolation = errors.New("record already exists")
+	// ErrForeignKeyViolation is returned when a write references a missing record
+	ErrForeignKeyViolation = errors.New("referenced record does not exist")
+	// ErrCheckViolation is returned when a write breaks a CHECK constraint
+	ErrCheckViolation = errors.New("record violates a check constraint")
+	// ErrInsufficientFunds is returned when a debit would take an account below
+	// its overdraft limit
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrReversalExceedsTransfer is returned when reversals would move back
+	// more than the original transfer
+	ErrReversalExceedsTransfer = errors.New("reversal exceeds the amount left on the transfer")
+	// ErrReversalOfReversal is returned when reversing a transfer that is itself a reversal
+	ErrReversalOfReversal = errors.New("a reversal can't be reversed")
+	// ErrHoldNotActive is returned when capturing or voiding a hold that was
+	// already captured, voided or has expired
+	ErrHoldNotActive = errors.New("hold is no longer active")
+	// ErrCaptureExceedsHold is returned when a capture takes more than the amount held
+	ErrCaptureExceedsHold = errors.New("capture exceeds the amount held")
+	// ErrAccountFrozen is returned when money would move in or out of a frozen account
+	ErrAccountFrozen = errors.New("account is frozen")
+	// ErrAccountClosed is returned when money would move in or out of a closed account
+	ErrAccountClosed = errors.New("account is closed")
+	// ErrAccountNotEmpty is returned when closing an account that still has a
+	// balance or active holds
+	ErrAccountNotEmpty = errors.New("account must have a zero balance and no active hold to be closed")
+)
+
+// balanceCheckConstraint is the database backstop for ErrInsufficientFunds
+const balanceCheckConstraint = "accounts_balance_check"
+
+// TranslateError wraps Postgres errors with the matching domain error so
+// callers can use errors.Is without knowing about *pq.Error. The original
+// error stays in the chain for logging and errors.As.
+func TranslateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || isTranslated(err) {
+		return err
+	}
+
+	switch pqErr.Code {
+	case UniqueViolation:
+		return fmt.Errorf("%w: %w", ErrUniqueViolation, err)
+	case ForeignKeyViolation:
+		return fmt.Errorf("%w: %w", ErrForeignKeyViolation, err)
+	case CheckViolation:
+		if pqErr.Constraint == balanceCheckConstraint {
+			return fmt.Errorf("%w: %w", ErrInsufficientFunds, err)
+		}
+		return fmt.Errorf("%w: %w", ErrCheckViolation, err)
+	}
+	return err
+}
+
+// IsRetryable reports whether err aborted a transaction that may succeed
+// when run again from the start
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == SerializationFailure || pqErr.Code == DeadlockDetected
+}
+
+// ConstraintName returns the name of the constraint that caused err, if any
+func ConstraintName(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Constraint
+	}
+	return ""
+}
+
+func isTranslated(err error) bool {
+	return errors.Is(err, ErrUniqueViolation) ||
+		errors.Is(err, ErrForeignKeyViolation) ||
+		errors.Is(err, ErrCheckViolation) ||
+		errors.Is(err, ErrInsufficientFunds)
+}
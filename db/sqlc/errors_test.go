@@ -0,0 +1,57 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			name:     "UniqueViolation",
+			err:      &pq.Error{Code: UniqueViolation, Constraint: "owner_current_key"},
+			expected: ErrUniqueViolation,
+		},
+		{
+			name:     "ForeignKeyViolation",
+			err:      &pq.Error{Code: ForeignKeyViolation},
+			expected: ErrForeignKeyViolation,
+		},
+		{
+			name:     "BalanceCheckViolation",
+			err:      &pq.Error{Code: CheckViolation, Constraint: balanceCheckConstraint},
+			expected: ErrInsufficientFunds,
+		},
+		{
+			name:     "OtherCheckViolation",
+			err:      &pq.Error{Code: CheckViolation, Constraint: "users_role_check"},
+			expected: ErrCheckViolation,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			err := TranslateError(tc.err)
+			require.ErrorIs(t, err, tc.expected)
+
+			// the original error stays reachable
+			var pqErr *pq.Error
+			require.True(t, errors.As(err, &pqErr))
+			require.Equal(t, pqErr.Constraint, ConstraintName(err))
+
+			// translating twice doesn't wrap again
+			require.Equal(t, err, TranslateError(err))
+		})
+	}
+
+	require.NoError(t, TranslateError(nil))
+	require.Equal(t, ErrRecordNotFound, TranslateError(ErrRecordNotFound))
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Statuses of a Hold
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldVoided   = "voided"
+	HoldExpired  = "expired"
+)
+
+// CreateHoldParams reserves Amount on AccountID, in its currency, for a
+// later capture by ToAccountID
+type CreateHoldParams struct {
+	AccountID   int64     `json:"account_id"`
+	ToAccountID int64     `json:"to_account_id"`
+	Amount      int64     `json:"amount"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	// Idempotency is optional, when set the hold is stored under its key
+	Idempotency *IdempotencyParams `json:"-"`
+}
+
+// CreateHold reserves funds on an account. The funds stay on the account but
+// are no longer available to transfers until the hold is captured, voided or
+// expires.
+func (store *SQLStore) CreateHold(ctx context.Context, arg CreateHoldParams) (Hold, error) {
+	var hold Hold
+	err := store.execTx(ctx, nil, func(q Querier) error {
+		// locking the account serializes holds and transfers competing for its funds
+		account, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := ensureActive(account); err != nil {
+			return err
+		}
+		if err := ensureAvailable(ctx, q, account, arg.Amount); err != nil {
+			return err
+		}
+
+		hold, err = q.InsertHold(ctx, InsertHoldParams{
+			AccountID:   arg.AccountID,
+			ToAccountID: arg.ToAccountID,
+			Amount:      arg.Amount,
+			Currency:    account.Currency,
+			ExpiresAt:   arg.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		if arg.Idempotency != nil {
+			return saveIdempotentResponse(ctx, q, *arg.Idempotency, hold)
+		}
+		return nil
+	})
+	return hold, err
+}
+
+// CaptureHoldParams settles a hold with a transfer to its to_account_id
+type CaptureHoldParams struct {
+	HoldID int64 `json:"hold_id"`
+	// Amount is what the capture takes in the currency of the hold, zero
+	// captures the whole hold. What isn't captured is released.
+	Amount int64 `json:"amount"`
+	// ToAmount and ExchangeRate are required when the destination account
+	// has another currency, see TransferTxParams
+	ToAmount     int64  `json:"to_amount"`
+	ExchangeRate string `json:"exchange_rate"`
+	// Idempotency is optional, when set the result is stored under its key
+	Idempotency *IdempotencyParams `json:"-"`
+}
+
+type CaptureHoldResult struct {
+	Hold Hold `json:"hold"`
+	TransferTxResult
+}
+
+// CaptureHold books the transfer of a hold, fully or partially, and closes
+// the hold. A hold is captured at most once.
+func (store *SQLStore) CaptureHold(ctx context.Context, arg CaptureHoldParams) (CaptureHoldResult, error) {
+	var result CaptureHoldResult
+	err := store.execTx(ctx, store.transferTx(), func(q Querier) error {
+		// locking the hold serializes captures and voids of the same hold
+		hold, err := q.GetHoldForUpdate(ctx, arg.HoldID)
+		if err != nil {
+			return err
+		}
+		if hold.Status != HoldActive || !hold.ExpiresAt.After(time.Now()) {
+			return fmt.Errorf("%w: hold [%d] is %s", ErrHoldNotActive, hold.ID, HoldStatus(hold))
+		}
+
+		amount := arg.Amount
+		if amount == 0 {
+			amount = hold.Amount
+		}
+		if amount <= 0 || amount > hold.Amount {
+			return fmt.Errorf("%w: hold [%d] has %d, capture needs %d",
+				ErrCaptureExceedsHold, hold.ID, hold.Amount, amount)
+		}
+
+		fromAccount, toAccount, err := lockAccounts(ctx, q, hold.AccountID, hold.ToAccountID)
+		if err != nil {
+			return err
+		}
+
+		toAmount, exchangeRate := arg.ToAmount, arg.ExchangeRate
+		if fromAccount.Currency == toAccount.Currency {
+			toAmount, exchangeRate = amount, "1"
+		} else if toAmount <= 0 || exchangeRate == "" {
+			return fmt.Errorf("capture from %s to %s needs a destination amount and an exchange rate",
+				fromAccount.Currency, toAccount.Currency)
+		}
+
+		// the hold stops reserving funds before the transfer takes them
+		if _, err := q.SetHoldCaptured(ctx, SetHoldCapturedParams{
+			ID:             hold.ID,
+			CapturedAmount: amount,
+		}); err != nil {
+			return err
+		}
+
+		result.TransferTxResult, err = bookTransfer(ctx, q, fromAccount, toAccount, CreateTransferParams{
+			FromAccountID: hold.AccountID,
+			ToAccountID:   hold.ToAccountID,
+			Amount:        amount,
+			FromCurrency:  fromAccount.Currency,
+			ToCurrency:    toAccount.Currency,
+			ToAmount:      toAmount,
+			ExchangeRate:  exchangeRate,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Hold, err = q.SetHoldTransfer(ctx, SetHoldTransferParams{
+			ID:         hold.ID,
+			TransferID: sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		if arg.Idempotency != nil {
+			return saveIdempotentResponse(ctx, q, *arg.Idempotency, result)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// VoidHold releases the funds of an active hold without moving them
+func (store *SQLStore) VoidHold(ctx context.Context, holdID int64) (Hold, error) {
+	hold, err := store.SetHoldVoided(ctx, holdID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return hold, err
+	}
+
+	// no active hold, tell a missing hold from one that is already closed
+	hold, err = store.GetHold(ctx, holdID)
+	if err != nil {
+		return hold, err
+	}
+	return hold, fmt.Errorf("%w: hold [%d] is %s", ErrHoldNotActive, hold.ID, HoldStatus(hold))
+}
+
+// HoldStatus is the status of a hold as the client sees it, an active hold
+// past its expiry is expired even before the executor marks it so
+func HoldStatus(hold Hold) string {
+	if hold.Status == HoldActive && !hold.ExpiresAt.After(time.Now()) {
+		return HoldExpired
+	}
+	return hold.Status
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHoldReservesFunds(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+
+	hold, err := store.CreateHold(context.Background(), CreateHoldParams{
+		AccountID:   account1.ID,
+		ToAccountID: account2.ID,
+		Amount:      800,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, HoldActive, hold.Status)
+	require.Equal(t, utils.USD, hold.Currency)
+
+	held, err := testQueries.GetHeldAmount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(800), held)
+
+	// the balance is untouched but only 200 are left to spend
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        300,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	_, err = store.CreateHold(context.Background(), CreateHoldParams{
+		AccountID:   account1.ID,
+		ToAccountID: account2.ID,
+		Amount:      300,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	voided, err := store.VoidHold(context.Background(), hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldVoided, voided.Status)
+
+	_, err = store.VoidHold(context.Background(), hold.ID)
+	require.ErrorIs(t, err, ErrHoldNotActive)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        300,
+	})
+	require.NoError(t, err)
+}
+
+func TestCaptureHold(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+
+	hold, err := store.CreateHold(context.Background(), CreateHoldParams{
+		AccountID:   account1.ID,
+		ToAccountID: account2.ID,
+		Amount:      500,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	_, err = store.CaptureHold(context.Background(), CaptureHoldParams{HoldID: hold.ID, Amount: 600})
+	require.ErrorIs(t, err, ErrCaptureExceedsHold)
+
+	result, err := store.CaptureHold(context.Background(), CaptureHoldParams{HoldID: hold.ID, Amount: 200})
+	require.NoError(t, err)
+	require.Equal(t, HoldCaptured, result.Hold.Status)
+	require.Equal(t, int64(200), result.Hold.CapturedAmount)
+	require.Equal(t, result.Transfer.ID, result.Hold.TransferID.Int64)
+	require.Equal(t, int64(800), result.FromAccount.Balance)
+	require.Equal(t, int64(1200), result.ToAccount.Balance)
+
+	// the rest of the hold is released
+	held, err := testQueries.GetHeldAmount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Zero(t, held)
+
+	_, err = store.CaptureHold(context.Background(), CaptureHoldParams{HoldID: hold.ID})
+	require.ErrorIs(t, err, ErrHoldNotActive)
+}
+
+func TestExpireHolds(t *testing.T) {
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := createRandomAccountInCurrency(t, utils.USD)
+
+	hold, err := testQueries.InsertHold(context.Background(), InsertHoldParams{
+		AccountID:   account1.ID,
+		ToAccountID: account2.ID,
+		Amount:      100,
+		Currency:    utils.USD,
+		ExpiresAt:   time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, HoldExpired, HoldStatus(hold))
+
+	// an expired hold stops reserving funds before the executor gets to it
+	held, err := testQueries.GetHeldAmount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Zero(t, held)
+
+	expired, err := testQueries.ExpireHolds(context.Background(), 1000)
+	require.NoError(t, err)
+	ids := map[int64]Hold{}
+	for _, h := range expired {
+		ids[h.ID] = h
+	}
+	require.Contains(t, ids, hold.ID)
+	require.Equal(t, HoldExpired, ids[hold.ID].Status)
+}
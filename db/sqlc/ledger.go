@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Kinds of LedgerDiscrepancy
+const (
+	// DiscrepancyBalance is an account whose balance isn't the sum of its entries
+	DiscrepancyBalance = "balance_mismatch"
+	// DiscrepancyTransfer is a transfer whose entries don't match its amounts
+	DiscrepancyTransfer = "transfer_mismatch"
+)
+
+type VerifyLedgerParams struct {
+	// Snapshot runs every check in one read-only repeatable read transaction,
+	// so transfers committed while the checker runs can't show up as drift
+	Snapshot bool `json:"snapshot"`
+}
+
+// LedgerDiscrepancy is a single problem found by VerifyLedger, amounts in
+// Detail are in minor units of the account or transfer currency
+type LedgerDiscrepancy struct {
+	Kind       string `json:"kind"`
+	AccountID  int64  `json:"account_id,omitempty"`
+	TransferID int64  `json:"transfer_id,omitempty"`
+	Detail     string `json:"detail"`
+}
+
+type LedgerReport struct {
+	CheckedAt     time.Time           `json:"checked_at"`
+	Snapshot      bool                `json:"snapshot"`
+	Accounts      int64               `json:"accounts"`
+	Entries       int64               `json:"entries"`
+	Transfers     int64               `json:"transfers"`
+	Discrepancies []LedgerDiscrepancy `json:"discrepancies"`
+}
+
+// OK reports whether the ledger is consistent
+func (report LedgerReport) OK() bool {
+	return len(report.Discrepancies) == 0
+}
+
+// VerifyLedger checks that every account balance is the sum of its entries and
+// that every transfer is booked as exactly one debit and one matching credit.
+// It only reads, discrepancies are reported and never fixed.
+func (store *SQLStore) VerifyLedger(ctx context.Context, arg VerifyLedgerParams) (LedgerReport, error) {
+	if !arg.Snapshot {
+		return verifyLedger(ctx, store.Backend, arg)
+	}
+
+	tx, err := store.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return LedgerReport{}, err
+	}
+	// nothing is written, rolling back just releases the snapshot
+	defer tx.Rollback()
+
+	return verifyLedger(ctx, tx, arg)
+}
+
+func verifyLedger(ctx context.Context, q Querier, arg VerifyLedgerParams) (LedgerReport, error) {
+	report := LedgerReport{
+		CheckedAt:     time.Now(),
+		Snapshot:      arg.Snapshot,
+		Discrepancies: []LedgerDiscrepancy{},
+	}
+
+	counts, err := q.GetLedgerCounts(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.Accounts, report.Entries, report.Transfers = counts.Accounts, counts.Entries, counts.Transfers
+
+	accounts, err := q.ListBalanceMismatches(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, account := range accounts {
+		report.Discrepancies = append(report.Discrepancies, LedgerDiscrepancy{
+			Kind:      DiscrepancyBalance,
+			AccountID: account.ID,
+			Detail: fmt.Sprintf("balance is %d %s but entries add up to %d, off by %d",
+				account.Balance, account.Currency, account.EntriesTotal, account.Balance-account.EntriesTotal),
+		})
+	}
+
+	transfers, err := q.ListTransferMismatches(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, transfer := range transfers {
+		report.Discrepancies = append(report.Discrepancies, LedgerDiscrepancy{
+			Kind:       DiscrepancyTransfer,
+			TransferID: transfer.ID,
+			Detail:     transferMismatchDetail(transfer),
+		})
+	}
+	return report, nil
+}
+
+func transferMismatchDetail(transfer ListTransferMismatchesRow) string {
+	switch {
+	case transfer.EntryCount != 2:
+		return fmt.Sprintf("has %d entries instead of 2", transfer.EntryCount)
+	case transfer.Debited != -transfer.Amount:
+		return fmt.Sprintf("debits %d %s from account [%d] instead of %d",
+			-transfer.Debited, transfer.FromCurrency, transfer.FromAccountID, transfer.Amount)
+	case transfer.Credited != transfer.ToAmount:
+		return fmt.Sprintf("credits %d %s to account [%d] instead of %d",
+			transfer.Credited, transfer.ToCurrency, transfer.ToAccountID, transfer.ToAmount)
+	}
+	return fmt.Sprintf("moves %d %s out but %d %s in, entries don't sum to zero",
+		transfer.Amount, transfer.FromCurrency, transfer.ToAmount, transfer.ToCurrency)
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyLedger(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	// an entry booked without touching the balance breaks both checks
+	_, err = testQueries.CreateEntry(context.Background(), CreateEntryParams{
+		AccountID:  account1.ID,
+		Amount:     -5,
+		TransferID: sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+	})
+	require.NoError(t, err)
+
+	for _, snapshot := range []bool{false, true} {
+		report, err := store.VerifyLedger(context.Background(), VerifyLedgerParams{Snapshot: snapshot})
+		require.NoError(t, err)
+		require.False(t, report.OK())
+		require.Equal(t, snapshot, report.Snapshot)
+		require.NotZero(t, report.Accounts)
+		require.NotZero(t, report.Transfers)
+
+		// accounts created by the other tests are funded without entries, so
+		// only look for the discrepancies of this test
+		var balance, transfer *LedgerDiscrepancy
+		for i, discrepancy := range report.Discrepancies {
+			switch {
+			case discrepancy.Kind == DiscrepancyBalance && discrepancy.AccountID == account1.ID:
+				balance = &report.Discrepancies[i]
+			case discrepancy.Kind == DiscrepancyTransfer && discrepancy.TransferID == result.Transfer.ID:
+				transfer = &report.Discrepancies[i]
+			}
+		}
+		require.NotNil(t, balance)
+		require.NotNil(t, transfer)
+		require.Equal(t, "has 3 entries instead of 2", transfer.Detail)
+	}
+}
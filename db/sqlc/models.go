@@ -5,7 +5,11 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Account struct {
@@ -14,6 +18,21 @@ type Account struct {
 	Balance   int64     `db:"balance" json:"balance"`
 	Currency  string    `db:"currency" json:"currency"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	// how far below zero the balance may go
+	OverdraftLimit int64 `db:"overdraft_limit" json:"overdraft_limit"`
+	// active, frozen or closed, only active accounts can send or receive money
+	Status string `db:"status" json:"status"`
+}
+
+type Currency struct {
+	// ISO 4217 alphabetic code
+	Code        string `db:"code" json:"code"`
+	NumericCode int32  `db:"numeric_code" json:"numeric_code"`
+	// number of decimal places, amounts are stored in these units
+	MinorUnits int16     `db:"minor_units" json:"minor_units"`
+	Symbol     string    `db:"symbol" json:"symbol"`
+	Enabled    bool      `db:"enabled" json:"enabled"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
 }
 
 type Entry struct {
@@ -22,14 +41,154 @@ type Entry struct {
 	// can be negative or positive
 	Amount    int64     `db:"amount" json:"amount"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	// transfer that booked this entry, null for other movements
+	TransferID sql.NullInt64 `db:"transfer_id" json:"transfer_id"`
+}
+
+type Hold struct {
+	ID int64 `db:"id" json:"id"`
+	// account whose funds are reserved
+	AccountID int64 `db:"account_id" json:"account_id"`
+	// account credited when the hold is captured
+	ToAccountID int64 `db:"to_account_id" json:"to_account_id"`
+	// must be positive, in currency of account_id
+	Amount   int64  `db:"amount" json:"amount"`
+	Currency string `db:"currency" json:"currency"`
+	// active, captured, voided or expired
+	Status string `db:"status" json:"status"`
+	// what the capture took, the rest is released
+	CapturedAmount int64         `db:"captured_amount" json:"captured_amount"`
+	TransferID     sql.NullInt64 `db:"transfer_id" json:"transfer_id"`
+	// an active hold stops reserving funds at that time
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type IdempotencyKey struct {
+	Username     string          `db:"username" json:"username"`
+	Key          string          `db:"key" json:"key"`
+	RequestPath  string          `db:"request_path" json:"request_path"`
+	RequestHash  string          `db:"request_hash" json:"request_hash"`
+	ResponseCode int32           `db:"response_code" json:"response_code"`
+	ResponseBody json.RawMessage `db:"response_body" json:"response_body"`
+	CreatedAt    time.Time       `db:"created_at" json:"created_at"`
+}
+
+type ScheduledTransfer struct {
+	ID            int64  `db:"id" json:"id"`
+	Owner         string `db:"owner" json:"owner"`
+	FromAccountID int64  `db:"from_account_id" json:"from_account_id"`
+	ToAccountID   int64  `db:"to_account_id" json:"to_account_id"`
+	// must be positive, in currency
+	Amount    int64     `db:"amount" json:"amount"`
+	Currency  string    `db:"currency" json:"currency"`
+	ExecuteAt time.Time `db:"execute_at" json:"execute_at"`
+	// pending, running, completed, failed or cancelled
+	Status   string `db:"status" json:"status"`
+	Attempts int32  `db:"attempts" json:"attempts"`
+	// when the executor picks the row up, pushed back on retries and while running
+	NextAttemptAt time.Time `db:"next_attempt_at" json:"next_attempt_at"`
+	LastError     string    `db:"last_error" json:"last_error"`
+	// transfer booked by the executor once completed
+	TransferID sql.NullInt64 `db:"transfer_id" json:"transfer_id"`
+	CreatedAt  time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time     `db:"updated_at" json:"updated_at"`
+}
+
+type Session struct {
+	ID           uuid.UUID `db:"id" json:"id"`
+	Username     string    `db:"username" json:"username"`
+	RefreshToken string    `db:"refresh_token" json:"refresh_token"`
+	UserAgent    string    `db:"user_agent" json:"user_agent"`
+	ClientIp     string    `db:"client_ip" json:"client_ip"`
+	IsBlocked    bool      `db:"is_blocked" json:"is_blocked"`
+	ExpiresAt    time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+type StandingOrder struct {
+	ID            int64  `db:"id" json:"id"`
+	Owner         string `db:"owner" json:"owner"`
+	FromAccountID int64  `db:"from_account_id" json:"from_account_id"`
+	ToAccountID   int64  `db:"to_account_id" json:"to_account_id"`
+	// must be positive, in currency
+	Amount   int64  `db:"amount" json:"amount"`
+	Currency string `db:"currency" json:"currency"`
+	// cron expression evaluated in UTC
+	Schedule string `db:"schedule" json:"schedule"`
+	// what happens to occurrences missed while the executor was down: all, latest or skip
+	CatchUp        string        `db:"catch_up" json:"catch_up"`
+	StartAt        time.Time     `db:"start_at" json:"start_at"`
+	EndAt          sql.NullTime  `db:"end_at" json:"end_at"`
+	MaxOccurrences sql.NullInt32 `db:"max_occurrences" json:"max_occurrences"`
+	// occurrences that came due so far, whatever their outcome
+	Occurrences int32 `db:"occurrences" json:"occurrences"`
+	// active, paused, completed or cancelled
+	Status string `db:"status" json:"status"`
+	// next occurrence of the schedule
+	NextRunAt time.Time `db:"next_run_at" json:"next_run_at"`
+	// set while an executor works on the order
+	LockedUntil sql.NullTime `db:"locked_until" json:"locked_until"`
+	CreatedAt   time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time    `db:"updated_at" json:"updated_at"`
+}
+
+type StandingOrderRun struct {
+	ID              int64     `db:"id" json:"id"`
+	StandingOrderID int64     `db:"standing_order_id" json:"standing_order_id"`
+	ScheduledFor    time.Time `db:"scheduled_for" json:"scheduled_for"`
+	// completed, failed or skipped
+	Status     string        `db:"status" json:"status"`
+	TransferID sql.NullInt64 `db:"transfer_id" json:"transfer_id"`
+	Error      string        `db:"error" json:"error"`
+	CreatedAt  time.Time     `db:"created_at" json:"created_at"`
 }
 
 type Transfer struct {
 	ID            int64 `db:"id" json:"id"`
 	FromAccountID int64 `db:"from_account_id" json:"from_account_id"`
 	ToAccountID   int64 `db:"to_account_id" json:"to_account_id"`
-	// must be positive
-	Amount    int64     `db:"amount" json:"amount"`
+	// must be positive, in from_currency
+	Amount       int64     `db:"amount" json:"amount"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	FromCurrency string    `db:"from_currency" json:"from_currency"`
+	ToCurrency   string    `db:"to_currency" json:"to_currency"`
+	// must be positive, in to_currency
+	ToAmount int64 `db:"to_amount" json:"to_amount"`
+	// to_currency units per from_currency unit
+	ExchangeRate string `db:"exchange_rate" json:"exchange_rate"`
+	// transfer this one compensates, it moves money back from to_account_id to from_account_id
+	ReversalOf sql.NullInt64 `db:"reversal_of" json:"reversal_of"`
+}
+
+type TransferBatch struct {
+	ID    int64  `db:"id" json:"id"`
+	Owner string `db:"owner" json:"owner"`
+	// atomic books every item or none, best_effort books the items that can be
+	Mode string `db:"mode" json:"mode"`
+	// completed, partial or failed
+	Status      string    `db:"status" json:"status"`
+	ItemCount   int32     `db:"item_count" json:"item_count"`
+	FailedCount int32     `db:"failed_count" json:"failed_count"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+type TransferBatchItem struct {
+	ID      int64 `db:"id" json:"id"`
+	BatchID int64 `db:"batch_id" json:"batch_id"`
+	// index of the item in the request
+	Position      int32 `db:"position" json:"position"`
+	FromAccountID int64 `db:"from_account_id" json:"from_account_id"`
+	ToAccountID   int64 `db:"to_account_id" json:"to_account_id"`
+	// must be positive, in currency
+	Amount   int64  `db:"amount" json:"amount"`
+	Currency string `db:"currency" json:"currency"`
+	Status   string `db:"status" json:"status"`
+	// transfer booked for the item once completed
+	TransferID sql.NullInt64 `db:"transfer_id" json:"transfer_id"`
+	// why a failed item was not booked
+	Error     string    `db:"error" json:"error"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
 
@@ -40,4 +199,5 @@ type User struct {
 	Email             string    `db:"email" json:"email"`
 	PasswordChangedAt time.Time `db:"password_changed_at" json:"password_changed_at"`
 	CreatedAt         time.Time `db:"created_at" json:"created_at"`
+	Role              string    `db:"role" json:"role"`
 }
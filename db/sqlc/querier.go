@@ -6,27 +6,118 @@ package db
 
 import (
 	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
 )
 
 type Querier interface {
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	// records the progress of the executor. An order paused or cancelled in the
+	// meantime keeps its status, locked_until is null once the executor is done.
+	AdvanceStandingOrder(ctx context.Context, arg AdvanceStandingOrderParams) (StandingOrder, error)
+	BlockSession(ctx context.Context, id uuid.UUID) (Session, error)
+	BlockUserSessions(ctx context.Context, username string) error
+	// only pending transfers can be cancelled, no row means the executor got there first.
+	CancelScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error)
+	CancelStandingOrder(ctx context.Context, id int64) (StandingOrder, error)
 	ChangePassword(ctx context.Context, arg ChangePasswordParams) error
+	// marks due transfers as running for lease_seconds, rows locked by another
+	// executor are skipped. Running rows whose lease expired are picked up again.
+	ClaimDueScheduledTransfers(ctx context.Context, arg ClaimDueScheduledTransfersParams) ([]ScheduledTransfer, error)
+	// locks due orders for lease_seconds, rows locked by another executor are
+	// skipped. Orders whose lease expired are picked up again.
+	ClaimDueStandingOrders(ctx context.Context, arg ClaimDueStandingOrdersParams) ([]StandingOrder, error)
+	CompleteScheduledTransfer(ctx context.Context, arg CompleteScheduledTransferParams) (ScheduledTransfer, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (IdempotencyKey, error)
+	CreateScheduledTransfer(ctx context.Context, arg CreateScheduledTransferParams) (ScheduledTransfer, error)
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateStandingOrder(ctx context.Context, arg CreateStandingOrderParams) (StandingOrder, error)
+	// an occurrence already recorded by an earlier attempt is left untouched.
+	CreateStandingOrderRun(ctx context.Context, arg CreateStandingOrderRunParams) error
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
+	CreateTransferBatch(ctx context.Context, arg CreateTransferBatchParams) (TransferBatch, error)
+	CreateTransferBatchItem(ctx context.Context, arg CreateTransferBatchItemParams) (TransferBatchItem, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
-	DeleteAccount(ctx context.Context, id int64) error
-	DeleteTransfer(ctx context.Context, id int64) error
+	// releases the active holds past their expiry, rows locked by a capture or
+	// another executor are left for the next run.
+	ExpireHolds(ctx context.Context, limit int32) ([]Hold, error)
+	// records a failed attempt, status is pending to retry at next_attempt_at or failed to give up.
+	FailScheduledTransfer(ctx context.Context, arg FailScheduledTransferParams) (ScheduledTransfer, error)
+	FreezeAccount(ctx context.Context, id int64) (Account, error)
 	GetAccount(ctx context.Context, id int64) (Account, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	// locks the accounts in id order, concurrent callers locking overlapping
+	// sets can't deadlock.
+	GetAccountsForUpdate(ctx context.Context, ids []int64) ([]Account, error)
+	GetCurrency(ctx context.Context, code string) (Currency, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	// sums the funds reserved on an account, a hold past its expiry doesn't
+	// count even before the executor marks it expired.
+	GetHeldAmount(ctx context.Context, accountID int64) (int64, error)
+	GetHold(ctx context.Context, id int64) (Hold, error)
+	GetHoldForUpdate(ctx context.Context, id int64) (Hold, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
+	GetLedgerCounts(ctx context.Context) (GetLedgerCountsRow, error)
+	// The balance right before from_time, derived from the current balance so
+	// accounts opened with a non-zero balance are handled.
+	GetOpeningBalance(ctx context.Context, arg GetOpeningBalanceParams) (int64, error)
+	// sums the reversals of a transfer, amount is in the original to_currency
+	// and to_amount in the original from_currency.
+	GetReversedTotals(ctx context.Context, reversalOf sql.NullInt64) (GetReversedTotalsRow, error)
+	GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error)
+	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
+	GetStandingOrder(ctx context.Context, id int64) (StandingOrder, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
+	GetTransferBatch(ctx context.Context, id int64) (TransferBatch, error)
+	GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	InsertHold(ctx context.Context, arg InsertHoldParams) (Hold, error)
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	ListAccountsBefore(ctx context.Context, arg ListAccountsBeforeParams) ([]Account, error)
+	ListAccountsByOwner(ctx context.Context, arg ListAccountsByOwnerParams) ([]Account, error)
+	ListAccountsByOwnerBefore(ctx context.Context, arg ListAccountsByOwnerBeforeParams) ([]Account, error)
+	// accounts whose balance isn't the sum of their entries.
+	ListBalanceMismatches(ctx context.Context) ([]ListBalanceMismatchesRow, error)
+	ListCurrencies(ctx context.Context) ([]Currency, error)
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
+	ListEntriesBefore(ctx context.Context, arg ListEntriesBeforeParams) ([]Entry, error)
+	ListHoldsByAccount(ctx context.Context, arg ListHoldsByAccountParams) ([]Hold, error)
+	ListHoldsByAccountAfter(ctx context.Context, arg ListHoldsByAccountAfterParams) ([]Hold, error)
+	ListScheduledTransfersByOwner(ctx context.Context, arg ListScheduledTransfersByOwnerParams) ([]ScheduledTransfer, error)
+	ListScheduledTransfersByOwnerBefore(ctx context.Context, arg ListScheduledTransfersByOwnerBeforeParams) ([]ScheduledTransfer, error)
+	ListStandingOrderRuns(ctx context.Context, arg ListStandingOrderRunsParams) ([]StandingOrderRun, error)
+	ListStandingOrderRunsAfter(ctx context.Context, arg ListStandingOrderRunsAfterParams) ([]StandingOrderRun, error)
+	ListStandingOrdersByOwner(ctx context.Context, arg ListStandingOrdersByOwnerParams) ([]StandingOrder, error)
+	ListStandingOrdersByOwnerBefore(ctx context.Context, arg ListStandingOrdersByOwnerBeforeParams) ([]StandingOrder, error)
+	// running_total is the sum of the entries up to and including each line,
+	// add the opening balance to get the running balance.
+	ListStatementLines(ctx context.Context, arg ListStatementLinesParams) ([]ListStatementLinesRow, error)
+	ListTransferBatchItems(ctx context.Context, batchID int64) ([]TransferBatchItem, error)
+	// transfers that don't have exactly one debit of amount on the sender and
+	// one credit of to_amount on the recipient, or whose amounts differ
+	// although both sides share a currency.
+	ListTransferMismatches(ctx context.Context) ([]ListTransferMismatchesRow, error)
+	// walks the history of an account newest first, amounts are compared
+	// in the account's own currency and to_time is exclusive.
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
-	UpadateAccount(ctx context.Context, arg UpadateAccountParams) (Account, error)
-	UpdateTransfer(ctx context.Context, arg UpdateTransferParams) error
+	// walks the same history back towards the newest transfer, oldest first.
+	ListTransfersAfter(ctx context.Context, arg ListTransfersAfterParams) ([]Transfer, error)
+	ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error)
+	ListUsersBefore(ctx context.Context, arg ListUsersBeforeParams) ([]User, error)
+	PauseStandingOrder(ctx context.Context, id int64) (StandingOrder, error)
+	// occurrences that came due while paused are not caught up, next_run_at is
+	// the first one after the order is resumed.
+	ResumeStandingOrder(ctx context.Context, arg ResumeStandingOrderParams) (StandingOrder, error)
+	SetAccountClosed(ctx context.Context, id int64) (Account, error)
+	SetHoldCaptured(ctx context.Context, arg SetHoldCapturedParams) (Hold, error)
+	SetHoldTransfer(ctx context.Context, arg SetHoldTransferParams) (Hold, error)
+	SetHoldVoided(ctx context.Context, id int64) (Hold, error)
+	UnfreezeAccount(ctx context.Context, id int64) (Account, error)
+	UpdateAccountOverdraftLimit(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error)
+	UpdateCurrencyEnabled(ctx context.Context, arg UpdateCurrencyEnabledParams) (Currency, error)
 	UpdateUser(ctx context.Context, arg UpdateUserParams) error
 }
 
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+)
+
+// ReverseTransferTxParams describes a full or partial reversal of a transfer.
+// The reversal moves money back from the original recipient to the sender.
+type ReverseTransferTxParams struct {
+	TransferID int64 `json:"transfer_id"`
+	// Amount is taken back from the recipient in the original to_currency,
+	// zero reverses whatever hasn't been reversed yet
+	Amount int64 `json:"amount"`
+	// Idempotency is optional, when set the result is stored under its key
+	Idempotency *IdempotencyParams `json:"-"`
+}
+
+// ReverseTransferTx books a compensating transfer linked to the original one
+// through reversal_of. The original transfer is never modified, reversals can
+// add up to at most its amount. The returned Transfer is the reversal.
+func (store *SQLStore) ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (TransferTxResult, error) {
+	var result TransferTxResult
+	err := store.execTx(ctx, store.transferTx(), func(q Querier) error {
+		// locking the original serializes concurrent reversals of the same transfer
+		original, err := q.GetTransferForUpdate(ctx, arg.TransferID)
+		if err != nil {
+			return err
+		}
+		if original.ReversalOf.Valid {
+			return fmt.Errorf("%w: transfer [%d] reverses transfer [%d]",
+				ErrReversalOfReversal, original.ID, original.ReversalOf.Int64)
+		}
+
+		totals, err := q.GetReversedTotals(ctx, sql.NullInt64{Int64: original.ID, Valid: true})
+		if err != nil {
+			return err
+		}
+
+		remaining := original.ToAmount - totals.ReversedAmount
+		amount := arg.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount <= 0 || amount > remaining {
+			return fmt.Errorf("%w: transfer [%d] has %d left to reverse, reversal needs %d",
+				ErrReversalExceedsTransfer, original.ID, remaining, amount)
+		}
+
+		// the sender is refunded at the original rate, the last reversal refunds
+		// whatever is left so that a fully reversed transfer nets to zero
+		refund := original.Amount - totals.RefundedAmount
+		if amount < remaining {
+			refund = scaleAmount(amount, original.Amount, original.ToAmount)
+		}
+		if refund <= 0 {
+			return fmt.Errorf("%w: %d is too small to be refunded in %s",
+				ErrReversalExceedsTransfer, amount, original.FromCurrency)
+		}
+
+		toAccount, fromAccount, err := lockAccounts(ctx, q, original.ToAccountID, original.FromAccountID)
+		if err != nil {
+			return err
+		}
+
+		result, err = bookTransfer(ctx, q, toAccount, fromAccount, CreateTransferParams{
+			FromAccountID: original.ToAccountID,
+			ToAccountID:   original.FromAccountID,
+			Amount:        amount,
+			FromCurrency:  original.ToCurrency,
+			ToCurrency:    original.FromCurrency,
+			ToAmount:      refund,
+			ExchangeRate:  inverseRate(original.ExchangeRate),
+			ReversalOf:    sql.NullInt64{Int64: original.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		if arg.Idempotency != nil {
+			return saveIdempotentResponse(ctx, q, *arg.Idempotency, result)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// scaleAmount returns amount * numerator / denominator rounded half up
+func scaleAmount(amount int64, numerator int64, denominator int64) int64 {
+	value := new(big.Int).Mul(big.NewInt(amount), big.NewInt(numerator))
+	value.Mul(value, big.NewInt(2))
+	value.Add(value, big.NewInt(denominator))
+	value.Quo(value, new(big.Int).Mul(big.NewInt(denominator), big.NewInt(2)))
+	return value.Int64()
+}
+
+// inverseRate turns the rate of a transfer into the rate of its reversal,
+// with the scale of the exchange_rate column
+func inverseRate(rate string) string {
+	value, ok := new(big.Rat).SetString(rate)
+	if !ok || value.Sign() == 0 {
+		return rate
+	}
+	return value.Inv(value).FloatString(10)
+}
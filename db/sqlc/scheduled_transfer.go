@@ -0,0 +1,10 @@
+package db
+
+// Statuses of a ScheduledTransfer
+const (
+	ScheduledTransferPending   = "pending"
+	ScheduledTransferRunning   = "running"
+	ScheduledTransferCompleted = "completed"
+	ScheduledTransferFailed    = "failed"
+	ScheduledTransferCancelled = "cancelled"
+)
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomScheduledTransfer(t *testing.T, executeAt time.Time) ScheduledTransfer {
+	account1 := createRandomAccountInCurrency(t, utils.USD)
+	account2 := createRandomAccountInCurrency(t, utils.USD)
+
+	arg := CreateScheduledTransferParams{
+		Owner:         account1.Owner,
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+		Currency:      utils.USD,
+		ExecuteAt:     executeAt,
+	}
+	scheduled, err := testQueries.CreateScheduledTransfer(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferPending, scheduled.Status)
+	require.Zero(t, scheduled.Attempts)
+	require.WithinDuration(t, executeAt, scheduled.NextAttemptAt, time.Second)
+	return scheduled
+}
+
+func TestClaimDueScheduledTransfers(t *testing.T) {
+	due := createRandomScheduledTransfer(t, time.Now().Add(-time.Minute))
+	future := createRandomScheduledTransfer(t, time.Now().Add(time.Hour))
+
+	claimed, err := testQueries.ClaimDueScheduledTransfers(context.Background(), ClaimDueScheduledTransfersParams{
+		LeaseSeconds: 60,
+		Limit:        1000,
+	})
+	require.NoError(t, err)
+
+	ids := map[int64]ScheduledTransfer{}
+	for _, scheduled := range claimed {
+		ids[scheduled.ID] = scheduled
+	}
+	require.Contains(t, ids, due.ID)
+	require.NotContains(t, ids, future.ID)
+	require.Equal(t, ScheduledTransferRunning, ids[due.ID].Status)
+	require.Equal(t, int32(1), ids[due.ID].Attempts)
+
+	// running rows stay hidden until their lease runs out
+	claimed, err = testQueries.ClaimDueScheduledTransfers(context.Background(), ClaimDueScheduledTransfersParams{
+		LeaseSeconds: 60,
+		Limit:        1000,
+	})
+	require.NoError(t, err)
+	for _, scheduled := range claimed {
+		require.NotEqual(t, due.ID, scheduled.ID)
+	}
+
+	// a running transfer can't be cancelled anymore
+	_, err = testQueries.CancelScheduledTransfer(context.Background(), due.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	failed, err := testQueries.FailScheduledTransfer(context.Background(), FailScheduledTransferParams{
+		ID:            due.ID,
+		Status:        ScheduledTransferFailed,
+		LastError:     "insufficient funds",
+		NextAttemptAt: time.Now(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferFailed, failed.Status)
+	require.Equal(t, "insufficient funds", failed.LastError)
+}
+
+func TestCancelScheduledTransfer(t *testing.T) {
+	scheduled := createRandomScheduledTransfer(t, time.Now().Add(time.Hour))
+
+	cancelled, err := testQueries.CancelScheduledTransfer(context.Background(), scheduled.ID)
+	require.NoError(t, err)
+	require.Equal(t, ScheduledTransferCancelled, cancelled.Status)
+
+	_, err = testQueries.CancelScheduledTransfer(context.Background(), scheduled.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	list, err := testQueries.ListScheduledTransfersByOwner(context.Background(), ListScheduledTransfersByOwnerParams{
+		Owner: scheduled.Owner,
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, ScheduledTransferCancelled, list[0].Status)
+}
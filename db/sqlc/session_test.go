@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func CreateRandomSession(t *testing.T, user *User) Session {
+	arg := CreateSessionParams{
+		ID:           uuid.New(),
+		Username:     user.Username,
+		RefreshToken: utils.RandomString(32),
+		UserAgent:    "go-test",
+		ClientIp:     "127.0.0.1",
+		IsBlocked:    false,
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+
+	session, err := testQueries.CreateSession(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, session)
+
+	require.Equal(t, arg.ID, session.ID)
+	require.Equal(t, arg.Username, session.Username)
+	require.Equal(t, arg.RefreshToken, session.RefreshToken)
+	require.False(t, session.IsBlocked)
+	require.WithinDuration(t, arg.ExpiresAt, session.ExpiresAt, time.Second)
+	require.NotZero(t, session.CreatedAt)
+	return session
+}
+
+func TestBlockSession(t *testing.T) {
+	user, err := CreateRandomUser()
+	require.NoError(t, err)
+	session1 := CreateRandomSession(t, user)
+
+	session2, err := testQueries.BlockSession(context.Background(), session1.ID)
+	require.NoError(t, err)
+	require.Equal(t, session1.ID, session2.ID)
+	require.True(t, session2.IsBlocked)
+}
+
+func TestBlockUserSessions(t *testing.T) {
+	user, err := CreateRandomUser()
+	require.NoError(t, err)
+
+	sessions := []Session{}
+	for i := 0; i < 3; i++ {
+		sessions = append(sessions, CreateRandomSession(t, user))
+	}
+
+	err = testQueries.BlockUserSessions(context.Background(), user.Username)
+	require.NoError(t, err)
+
+	for _, session := range sessions {
+		gotSession, err := testQueries.GetSession(context.Background(), session.ID)
+		require.NoError(t, err)
+		require.True(t, gotSession.IsBlocked)
+	}
+}
@@ -0,0 +1,27 @@
+package db
+
+// Statuses of a StandingOrder
+const (
+	StandingOrderActive    = "active"
+	StandingOrderPaused    = "paused"
+	StandingOrderCompleted = "completed"
+	StandingOrderCancelled = "cancelled"
+)
+
+// Catch-up policies of a StandingOrder, they decide what happens to the
+// occurrences that came due while no executor was running
+const (
+	// CatchUpAll books every missed occurrence
+	CatchUpAll = "all"
+	// CatchUpLatest books the most recent missed occurrence and skips the others
+	CatchUpLatest = "latest"
+	// CatchUpSkip books an occurrence only if it isn't late, see the scheduler
+	CatchUpSkip = "skip"
+)
+
+// Statuses of a StandingOrderRun
+const (
+	StandingOrderRunCompleted = "completed"
+	StandingOrderRunFailed    = "failed"
+	StandingOrderRunSkipped   = "skipped"
+)
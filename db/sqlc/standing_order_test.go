@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomStandingOrder(t *testing.T, nextRunAt time.Time) StandingOrder {
+	account1 := createRandomAccountInCurrency(t, utils.USD)
+	account2 := createRandomAccountInCurrency(t, utils.USD)
+
+	arg := CreateStandingOrderParams{
+		Owner:          account1.Owner,
+		FromAccountID:  account1.ID,
+		ToAccountID:    account2.ID,
+		Amount:         10,
+		Currency:       utils.USD,
+		Schedule:       "@hourly",
+		CatchUp:        CatchUpLatest,
+		StartAt:        nextRunAt,
+		MaxOccurrences: sql.NullInt32{Int32: 3, Valid: true},
+		NextRunAt:      nextRunAt,
+	}
+	order, err := testQueries.CreateStandingOrder(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, StandingOrderActive, order.Status)
+	require.Zero(t, order.Occurrences)
+	require.False(t, order.EndAt.Valid)
+	require.False(t, order.LockedUntil.Valid)
+	return order
+}
+
+func TestClaimDueStandingOrders(t *testing.T) {
+	order := createRandomStandingOrder(t, time.Now().Add(-time.Minute))
+	arg := ClaimDueStandingOrdersParams{LeaseSeconds: 60, Limit: 1000}
+
+	claimed, err := testQueries.ClaimDueStandingOrders(context.Background(), arg)
+	require.NoError(t, err)
+	var found bool
+	for _, claimedOrder := range claimed {
+		if claimedOrder.ID == order.ID {
+			found = true
+			require.True(t, claimedOrder.LockedUntil.Valid)
+		}
+	}
+	require.True(t, found)
+
+	// locked until the executor is done
+	claimed, err = testQueries.ClaimDueStandingOrders(context.Background(), arg)
+	require.NoError(t, err)
+	for _, claimedOrder := range claimed {
+		require.NotEqual(t, order.ID, claimedOrder.ID)
+	}
+
+	run := CreateStandingOrderRunParams{
+		StandingOrderID: order.ID,
+		ScheduledFor:    order.NextRunAt,
+		Status:          StandingOrderRunFailed,
+		Error:           "insufficient funds",
+	}
+	require.NoError(t, testQueries.CreateStandingOrderRun(context.Background(), run))
+	// the same occurrence again is ignored
+	run.Status = StandingOrderRunCompleted
+	require.NoError(t, testQueries.CreateStandingOrderRun(context.Background(), run))
+
+	runs, err := testQueries.ListStandingOrderRuns(context.Background(), ListStandingOrderRunsParams{
+		StandingOrderID: order.ID,
+		BeforeID:        1 << 62,
+		Limit:           10,
+	})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, StandingOrderRunFailed, runs[0].Status)
+
+	advanced, err := testQueries.AdvanceStandingOrder(context.Background(), AdvanceStandingOrderParams{
+		ID:          order.ID,
+		NextRunAt:   order.NextRunAt.Add(time.Hour),
+		Occurrences: 1,
+		Status:      StandingOrderActive,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), advanced.Occurrences)
+	require.False(t, advanced.LockedUntil.Valid)
+}
+
+func TestAdvanceKeepsPausedOrders(t *testing.T) {
+	order := createRandomStandingOrder(t, time.Now().Add(time.Hour))
+
+	paused, err := testQueries.PauseStandingOrder(context.Background(), order.ID)
+	require.NoError(t, err)
+	require.Equal(t, StandingOrderPaused, paused.Status)
+
+	_, err = testQueries.PauseStandingOrder(context.Background(), order.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	advanced, err := testQueries.AdvanceStandingOrder(context.Background(), AdvanceStandingOrderParams{
+		ID:          order.ID,
+		NextRunAt:   order.NextRunAt.Add(time.Hour),
+		Occurrences: 1,
+		Status:      StandingOrderActive,
+	})
+	require.NoError(t, err)
+	require.Equal(t, StandingOrderPaused, advanced.Status)
+
+	resumed, err := testQueries.ResumeStandingOrder(context.Background(), ResumeStandingOrderParams{
+		ID:        order.ID,
+		NextRunAt: order.NextRunAt.Add(2 * time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, StandingOrderActive, resumed.Status)
+
+	cancelled, err := testQueries.CancelStandingOrder(context.Background(), order.ID)
+	require.NoError(t, err)
+	require.Equal(t, StandingOrderCancelled, cancelled.Status)
+
+	_, err = testQueries.ResumeStandingOrder(context.Background(), ResumeStandingOrderParams{
+		ID:        order.ID,
+		NextRunAt: order.NextRunAt,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// StatementBalances are the balances of an account at the start and at the
+// end of a statement
+type StatementBalances struct {
+	Opening int64
+	Closing int64
+}
+
+// StatementTx reads a statement in one read-only repeatable read snapshot, so
+// that the opening balance plus the lines always adds up to the closing
+// balance while transfers are booked meanwhile. begin gets the balances
+// before the lines are streamed to fn, for formats that print the closing
+// balance first. Reading stops at the first error of begin or fn.
+func (store *SQLStore) StatementTx(ctx context.Context, arg ListStatementLinesParams, begin func(StatementBalances) error, fn func(ListStatementLinesRow) error) error {
+	tx, err := store.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	// nothing is written, rolling back just releases the snapshot
+	defer tx.Rollback()
+
+	var balances StatementBalances
+	balances.Opening, err = tx.GetOpeningBalance(ctx, GetOpeningBalanceParams{
+		FromTime:  arg.FromTime,
+		AccountID: arg.AccountID,
+	})
+	if err != nil {
+		return err
+	}
+	balances.Closing, err = tx.GetOpeningBalance(ctx, GetOpeningBalanceParams{
+		FromTime:  arg.ToTime,
+		AccountID: arg.AccountID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := begin(balances); err != nil {
+		return err
+	}
+	return tx.StreamStatementLines(ctx, arg, fn)
+}
+
+// StreamStatementLines runs the ListStatementLines query and hands every row
+// to fn as it is read, so exports of long histories don't hold them all in memory.
+// Iteration stops at the first error returned by fn.
+func (q *Queries) StreamStatementLines(ctx context.Context, arg ListStatementLinesParams, fn func(ListStatementLinesRow) error) error {
+	rows, err := q.db.QueryContext(ctx, listStatementLines, arg.AccountID, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i ListStatementLinesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.TransferID,
+			&i.CounterpartyAccountID,
+			&i.CounterpartyOwner,
+			&i.RunningTotal,
+		); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
@@ -3,48 +3,106 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 )
 
 type Store interface {
 	Querier
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	CreateAccountTx(ctx context.Context, arg CreateAccountTxParams) (Account, error)
+	CloseAccount(ctx context.Context, accountID int64) (Account, error)
+	ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (TransferTxResult, error)
+	TransferBatchTx(ctx context.Context, arg TransferBatchTxParams) (TransferBatchTxResult, error)
+	CreateHold(ctx context.Context, arg CreateHoldParams) (Hold, error)
+	CaptureHold(ctx context.Context, arg CaptureHoldParams) (CaptureHoldResult, error)
+	VoidHold(ctx context.Context, holdID int64) (Hold, error)
+	StreamStatementLines(ctx context.Context, arg ListStatementLinesParams, fn func(ListStatementLinesRow) error) error
+	StatementTx(ctx context.Context, arg ListStatementLinesParams, begin func(StatementBalances) error, fn func(ListStatementLinesRow) error) error
+	VerifyLedger(ctx context.Context, arg VerifyLedgerParams) (LedgerReport, error)
+	TxStats() TxStats
 }
+
+// SQLStore implements the transactions of Store on top of a Backend, the
+// Postgres database it is named after unless created by NewBackendStore
 type SQLStore struct {
-	*Queries
-	db *sql.DB
-	
+	Backend
+	options  StoreOptions
+	counters txCounters
 }
 
 func NewStore(db *sql.DB) Store {
+	return NewStoreWithOptions(db, DefaultStoreOptions())
+}
+
+// NewStoreWithOptions creates a store whose transactions follow options
+func NewStoreWithOptions(db *sql.DB, options StoreOptions) Store {
+	return NewBackendStore(sqlBackend{Queries: New(db), db: db}, options)
+}
+
+// NewBackendStore creates a store that keeps its data in backend
+func NewBackendStore(backend Backend, options StoreOptions) Store {
 	return &SQLStore{
-		db:      db,
-		Queries: New(db),
+		Backend: backend,
+		options: options,
 	}
 }
 
 var txKey = struct{}{}
 
-func (store *SQLStore) execTx(ctx context.Context, function func(*Queries) error) error {
-	tx, err := store.db.BeginTx(ctx, nil) // default value
-	if err != nil {
-		return err
-	}
-	q := New(tx)
-	err = function(q)
+// SystemIdempotencyKeyPrefix starts the idempotency keys of the transfers
+// the server books on its own, clients can't send keys with it
+const SystemIdempotencyKeyPrefix = "system:"
+
+// IdempotencyParams identifies a client request whose response must be
+// recorded in the same transaction that performs it
+type IdempotencyParams struct {
+	Username    string
+	Key         string
+	RequestPath string
+	RequestHash string
+}
+
+// IsIdempotencyConflict reports whether err comes from a request whose
+// idempotency key was already stored by another transaction
+func IsIdempotencyConflict(err error) bool {
+	err = TranslateError(err)
+	return errors.Is(err, ErrUniqueViolation) && ConstraintName(err) == "idempotency_keys_pkey"
+}
+
+// saveIdempotentResponse stores the successful response of a request under its idempotency key,
+// in the transaction that produced it. Error responses are stored by the API once the
+// transaction rolled back.
+func saveIdempotentResponse(ctx context.Context, q Querier, arg IdempotencyParams, response interface{}) error {
+	body, err := json.Marshal(response)
 	if err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
-			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
-		}
 		return err
 	}
-	return tx.Commit()
+
+	_, err = q.CreateIdempotencyKey(ctx, CreateIdempotencyKeyParams{
+		Username:     arg.Username,
+		Key:          arg.Key,
+		RequestPath:  arg.RequestPath,
+		RequestHash:  arg.RequestHash,
+		ResponseCode: http.StatusOK,
+		ResponseBody: body,
+	})
+	return err
 }
 
 type TransferTxParams struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
 	Amount        int64 `json:"amount"`
+	// ToAmount is what the destination account is credited in its own currency,
+	// it defaults to Amount and is required when the currencies differ
+	ToAmount int64 `json:"to_amount"`
+	// ExchangeRate is the rate applied to convert Amount into ToAmount, defaults to "1"
+	ExchangeRate string `json:"exchange_rate"`
+	// Idempotency is optional, when set the result is stored under its key
+	Idempotency *IdempotencyParams `json:"-"`
 }
 
 type TransferTxResult struct {
@@ -57,47 +115,163 @@ type TransferTxResult struct {
 
 func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
 	var result TransferTxResult
-	err := store.execTx(ctx, func(q *Queries) error {
+	err := store.execTx(ctx, store.transferTx(), func(q Querier) error {
 		var err error
 
-		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
+		fromAccount, toAccount, err := lockAccounts(ctx, q, arg.FromAccountID, arg.ToAccountID)
+		if err != nil {
+			return err
+		}
+
+		toAmount, exchangeRate := arg.ToAmount, arg.ExchangeRate
+		if fromAccount.Currency == toAccount.Currency {
+			toAmount, exchangeRate = arg.Amount, "1"
+		} else if toAmount <= 0 || exchangeRate == "" {
+			return fmt.Errorf("transfer from %s to %s needs a destination amount and an exchange rate",
+				fromAccount.Currency, toAccount.Currency)
+		}
+
+		result, err = bookTransfer(ctx, q, fromAccount, toAccount, CreateTransferParams{
 			FromAccountID: arg.FromAccountID,
 			ToAccountID:   arg.ToAccountID,
 			Amount:        arg.Amount,
+			FromCurrency:  fromAccount.Currency,
+			ToCurrency:    toAccount.Currency,
+			ToAmount:      toAmount,
+			ExchangeRate:  exchangeRate,
 		})
 		if err != nil {
 			return err
 		}
 
-		result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.FromAccountID,
-			Amount:    -arg.Amount,
-		})
-		if err != nil {
-			return err
+		if arg.Idempotency != nil {
+			return saveIdempotentResponse(ctx, q, *arg.Idempotency, result)
 		}
+		return nil
+	})
+	return result, err
+}
 
-		result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.ToAccountID,
-			Amount:    arg.Amount,
-		})
+// bookTransfer records a transfer with its two entries and moves the money.
+// Both accounts must already be locked, fromAccount is the locked sender and
+// toAccount the locked recipient.
+func bookTransfer(ctx context.Context, q Querier, fromAccount Account, toAccount Account, arg CreateTransferParams) (TransferTxResult, error) {
+	var result TransferTxResult
+	var err error
+
+	if err = ensureActive(fromAccount); err != nil {
+		return result, err
+	}
+	if err = ensureActive(toAccount); err != nil {
+		return result, err
+	}
+	if err = ensureAvailable(ctx, q, fromAccount, arg.Amount); err != nil {
+		return result, err
+	}
+
+	result, err = bookEntries(ctx, q, arg)
+	if err != nil {
+		return result, err
+	}
+
+	// Advoiding Deadlock
+	if arg.FromAccountID < arg.ToAccountID {
+		result.FromAccount, result.ToAccount, err = AddMoney(ctx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.ToAmount)
+	} else {
+		result.ToAccount, result.FromAccount, err = AddMoney(ctx, q, arg.ToAccountID, arg.ToAmount, arg.FromAccountID, -arg.Amount)
+	}
+	return result, err
+}
+
+// bookEntries records a transfer with its two entries, the balances are
+// left to the caller
+func bookEntries(ctx context.Context, q Querier, arg CreateTransferParams) (TransferTxResult, error) {
+	var result TransferTxResult
+	var err error
+
+	result.Transfer, err = q.CreateTransfer(ctx, arg)
+	if err != nil {
+		return result, err
+	}
+
+	// each entry is booked in its own account's currency
+	result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:  arg.FromAccountID,
+		Amount:     -arg.Amount,
+		TransferID: sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:  arg.ToAccountID,
+		Amount:     arg.ToAmount,
+		TransferID: sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+	})
+	return result, err
+}
+
+// ensureAvailable fails with ErrInsufficientFunds when amount is more than
+// the locked account can spend: its balance and overdraft limit minus the
+// funds reserved by active holds
+func ensureAvailable(ctx context.Context, q Querier, account Account, amount int64) error {
+	held, err := q.GetHeldAmount(ctx, account.ID)
+	if err != nil {
+		return err
+	}
+
+	available := account.Balance + account.OverdraftLimit - held
+	if available < amount {
+		return fmt.Errorf("%w: account [%d] has %d available, transfer needs %d",
+			ErrInsufficientFunds, account.ID, available, amount)
+	}
+	return nil
+}
+
+// lockAccounts locks both accounts in id order so that concurrent transfers
+// in opposite directions can't deadlock, the accounts are returned in argument order
+func lockAccounts(ctx context.Context, q Querier, accountID1 int64, accountID2 int64) (account1 Account, account2 Account, err error) {
+	if accountID1 > accountID2 {
+		account2, account1, err = lockAccounts(ctx, q, accountID2, accountID1)
+		return
+	}
+
+	account1, err = q.GetAccountForUpdate(ctx, accountID1)
+	if err != nil {
+		return
+	}
+
+	account2, err = q.GetAccountForUpdate(ctx, accountID2)
+	return
+}
+
+type CreateAccountTxParams struct {
+	CreateAccountParams
+	// Idempotency is optional, when set the account is stored under its key
+	Idempotency *IdempotencyParams `json:"-"`
+}
+
+// CreateAccountTx creates an account and records the idempotency key, if any, in the same transaction
+func (store *SQLStore) CreateAccountTx(ctx context.Context, arg CreateAccountTxParams) (Account, error) {
+	var account Account
+	err := store.execTx(ctx, nil, func(q Querier) error {
+		var err error
+
+		account, err = q.CreateAccount(ctx, arg.CreateAccountParams)
 		if err != nil {
 			return err
 		}
 
-		//TODO later
-		// Advoiding Deadlock
-		if arg.FromAccountID < arg.ToAccountID {
-			result.FromAccount, result.ToAccount, err = AddMoney(ctx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount)
-		} else {
-			result.ToAccount, result.FromAccount, err = AddMoney(ctx, q, arg.ToAccountID, arg.Amount, arg.FromAccountID, -arg.Amount)
+		if arg.Idempotency != nil {
+			return saveIdempotentResponse(ctx, q, *arg.Idempotency, account)
 		}
 		return nil
 	})
-	return result, err
+	return account, err
 }
 
-func AddMoney(ctx context.Context, q *Queries, accountID1 int64, amount1 int64, accountID2 int64, amount2 int64) (account1 Account, account2 Account, err error) {
+func AddMoney(ctx context.Context, q Querier, accountID1 int64, amount1 int64, accountID2 int64, amount2 int64) (account1 Account, account2 Account, err error) {
 	account1, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
 		ID:     accountID1,
 		Amount: amount1,
@@ -0,0 +1,21 @@
+package db_test
+
+import (
+	"database/sql"
+	"testing"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/db/storetest"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreConformance(t *testing.T) {
+	config, err := utils.LoadConfig("../..")
+	require.NoError(t, err)
+	conn, err := sql.Open(config.DBDriver, config.DBSource)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	storetest.Run(t, db.NewStore(conn))
+}
@@ -7,28 +7,48 @@ package db
 
 import (
 	"context"
+	"database/sql"
 )
 
 const createTransfer = `-- name: CreateTransfer :one
 INSERT INTO transfers(
     from_account_id,
     to_account_id,
-    amount
+    amount,
+    from_currency,
+    to_currency,
+    to_amount,
+    exchange_rate,
+    reversal_of
 )
 VALUES(
-    $1, $2, $3
+    $1, $2, $3, $4, $5, $6, $7, $8
 )
-RETURNING id, from_account_id, to_account_id, amount, created_at
+RETURNING id, from_account_id, to_account_id, amount, created_at, from_currency, to_currency, to_amount, exchange_rate, reversal_of
 `
 
 type CreateTransferParams struct {
-	FromAccountID int64 `db:"from_account_id" json:"from_account_id"`
-	ToAccountID   int64 `db:"to_account_id" json:"to_account_id"`
-	Amount        int64 `db:"amount" json:"amount"`
+	FromAccountID int64         `db:"from_account_id" json:"from_account_id"`
+	ToAccountID   int64         `db:"to_account_id" json:"to_account_id"`
+	Amount        int64         `db:"amount" json:"amount"`
+	FromCurrency  string        `db:"from_currency" json:"from_currency"`
+	ToCurrency    string        `db:"to_currency" json:"to_currency"`
+	ToAmount      int64         `db:"to_amount" json:"to_amount"`
+	ExchangeRate  string        `db:"exchange_rate" json:"exchange_rate"`
+	ReversalOf    sql.NullInt64 `db:"reversal_of" json:"reversal_of"`
 }
 
 func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error) {
-	row := q.db.QueryRowContext(ctx, createTransfer, arg.FromAccountID, arg.ToAccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createTransfer,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.FromCurrency,
+		arg.ToCurrency,
+		arg.ToAmount,
+		arg.ExchangeRate,
+		arg.ReversalOf,
+	)
 	var i Transfer
 	err := row.Scan(
 		&i.ID,
@@ -36,22 +56,39 @@ func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.ReversalOf,
 	)
 	return i, err
 }
 
-const deleteTransfer = `-- name: DeleteTransfer :exec
-DELETE FROM transfers
-WHERE id = $1
+const getReversedTotals = `-- name: GetReversedTotals :one
+SELECT
+  COALESCE(SUM(amount), 0)::bigint AS reversed_amount,
+  COALESCE(SUM(to_amount), 0)::bigint AS refunded_amount
+FROM transfers
+WHERE reversal_of = $1
 `
 
-func (q *Queries) DeleteTransfer(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deleteTransfer, id)
-	return err
+type GetReversedTotalsRow struct {
+	ReversedAmount int64 `db:"reversed_amount" json:"reversed_amount"`
+	RefundedAmount int64 `db:"refunded_amount" json:"refunded_amount"`
+}
+
+// sums the reversals of a transfer, amount is in the original to_currency
+// and to_amount in the original from_currency.
+func (q *Queries) GetReversedTotals(ctx context.Context, reversalOf sql.NullInt64) (GetReversedTotalsRow, error) {
+	row := q.db.QueryRowContext(ctx, getReversedTotals, reversalOf)
+	var i GetReversedTotalsRow
+	err := row.Scan(&i.ReversedAmount, &i.RefundedAmount)
+	return i, err
 }
 
 const getTransfer = `-- name: GetTransfer :one
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, from_currency, to_currency, to_amount, exchange_rate, reversal_of FROM transfers
 WHERE id = $1 LIMIT 1
 `
 
@@ -64,33 +101,85 @@ func (q *Queries) GetTransfer(ctx context.Context, id int64) (Transfer, error) {
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.ReversalOf,
+	)
+	return i, err
+}
+
+const getTransferForUpdate = `-- name: GetTransferForUpdate :one
+SELECT id, from_account_id, to_account_id, amount, created_at, from_currency, to_currency, to_amount, exchange_rate, reversal_of FROM transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, getTransferForUpdate, id)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.ReversalOf,
 	)
 	return i, err
 }
 
 const listTransfers = `-- name: ListTransfers :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
-WHERE 
-    from_account_id = $1 OR
-    to_account_id = $2
-ORDER BY id
-LIMIT $3
-OFFSET $4
+SELECT * FROM transfers
+WHERE
+    CASE $1::text
+        WHEN 'incoming' THEN to_account_id = $2
+        WHEN 'outgoing' THEN from_account_id = $2
+        ELSE from_account_id = $2 OR to_account_id = $2
+    END
+    AND ($3::bigint IS NULL
+        OR from_account_id = $3 OR to_account_id = $3)
+    AND ($4::bigint IS NULL
+        OR CASE WHEN to_account_id = $2 THEN to_amount ELSE amount END >= $4)
+    AND ($5::bigint IS NULL
+        OR CASE WHEN to_account_id = $2 THEN to_amount ELSE amount END <= $5)
+    AND ($6::timestamptz IS NULL OR created_at >= $6)
+    AND ($7::timestamptz IS NULL OR created_at < $7)
+    AND id < $8
+ORDER BY id DESC
+LIMIT $9
 `
 
 type ListTransfersParams struct {
-	FromAccountID int64 `db:"from_account_id" json:"from_account_id"`
-	ToAccountID   int64 `db:"to_account_id" json:"to_account_id"`
-	Limit         int32 `db:"limit" json:"limit"`
-	Offset        int32 `db:"offset" json:"offset"`
+	Direction      string        `db:"direction" json:"direction"`
+	AccountID      int64         `db:"account_id" json:"account_id"`
+	CounterpartyID sql.NullInt64 `db:"counterparty_id" json:"counterparty_id"`
+	MinAmount      sql.NullInt64 `db:"min_amount" json:"min_amount"`
+	MaxAmount      sql.NullInt64 `db:"max_amount" json:"max_amount"`
+	FromTime       sql.NullTime  `db:"from_time" json:"from_time"`
+	ToTime         sql.NullTime  `db:"to_time" json:"to_time"`
+	BeforeID       int64         `db:"before_id" json:"before_id"`
+	Limit          int32         `db:"limit" json:"limit"`
 }
 
+// walks the history of an account newest first, amounts are compared
+// in the account's own currency and to_time is exclusive.
 func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error) {
 	rows, err := q.db.QueryContext(ctx, listTransfers,
-		arg.FromAccountID,
-		arg.ToAccountID,
+		arg.Direction,
+		arg.AccountID,
+		arg.CounterpartyID,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.FromTime,
+		arg.ToTime,
+		arg.BeforeID,
 		arg.Limit,
-		arg.Offset,
 	)
 	if err != nil {
 		return nil, err
@@ -105,6 +194,11 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.FromCurrency,
+			&i.ToCurrency,
+			&i.ToAmount,
+			&i.ExchangeRate,
+			&i.ReversalOf,
 		); err != nil {
 			return nil, err
 		}
@@ -119,18 +213,80 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 	return items, nil
 }
 
-const updateTransfer = `-- name: UpdateTransfer :exec
-UPDATE transfers
-SET amount = $1
-WHERE id = $2
+const listTransfersAfter = `-- name: ListTransfersAfter :many
+SELECT * FROM transfers
+WHERE
+    CASE $1::text
+        WHEN 'incoming' THEN to_account_id = $2
+        WHEN 'outgoing' THEN from_account_id = $2
+        ELSE from_account_id = $2 OR to_account_id = $2
+    END
+    AND ($3::bigint IS NULL
+        OR from_account_id = $3 OR to_account_id = $3)
+    AND ($4::bigint IS NULL
+        OR CASE WHEN to_account_id = $2 THEN to_amount ELSE amount END >= $4)
+    AND ($5::bigint IS NULL
+        OR CASE WHEN to_account_id = $2 THEN to_amount ELSE amount END <= $5)
+    AND ($6::timestamptz IS NULL OR created_at >= $6)
+    AND ($7::timestamptz IS NULL OR created_at < $7)
+    AND id > $8
+ORDER BY id
+LIMIT $9
 `
 
-type UpdateTransferParams struct {
-	Amount int64 `db:"amount" json:"amount"`
-	ID     int64 `db:"id" json:"id"`
+type ListTransfersAfterParams struct {
+	Direction      string        `db:"direction" json:"direction"`
+	AccountID      int64         `db:"account_id" json:"account_id"`
+	CounterpartyID sql.NullInt64 `db:"counterparty_id" json:"counterparty_id"`
+	MinAmount      sql.NullInt64 `db:"min_amount" json:"min_amount"`
+	MaxAmount      sql.NullInt64 `db:"max_amount" json:"max_amount"`
+	FromTime       sql.NullTime  `db:"from_time" json:"from_time"`
+	ToTime         sql.NullTime  `db:"to_time" json:"to_time"`
+	AfterID        int64         `db:"after_id" json:"after_id"`
+	Limit          int32         `db:"limit" json:"limit"`
 }
 
-func (q *Queries) UpdateTransfer(ctx context.Context, arg UpdateTransferParams) error {
-	_, err := q.db.ExecContext(ctx, updateTransfer, arg.Amount, arg.ID)
-	return err
+// walks the same history back towards the newest transfer, oldest first.
+func (q *Queries) ListTransfersAfter(ctx context.Context, arg ListTransfersAfterParams) ([]Transfer, error) {
+	rows, err := q.db.QueryContext(ctx, listTransfersAfter,
+		arg.Direction,
+		arg.AccountID,
+		arg.CounterpartyID,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.FromTime,
+		arg.ToTime,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfer{}
+	for rows.Next() {
+		var i Transfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.FromCurrency,
+			&i.ToCurrency,
+			&i.ToAmount,
+			&i.ExchangeRate,
+			&i.ReversalOf,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
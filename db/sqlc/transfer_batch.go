@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// Modes of a TransferBatch
+const (
+	// BatchAtomic books every transfer of the batch or none of them
+	BatchAtomic = "atomic"
+	// BatchBestEffort books the transfers that can be and records why the
+	// others failed
+	BatchBestEffort = "best_effort"
+)
+
+// Statuses of a TransferBatch
+const (
+	TransferBatchCompleted = "completed"
+	TransferBatchPartial   = "partial"
+	TransferBatchFailed    = "failed"
+)
+
+// Statuses of a TransferBatchItem
+const (
+	TransferBatchItemCompleted = "completed"
+	TransferBatchItemFailed    = "failed"
+)
+
+type TransferBatchTxParams struct {
+	Owner string `json:"owner"`
+	Mode  string `json:"mode"`
+	// Transfers are booked in order, their Idempotency is ignored
+	Transfers []TransferTxParams `json:"transfers"`
+	// Idempotency is optional, when set the result is stored under its key
+	Idempotency *IdempotencyParams `json:"-"`
+}
+
+type TransferBatchTxResult struct {
+	Batch TransferBatch       `json:"batch"`
+	Items []TransferBatchItem `json:"items"`
+}
+
+// TransferBatchTx books a batch of transfers in one transaction. Every
+// account involved is locked upfront in id order, so batches and transfers
+// sharing accounts can't deadlock, and each account balance is updated once
+// with the net of its transfers. In atomic mode the first transfer that
+// can't be booked fails the whole batch, in best-effort mode it is recorded
+// as failed and the batch goes on.
+func (store *SQLStore) TransferBatchTx(ctx context.Context, arg TransferBatchTxParams) (TransferBatchTxResult, error) {
+	var result TransferBatchTxResult
+	err := store.execTx(ctx, store.transferTx(), func(q Querier) error {
+		accounts, err := lockBatchAccounts(ctx, q, arg.Transfers)
+		if err != nil {
+			return err
+		}
+
+		// what each sender can still spend, credits of the batch don't count
+		available := map[int64]int64{}
+		for _, transfer := range arg.Transfers {
+			account := accounts[transfer.FromAccountID]
+			if _, ok := available[account.ID]; ok {
+				continue
+			}
+			held, err := q.GetHeldAmount(ctx, account.ID)
+			if err != nil {
+				return err
+			}
+			available[account.ID] = account.Balance + account.OverdraftLimit - held
+		}
+
+		items := make([]CreateTransferBatchItemParams, len(arg.Transfers))
+		net := map[int64]int64{}
+		var failed int32
+		for i, transfer := range arg.Transfers {
+			fromAccount, toAccount := accounts[transfer.FromAccountID], accounts[transfer.ToAccountID]
+			items[i] = CreateTransferBatchItemParams{
+				Position:      int32(i),
+				FromAccountID: fromAccount.ID,
+				ToAccountID:   toAccount.ID,
+				Amount:        transfer.Amount,
+				Currency:      fromAccount.Currency,
+				Status:        TransferBatchItemCompleted,
+			}
+
+			params, err := batchTransfer(fromAccount, toAccount, transfer, available[fromAccount.ID])
+			if err != nil {
+				if arg.Mode == BatchAtomic {
+					return fmt.Errorf("transfer %d: %w", i, err)
+				}
+				items[i].Status = TransferBatchItemFailed
+				items[i].Error = err.Error()
+				failed++
+				continue
+			}
+
+			booked, err := bookEntries(ctx, q, params)
+			if err != nil {
+				return err
+			}
+			available[fromAccount.ID] -= params.Amount
+			net[fromAccount.ID] -= params.Amount
+			net[toAccount.ID] += params.ToAmount
+			items[i].TransferID = sql.NullInt64{Int64: booked.Transfer.ID, Valid: true}
+		}
+
+		// a single balance update per account, in lock order
+		ids := make([]int64, 0, len(net))
+		for id := range net {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			if net[id] == 0 {
+				continue
+			}
+			if _, err := q.AddAccountBalance(ctx, AddAccountBalanceParams{ID: id, Amount: net[id]}); err != nil {
+				return err
+			}
+		}
+
+		status := TransferBatchCompleted
+		if failed == int32(len(items)) {
+			status = TransferBatchFailed
+		} else if failed > 0 {
+			status = TransferBatchPartial
+		}
+		result.Batch, err = q.CreateTransferBatch(ctx, CreateTransferBatchParams{
+			Owner:       arg.Owner,
+			Mode:        arg.Mode,
+			Status:      status,
+			ItemCount:   int32(len(items)),
+			FailedCount: failed,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Items = make([]TransferBatchItem, 0, len(items))
+		for _, item := range items {
+			item.BatchID = result.Batch.ID
+			created, err := q.CreateTransferBatchItem(ctx, item)
+			if err != nil {
+				return err
+			}
+			result.Items = append(result.Items, created)
+		}
+
+		if arg.Idempotency != nil {
+			return saveIdempotentResponse(ctx, q, *arg.Idempotency, result)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// lockBatchAccounts locks every account of a batch, see GetAccountsForUpdate
+func lockBatchAccounts(ctx context.Context, q Querier, transfers []TransferTxParams) (map[int64]Account, error) {
+	seen := map[int64]bool{}
+	ids := []int64{}
+	for _, transfer := range transfers {
+		for _, id := range []int64{transfer.FromAccountID, transfer.ToAccountID} {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	locked, err := q.GetAccountsForUpdate(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(locked) != len(ids) {
+		return nil, fmt.Errorf("batch references %d accounts, %d exist: %w", len(ids), len(locked), ErrRecordNotFound)
+	}
+
+	accounts := make(map[int64]Account, len(locked))
+	for _, account := range locked {
+		accounts[account.ID] = account
+	}
+	return accounts, nil
+}
+
+// batchTransfer checks a transfer of a batch against the locked accounts and
+// what the sender has left, it returns the transfer to book
+func batchTransfer(fromAccount Account, toAccount Account, arg TransferTxParams, available int64) (CreateTransferParams, error) {
+	params := CreateTransferParams{
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        arg.Amount,
+		FromCurrency:  fromAccount.Currency,
+		ToCurrency:    toAccount.Currency,
+		ToAmount:      arg.ToAmount,
+		ExchangeRate:  arg.ExchangeRate,
+	}
+	if fromAccount.Currency == toAccount.Currency {
+		params.ToAmount, params.ExchangeRate = arg.Amount, "1"
+	} else if arg.ToAmount <= 0 || arg.ExchangeRate == "" {
+		return params, fmt.Errorf("transfer from %s to %s needs a destination amount and an exchange rate",
+			fromAccount.Currency, toAccount.Currency)
+	}
+
+	if err := ensureActive(fromAccount); err != nil {
+		return params, err
+	}
+	if err := ensureActive(toAccount); err != nil {
+		return params, err
+	}
+	if available < arg.Amount {
+		return params, fmt.Errorf("%w: account [%d] has %d available, transfer needs %d",
+			ErrInsufficientFunds, fromAccount.ID, available, arg.Amount)
+	}
+	return params, nil
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferBatchTxBestEffort(t *testing.T) {
+	store := NewStore(testDB)
+	payer := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 100)
+	payee1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 0)
+	payee2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 0)
+
+	result, err := store.TransferBatchTx(context.Background(), TransferBatchTxParams{
+		Owner: payer.Owner,
+		Mode:  BatchBestEffort,
+		Transfers: []TransferTxParams{
+			{FromAccountID: payer.ID, ToAccountID: payee1.ID, Amount: 60},
+			{FromAccountID: payer.ID, ToAccountID: payee2.ID, Amount: 60},
+			{FromAccountID: payer.ID, ToAccountID: payee2.ID, Amount: 40},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferBatchPartial, result.Batch.Status)
+	require.Equal(t, int32(3), result.Batch.ItemCount)
+	require.Equal(t, int32(1), result.Batch.FailedCount)
+
+	require.Len(t, result.Items, 3)
+	require.Equal(t, TransferBatchItemCompleted, result.Items[0].Status)
+	require.True(t, result.Items[0].TransferID.Valid)
+	// the second item would overdraw the payer, the third still fits
+	require.Equal(t, TransferBatchItemFailed, result.Items[1].Status)
+	require.False(t, result.Items[1].TransferID.Valid)
+	require.Contains(t, result.Items[1].Error, ErrInsufficientFunds.Error())
+	require.Equal(t, TransferBatchItemCompleted, result.Items[2].Status)
+
+	for id, balance := range map[int64]int64{payer.ID: 0, payee1.ID: 60, payee2.ID: 40} {
+		account, err := testQueries.GetAccount(context.Background(), id)
+		require.NoError(t, err)
+		require.Equal(t, balance, account.Balance)
+	}
+
+	items, err := testQueries.ListTransferBatchItems(context.Background(), result.Batch.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.Items, items)
+}
+
+func TestTransferBatchTxAtomic(t *testing.T) {
+	store := NewStore(testDB)
+	payer := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 100)
+	payee1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 0)
+	payee2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 0)
+
+	_, err := store.TransferBatchTx(context.Background(), TransferBatchTxParams{
+		Owner: payer.Owner,
+		Mode:  BatchAtomic,
+		Transfers: []TransferTxParams{
+			{FromAccountID: payer.ID, ToAccountID: payee1.ID, Amount: 60},
+			{FromAccountID: payer.ID, ToAccountID: payee2.ID, Amount: 60},
+		},
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// nothing was booked
+	account, err := testQueries.GetAccount(context.Background(), payee1.ID)
+	require.NoError(t, err)
+	require.Zero(t, account.Balance)
+
+	result, err := store.TransferBatchTx(context.Background(), TransferBatchTxParams{
+		Owner: payer.Owner,
+		Mode:  BatchAtomic,
+		Transfers: []TransferTxParams{
+			{FromAccountID: payer.ID, ToAccountID: payee1.ID, Amount: 60},
+			{FromAccountID: payer.ID, ToAccountID: payee2.ID, Amount: 40},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferBatchCompleted, result.Batch.Status)
+
+	report, err := store.VerifyLedger(context.Background(), VerifyLedgerParams{})
+	require.NoError(t, err)
+	for _, discrepancy := range report.Discrepancies {
+		require.NotEqual(t, payer.ID, discrepancy.AccountID)
+	}
+}
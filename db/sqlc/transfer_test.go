@@ -2,15 +2,20 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
 	"testing"
+	"time"
 
+	"github.com/minhdang2803/simple_bank/utils"
 	"github.com/stretchr/testify/require"
 )
 
 func TestTransferTx(t *testing.T) {
 	store := NewStore(testDB)
-	account1 := CreateRandomAccount(t)
-	account2 := CreateRandomAccount(t)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
 	//Fake fake balance
 	amount := int64(10)
 
@@ -45,6 +50,10 @@ func TestTransferTx(t *testing.T) {
 		require.Equal(t, account1.ID, transfer.FromAccountID)
 		require.Equal(t, account2.ID, transfer.ToAccountID)
 		require.Equal(t, amount, transfer.Amount)
+		require.Equal(t, amount, transfer.ToAmount)
+		require.Equal(t, utils.USD, transfer.FromCurrency)
+		require.Equal(t, utils.USD, transfer.ToCurrency)
+		require.Equal(t, "1.0000000000", transfer.ExchangeRate)
 		require.NotZero(t, transfer.ID)
 		require.NotZero(t, transfer.FromAccountID)
 		require.NotZero(t, transfer.ToAccountID)
@@ -105,8 +114,8 @@ func TestTransferTx(t *testing.T) {
 
 func TestTransferTxDeadlock(t *testing.T) {
 	store := NewStore(testDB)
-	account1 := CreateRandomAccount(t)
-	account2 := CreateRandomAccount(t)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
 
 	//Fake fake balance
 	amount := int64(10)
@@ -146,3 +155,300 @@ func TestTransferTxDeadlock(t *testing.T) {
 	require.Equal(t, account1.Balance, updatedAccount1.Balance)
 	require.Equal(t, account2.Balance, updatedAccount2.Balance)
 }
+
+func TestTransferTxIdempotency(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+
+	arg := TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+		Idempotency: &IdempotencyParams{
+			Username:    account1.Owner,
+			Key:         utils.RandomString(16),
+			RequestPath: "/transfers",
+			RequestHash: utils.RandomString(64),
+		},
+	}
+
+	result, err := store.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+
+	saved, err := store.GetIdempotencyKey(context.Background(), GetIdempotencyKeyParams{
+		Username: arg.Idempotency.Username,
+		Key:      arg.Idempotency.Key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, arg.Idempotency.RequestHash, saved.RequestHash)
+	require.EqualValues(t, 200, saved.ResponseCode)
+
+	var savedResult TransferTxResult
+	err = json.Unmarshal(saved.ResponseBody, &savedResult)
+	require.NoError(t, err)
+	require.Equal(t, result.Transfer.ID, savedResult.Transfer.ID)
+
+	// the same key can't be stored twice, so the second transfer is rolled back
+	_, err = store.TransferTx(context.Background(), arg)
+	require.Error(t, err)
+
+	updatedAccount1, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance-arg.Amount, updatedAccount1.Balance)
+}
+
+func TestTransferTxInsufficientFunds(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 10)
+	account2 := createRandomAccountInCurrency(t, utils.USD)
+
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        11,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// nothing is booked when the transfer is rejected
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updatedAccount1.Balance)
+}
+
+func TestTransferTxOverdraft(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 10)
+	account2 := createRandomAccountInCurrency(t, utils.USD)
+
+	_, err := testQueries.UpdateAccountOverdraftLimit(context.Background(), UpdateAccountOverdraftLimitParams{
+		ID:             account1.ID,
+		OverdraftLimit: 50,
+	})
+	require.NoError(t, err)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        60,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(-50), result.FromAccount.Balance)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        1,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestTransferTxCrossCurrency(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.EUR), 1000)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+		ToAmount:      92,
+		ExchangeRate:  "0.92",
+	})
+	require.NoError(t, err)
+
+	transfer := result.Transfer
+	require.Equal(t, utils.USD, transfer.FromCurrency)
+	require.Equal(t, utils.EUR, transfer.ToCurrency)
+	require.Equal(t, int64(100), transfer.Amount)
+	require.Equal(t, int64(92), transfer.ToAmount)
+	require.Equal(t, "0.9200000000", transfer.ExchangeRate)
+
+	// each side is booked in its own currency
+	require.Equal(t, int64(-100), result.FromEntry.Amount)
+	require.Equal(t, int64(92), result.ToEntry.Amount)
+	require.Equal(t, int64(900), result.FromAccount.Balance)
+	require.Equal(t, int64(1092), result.ToAccount.Balance)
+}
+
+func TestTransferTxCrossCurrencyWithoutRate(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := createRandomAccountInCurrency(t, utils.EUR)
+
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.Error(t, err)
+
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updatedAccount1.Balance)
+}
+
+func fundAccount(t *testing.T, account Account, balance int64) Account {
+	account, err := testQueries.AddAccountBalance(context.Background(), AddAccountBalanceParams{
+		ID:     account.ID,
+		Amount: balance - account.Balance,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func TestListTransfers(t *testing.T) {
+	store := NewStore(testDB)
+	account := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	other := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	third := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+
+	var outgoing, incoming, large TransferTxResult
+	var err error
+	outgoing, err = store.TransferTx(context.Background(), TransferTxParams{FromAccountID: account.ID, ToAccountID: other.ID, Amount: 10})
+	require.NoError(t, err)
+	incoming, err = store.TransferTx(context.Background(), TransferTxParams{FromAccountID: other.ID, ToAccountID: account.ID, Amount: 20})
+	require.NoError(t, err)
+	large, err = store.TransferTx(context.Background(), TransferTxParams{FromAccountID: account.ID, ToAccountID: third.ID, Amount: 300})
+	require.NoError(t, err)
+
+	arg := ListTransfersParams{
+		Direction: "both",
+		AccountID: account.ID,
+		BeforeID:  math.MaxInt64,
+		Limit:     10,
+	}
+	transfers, err := testQueries.ListTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, transfers, 3)
+	// newest first
+	require.Equal(t, large.Transfer.ID, transfers[0].ID)
+	require.Equal(t, outgoing.Transfer.ID, transfers[2].ID)
+
+	arg.Direction = "incoming"
+	transfers, err = testQueries.ListTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+	require.Equal(t, incoming.Transfer.ID, transfers[0].ID)
+
+	arg.Direction = "outgoing"
+	arg.MinAmount = sql.NullInt64{Int64: 100, Valid: true}
+	transfers, err = testQueries.ListTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+	require.Equal(t, large.Transfer.ID, transfers[0].ID)
+
+	arg.Direction = "both"
+	arg.MinAmount = sql.NullInt64{}
+	arg.CounterpartyID = sql.NullInt64{Int64: other.ID, Valid: true}
+	arg.FromTime = sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}
+	arg.ToTime = sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	transfers, err = testQueries.ListTransfers(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, transfers, 2)
+
+	// walking back from the oldest transfer returns the newer ones, oldest first
+	newer, err := testQueries.ListTransfersAfter(context.Background(), ListTransfersAfterParams{
+		Direction: "both",
+		AccountID: account.ID,
+		AfterID:   outgoing.Transfer.ID,
+		Limit:     10,
+	})
+	require.NoError(t, err)
+	require.Len(t, newer, 2)
+	require.Equal(t, incoming.Transfer.ID, newer[0].ID)
+	require.Equal(t, large.Transfer.ID, newer[1].ID)
+}
+
+func TestReverseTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	partial, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+		Amount:     30,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account2.ID, partial.Transfer.FromAccountID)
+	require.Equal(t, account1.ID, partial.Transfer.ToAccountID)
+	require.Equal(t, int64(30), partial.Transfer.Amount)
+	require.Equal(t, original.Transfer.ID, partial.Transfer.ReversalOf.Int64)
+	require.Equal(t, int64(-30), partial.FromEntry.Amount)
+	require.Equal(t, int64(30), partial.ToEntry.Amount)
+	require.Equal(t, int64(930), partial.ToAccount.Balance)
+	require.Equal(t, int64(1070), partial.FromAccount.Balance)
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+		Amount:     71,
+	})
+	require.ErrorIs(t, err, ErrReversalExceedsTransfer)
+
+	// zero reverses the rest
+	rest, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(70), rest.Transfer.Amount)
+	require.Equal(t, account1.Balance, rest.ToAccount.Balance)
+	require.Equal(t, account2.Balance, rest.FromAccount.Balance)
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+	})
+	require.ErrorIs(t, err, ErrReversalExceedsTransfer)
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: rest.Transfer.ID,
+	})
+	require.ErrorIs(t, err, ErrReversalOfReversal)
+
+	// the original transfer is left untouched
+	saved, err := store.GetTransfer(context.Background(), original.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, original.Transfer, saved)
+}
+
+func TestReverseTransferTxCrossCurrency(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.EUR), 1000)
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+		ToAmount:      92,
+		ExchangeRate:  "0.92",
+	})
+	require.NoError(t, err)
+
+	// 10 EUR back at the original rate is 10.869... USD
+	partial, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+		Amount:     10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, utils.EUR, partial.Transfer.FromCurrency)
+	require.Equal(t, utils.USD, partial.Transfer.ToCurrency)
+	require.Equal(t, int64(11), partial.Transfer.ToAmount)
+	require.Equal(t, "1.0869565217", partial.Transfer.ExchangeRate)
+
+	// the last reversal refunds exactly what is left, whatever the rounding
+	rest, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(82), rest.Transfer.Amount)
+	require.Equal(t, int64(89), rest.Transfer.ToAmount)
+	require.Equal(t, account1.Balance, rest.ToAccount.Balance)
+	require.Equal(t, account2.Balance, rest.FromAccount.Balance)
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy decides how often a transaction that failed with a retryable
+// error, see IsRetryable, is run again
+type RetryPolicy struct {
+	// MaxAttempts counts the first run, 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, it doubles with each
+	// retry up to MaxDelay. The actual delay is picked at random below it so
+	// that conflicting transactions don't retry in lockstep.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy retries a transaction twice within a few hundred milliseconds
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    200 * time.Millisecond,
+}
+
+// backoff returns the jittered delay before the retry that follows attempt
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := policy.BaseDelay
+	for i := 1; i < attempt && ceiling < policy.MaxDelay; i++ {
+		ceiling *= 2
+	}
+	if ceiling > policy.MaxDelay {
+		ceiling = policy.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+type StoreOptions struct {
+	// TransferIsolation is the isolation level of the transactions that move
+	// money: transfers, reversals, hold captures and batches
+	TransferIsolation sql.IsolationLevel
+	Retry             RetryPolicy
+}
+
+// DefaultStoreOptions runs transfers under the default isolation level of the
+// database, read committed for Postgres
+func DefaultStoreOptions() StoreOptions {
+	return StoreOptions{
+		TransferIsolation: sql.LevelDefault,
+		Retry:             DefaultRetryPolicy,
+	}
+}
+
+// ParseIsolationLevel reads an isolation level as configured, such as
+// "serializable" or "repeatable_read". An empty name is the database default.
+func ParseIsolationLevel(name string) (sql.IsolationLevel, error) {
+	switch name {
+	case "":
+		return sql.LevelDefault, nil
+	case "read_committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable_read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	}
+	return sql.LevelDefault, fmt.Errorf("unsupported isolation level %q", name)
+}
+
+// TxStats counts the retries of the store's transactions since it was created
+type TxStats struct {
+	// Retries is the number of times a transaction was run again
+	Retries int64 `json:"retries"`
+	// Exhausted is the number of transactions that still failed with a
+	// retryable error on their last attempt
+	Exhausted int64 `json:"exhausted"`
+}
+
+type txCounters struct {
+	retries   atomic.Int64
+	exhausted atomic.Int64
+}
+
+// TxStats returns the retry counters of the store
+func (store *SQLStore) TxStats() TxStats {
+	return TxStats{
+		Retries:   store.counters.retries.Load(),
+		Exhausted: store.counters.exhausted.Load(),
+	}
+}
+
+// transferTx returns the options of the transactions that move money
+func (store *SQLStore) transferTx() *sql.TxOptions {
+	return &sql.TxOptions{Isolation: store.options.TransferIsolation}
+}
+
+// execTx runs function in a transaction, opts may be nil for the defaults.
+// Deadlocks and serialization failures roll the transaction back and run it
+// again following the retry policy of the store, function must therefore only
+// have effects through q.
+func (store *SQLStore) execTx(ctx context.Context, opts *sql.TxOptions, function func(Querier) error) error {
+	policy := store.options.Retry
+	for attempt := 1; ; attempt++ {
+		err := store.runTx(ctx, opts, function)
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+		if attempt >= policy.MaxAttempts {
+			store.counters.exhausted.Add(1)
+			return err
+		}
+
+		store.counters.retries.Add(1)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(policy.backoff(attempt)):
+		}
+	}
+}
+
+func (store *SQLStore) runTx(ctx context.Context, opts *sql.TxOptions, function func(Querier) error) error {
+	tx, err := store.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	err = function(tx)
+	if err != nil {
+		err = TranslateError(err)
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx err: %w, rb err: %v", err, rbErr)
+		}
+		return err
+	}
+	return TranslateError(tx.Commit())
+}
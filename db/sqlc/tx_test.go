@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecTxRetries(t *testing.T) {
+	store := NewStoreWithOptions(testDB, StoreOptions{
+		Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+	}).(*SQLStore)
+
+	// a deadlock on the first two runs is absorbed
+	runs := 0
+	err := store.execTx(context.Background(), nil, func(q Querier) error {
+		runs++
+		if runs < 3 {
+			return &pq.Error{Code: DeadlockDetected}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, runs)
+	require.Equal(t, TxStats{Retries: 2}, store.TxStats())
+
+	// the last attempt gives up
+	err = store.execTx(context.Background(), nil, func(q Querier) error {
+		return &pq.Error{Code: SerializationFailure}
+	})
+	require.True(t, IsRetryable(err))
+	require.Equal(t, TxStats{Retries: 4, Exhausted: 1}, store.TxStats())
+
+	// other errors are not retried
+	runs = 0
+	err = store.execTx(context.Background(), nil, func(q Querier) error {
+		runs++
+		return ErrInsufficientFunds
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+	require.Equal(t, 1, runs)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond}
+	for i := 0; i < 100; i++ {
+		require.Less(t, policy.backoff(1), 10*time.Millisecond)
+		require.Less(t, policy.backoff(2), 20*time.Millisecond)
+		require.Less(t, policy.backoff(8), 40*time.Millisecond)
+		require.GreaterOrEqual(t, policy.backoff(8), time.Duration(0))
+	}
+}
+
+func TestParseIsolationLevel(t *testing.T) {
+	level, err := ParseIsolationLevel("serializable")
+	require.NoError(t, err)
+	require.Equal(t, sql.LevelSerializable, level)
+
+	level, err = ParseIsolationLevel("")
+	require.NoError(t, err)
+	require.Equal(t, sql.LevelDefault, level)
+
+	_, err = ParseIsolationLevel("snapshot")
+	require.Error(t, err)
+}
+
+// TestSerializableTransferTx hammers transfers in both directions between the
+// same accounts, deadlocks and serialization failures must not reach callers
+func TestSerializableTransferTx(t *testing.T) {
+	store := NewStoreWithOptions(testDB, StoreOptions{
+		TransferIsolation: sql.LevelSerializable,
+		Retry:             RetryPolicy{MaxAttempts: 20, BaseDelay: 5 * time.Millisecond, MaxDelay: 100 * time.Millisecond},
+	})
+	account1 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+	account2 := fundAccount(t, createRandomAccountInCurrency(t, utils.USD), 1000)
+
+	n := 40
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		fromAccountID, toAccountID := account1.ID, account2.ID
+		if i%2 == 1 {
+			fromAccountID, toAccountID = account2.ID, account1.ID
+		}
+		go func() {
+			_, err := store.TransferTx(context.Background(), TransferTxParams{
+				FromAccountID: fromAccountID,
+				ToAccountID:   toAccountID,
+				Amount:        10,
+			})
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	updated1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	updated2, err := testQueries.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), updated1.Balance)
+	require.Equal(t, int64(1000), updated2.Balance)
+	require.Zero(t, store.TxStats().Exhausted)
+}
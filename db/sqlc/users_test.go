@@ -22,6 +22,7 @@ func TestCreateUser(t *testing.T) {
 	require.Equal(t, createUser.HashedPassword, user.HashedPassword)
 	require.Equal(t, createUser.FullName, user.FullName)
 	require.Equal(t, createUser.Email, user.Email)
+	require.Equal(t, utils.DepositorRole, user.Role)
 
 	//
 	require.True(t, user.PasswordChangedAt.IsZero())
@@ -93,3 +94,31 @@ func TestUpdateUser(t *testing.T) {
 	require.Equal(t, userFromDB.Email, newEmail)
 	require.Equal(t, userFromDB.FullName, newFullname)
 }
+
+func TestListUsers(t *testing.T) {
+	for i := 0; i < 5; i++ {
+		_, err := CreateRandomUser()
+		require.NoError(t, err)
+	}
+
+	users, err := testQueries.ListUsers(context.Background(), ListUsersParams{
+		Limit: 5,
+	})
+	require.NoError(t, err)
+	require.Len(t, users, 5)
+	for i, user := range users {
+		require.NotEmpty(t, user)
+		require.NotEmpty(t, user.Role)
+		if i > 0 {
+			require.Less(t, users[i-1].Username, user.Username)
+		}
+	}
+
+	before, err := testQueries.ListUsersBefore(context.Background(), ListUsersBeforeParams{
+		BeforeUsername: users[4].Username,
+		Limit:          5,
+	})
+	require.NoError(t, err)
+	require.Len(t, before, 4)
+	require.Equal(t, users[3], before[0])
+}
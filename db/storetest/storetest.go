@@ -0,0 +1,676 @@
+// Package storetest is a conformance suite for implementations of db.Store.
+// It checks what callers rely on, results, errors and transactional
+// behavior, so that SQLStore and memstore can't drift apart.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the suite against store. The store may hold the data of other
+// tests, each check creates the users and accounts it looks at.
+func Run(t *testing.T, store db.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store db.Store)
+	}{
+		{"Users", testUsers},
+		{"Sessions", testSessions},
+		{"Currencies", testCurrencies},
+		{"Accounts", testAccounts},
+		{"BalanceCheck", testBalanceCheck},
+		{"TransferTx", testTransferTx},
+		{"ConcurrentTransferTx", testConcurrentTransferTx},
+		{"IdempotentTransferTx", testIdempotentTransferTx},
+		{"AtomicTransferBatchTx", testAtomicTransferBatchTx},
+		{"BestEffortTransferBatchTx", testBestEffortTransferBatchTx},
+		{"ReverseTransferTx", testReverseTransferTx},
+		{"Holds", testHolds},
+		{"CloseAccount", testCloseAccount},
+		{"Statement", testStatement},
+		{"VerifyLedger", testVerifyLedger},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, store)
+		})
+	}
+}
+
+func createUser(t *testing.T, store db.Store) db.User {
+	user, err := store.CreateUser(context.Background(), db.CreateUserParams{
+		Username:       utils.RandomOwner() + utils.RandomString(6),
+		HashedPassword: utils.RandomString(32),
+		FullName:       utils.RandomOwner(),
+		Email:          utils.RandomString(12) + "@email.com",
+	})
+	require.NoError(t, err)
+	return user
+}
+
+// createAccount opens an account for a new user, funded with a deposit entry
+// so that the ledger stays consistent
+func createAccount(t *testing.T, store db.Store, currency string, balance int64) db.Account {
+	user := createUser(t, store)
+	account, err := store.CreateAccount(context.Background(), db.CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  0,
+		Currency: currency,
+	})
+	require.NoError(t, err)
+
+	if balance != 0 {
+		_, err = store.CreateEntry(context.Background(), db.CreateEntryParams{
+			AccountID: account.ID,
+			Amount:    balance,
+		})
+		require.NoError(t, err)
+		account, err = store.AddAccountBalance(context.Background(), db.AddAccountBalanceParams{
+			ID:     account.ID,
+			Amount: balance,
+		})
+		require.NoError(t, err)
+	}
+	return account
+}
+
+func requireBalance(t *testing.T, store db.Store, accountID int64, balance int64) {
+	account, err := store.GetAccount(context.Background(), accountID)
+	require.NoError(t, err)
+	require.Equal(t, balance, account.Balance)
+}
+
+func requireConstraint(t *testing.T, err error, target error, constraint string) {
+	err = db.TranslateError(err)
+	require.ErrorIs(t, err, target)
+	require.Equal(t, constraint, db.ConstraintName(err))
+}
+
+func testUsers(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+	require.Equal(t, utils.DepositorRole, user.Role)
+	require.True(t, user.PasswordChangedAt.IsZero())
+	require.NotZero(t, user.CreatedAt)
+
+	got, err := store.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user.Email, got.Email)
+
+	_, err = store.GetUser(ctx, user.Username+"x")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = store.CreateUser(ctx, db.CreateUserParams{
+		Username:       user.Username,
+		HashedPassword: utils.RandomString(32),
+		FullName:       utils.RandomOwner(),
+		Email:          utils.RandomString(12) + "@email.com",
+	})
+	requireConstraint(t, err, db.ErrUniqueViolation, "users_pkey")
+
+	other := createUser(t, store)
+	_, err = store.CreateUser(ctx, db.CreateUserParams{
+		Username:       user.Username + "x",
+		HashedPassword: utils.RandomString(32),
+		FullName:       utils.RandomOwner(),
+		Email:          other.Email,
+	})
+	requireConstraint(t, err, db.ErrUniqueViolation, "users_email_key")
+
+	err = store.UpdateUser(ctx, db.UpdateUserParams{
+		FullName: user.FullName,
+		Email:    other.Email,
+		Username: user.Username,
+	})
+	requireConstraint(t, err, db.ErrUniqueViolation, "users_email_key")
+
+	err = store.UpdateUser(ctx, db.UpdateUserParams{
+		FullName: "New Name",
+		Email:    user.Email,
+		Username: user.Username,
+	})
+	require.NoError(t, err)
+	got, err = store.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, "New Name", got.FullName)
+
+	after, err := store.ListUsers(ctx, db.ListUsersParams{AfterUsername: user.Username, Limit: 10})
+	require.NoError(t, err)
+	for i, listed := range after {
+		require.Greater(t, listed.Username, user.Username)
+		if i > 0 {
+			require.Greater(t, listed.Username, after[i-1].Username)
+		}
+	}
+
+	before, err := store.ListUsersBefore(ctx, db.ListUsersBeforeParams{BeforeUsername: user.Username, Limit: 10})
+	require.NoError(t, err)
+	for i, listed := range before {
+		require.Less(t, listed.Username, user.Username)
+		if i > 0 {
+			require.Less(t, listed.Username, before[i-1].Username)
+		}
+	}
+}
+
+func testSessions(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	arg := db.CreateSessionParams{
+		ID:           uuid.New(),
+		Username:     user.Username,
+		RefreshToken: utils.RandomString(32),
+		UserAgent:    "go-test",
+		ClientIp:     "127.0.0.1",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+	session, err := store.CreateSession(ctx, arg)
+	require.NoError(t, err)
+	require.WithinDuration(t, arg.ExpiresAt, session.ExpiresAt, time.Millisecond)
+
+	_, err = store.CreateSession(ctx, arg)
+	requireConstraint(t, err, db.ErrUniqueViolation, "sessions_pkey")
+
+	arg.ID = uuid.New()
+	arg.Username = user.Username + "x"
+	_, err = store.CreateSession(ctx, arg)
+	requireConstraint(t, err, db.ErrForeignKeyViolation, "sessions_username_fkey")
+
+	require.NoError(t, store.BlockUserSessions(ctx, user.Username))
+	session, err = store.GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	require.True(t, session.IsBlocked)
+
+	_, err = store.BlockSession(ctx, uuid.New())
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testCurrencies(t *testing.T, store db.Store) {
+	currency, err := store.GetCurrency(context.Background(), utils.USD)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, currency.MinorUnits)
+
+	_, err = store.GetCurrency(context.Background(), "XXX")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	currencies, err := store.ListCurrencies(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(currencies), len(utils.DefaultCurrencies))
+	for i := 1; i < len(currencies); i++ {
+		require.Less(t, currencies[i-1].Code, currencies[i].Code)
+	}
+}
+
+func testAccounts(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account := createAccount(t, store, utils.USD, 0)
+	require.Equal(t, db.AccountActive, account.Status)
+	require.Zero(t, account.OverdraftLimit)
+
+	_, err := store.CreateAccount(ctx, db.CreateAccountParams{Owner: account.Owner, Currency: utils.USD})
+	requireConstraint(t, err, db.ErrUniqueViolation, "owner_current_key")
+
+	_, err = store.CreateAccount(ctx, db.CreateAccountParams{Owner: account.Owner + "x", Currency: utils.USD})
+	requireConstraint(t, err, db.ErrForeignKeyViolation, "accounts_owner_fkey")
+
+	_, err = store.GetAccount(ctx, account.ID+1_000_000)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// keyset pagination walks the accounts of the owner in both directions
+	eur, err := store.CreateAccount(ctx, db.CreateAccountParams{Owner: account.Owner, Currency: utils.EUR})
+	require.NoError(t, err)
+	cad, err := store.CreateAccount(ctx, db.CreateAccountParams{Owner: account.Owner, Currency: utils.CAD})
+	require.NoError(t, err)
+
+	accounts, err := store.ListAccountsByOwner(ctx, db.ListAccountsByOwnerParams{Owner: account.Owner, AfterID: account.ID, Limit: 5})
+	require.NoError(t, err)
+	require.Equal(t, []db.Account{eur, cad}, accounts)
+
+	accounts, err = store.ListAccountsByOwnerBefore(ctx, db.ListAccountsByOwnerBeforeParams{Owner: account.Owner, BeforeID: cad.ID, Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, []db.Account{eur}, accounts)
+
+	accounts, err = store.ListAccountsByOwner(ctx, db.ListAccountsByOwnerParams{Owner: account.Owner + "x", Limit: 5})
+	require.NoError(t, err)
+	require.NotNil(t, accounts)
+	require.Empty(t, accounts)
+
+	// status changes only apply from the expected status
+	frozen, err := store.FreezeAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.AccountFrozen, frozen.Status)
+	_, err = store.FreezeAccount(ctx, account.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = store.UnfreezeAccount(ctx, account.ID)
+	require.NoError(t, err)
+}
+
+func testBalanceCheck(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account := createAccount(t, store, utils.USD, 100)
+
+	_, err := store.AddAccountBalance(ctx, db.AddAccountBalanceParams{ID: account.ID, Amount: -101})
+	require.ErrorIs(t, db.TranslateError(err), db.ErrInsufficientFunds)
+	requireBalance(t, store, account.ID, 100)
+
+	_, err = store.UpdateAccountOverdraftLimit(ctx, db.UpdateAccountOverdraftLimitParams{ID: account.ID, OverdraftLimit: -1})
+	requireConstraint(t, err, db.ErrCheckViolation, "accounts_overdraft_limit_check")
+
+	_, err = store.UpdateAccountOverdraftLimit(ctx, db.UpdateAccountOverdraftLimitParams{ID: account.ID, OverdraftLimit: 50})
+	require.NoError(t, err)
+	updated, err := store.AddAccountBalance(ctx, db.AddAccountBalanceParams{ID: account.ID, Amount: -150})
+	require.NoError(t, err)
+	require.Equal(t, int64(-50), updated.Balance)
+
+	_, err = store.AddAccountBalance(ctx, db.AddAccountBalanceParams{ID: account.ID + 1_000_000, Amount: 1})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testTransferTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, utils.USD, 100)
+	account2 := createAccount(t, store, utils.USD, 0)
+
+	result, err := store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        30,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(70), result.FromAccount.Balance)
+	require.Equal(t, int64(30), result.ToAccount.Balance)
+	require.Equal(t, "1.0000000000", result.Transfer.ExchangeRate)
+	require.Equal(t, int64(-30), result.FromEntry.Amount)
+	require.Equal(t, result.Transfer.ID, result.ToEntry.TransferID.Int64)
+
+	transfer, err := store.GetTransfer(ctx, result.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.Transfer, transfer)
+
+	transfers, err := store.ListTransfers(ctx, db.ListTransfersParams{
+		Direction: "incoming",
+		AccountID: account2.ID,
+		BeforeID:  transfer.ID + 1,
+		Limit:     10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []db.Transfer{transfer}, transfers)
+
+	_, err = store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        71,
+	})
+	require.ErrorIs(t, err, db.ErrInsufficientFunds)
+	requireBalance(t, store, account1.ID, 70)
+
+	_, err = store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID + 1_000_000,
+		Amount:        1,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// a cross-currency transfer keeps the rate with the scale of its column
+	eur := createAccount(t, store, utils.EUR, 0)
+	result, err = store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   eur.ID,
+		Amount:        10,
+		ToAmount:      9,
+		ExchangeRate:  "0.92",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "0.9200000000", result.Transfer.ExchangeRate)
+	require.Equal(t, int64(9), result.ToAccount.Balance)
+}
+
+func testConcurrentTransferTx(t *testing.T, store db.Store) {
+	account1 := createAccount(t, store, utils.USD, 1000)
+	account2 := createAccount(t, store, utils.USD, 1000)
+
+	n := 20
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		fromAccountID, toAccountID := account1.ID, account2.ID
+		if i%2 == 1 {
+			fromAccountID, toAccountID = account2.ID, account1.ID
+		}
+		go func() {
+			_, err := store.TransferTx(context.Background(), db.TransferTxParams{
+				FromAccountID: fromAccountID,
+				ToAccountID:   toAccountID,
+				Amount:        10,
+			})
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	requireBalance(t, store, account1.ID, 1000)
+	requireBalance(t, store, account2.ID, 1000)
+}
+
+func testIdempotentTransferTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, utils.USD, 100)
+	account2 := createAccount(t, store, utils.USD, 0)
+
+	arg := db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+		Idempotency: &db.IdempotencyParams{
+			Username:    account1.Owner,
+			Key:         utils.RandomString(16),
+			RequestPath: "/transfers",
+			RequestHash: utils.RandomString(64),
+		},
+	}
+	result, err := store.TransferTx(ctx, arg)
+	require.NoError(t, err)
+
+	saved, err := store.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		Username: arg.Idempotency.Username,
+		Key:      arg.Idempotency.Key,
+	})
+	require.NoError(t, err)
+	var savedResult db.TransferTxResult
+	require.NoError(t, json.Unmarshal(saved.ResponseBody, &savedResult))
+	require.Equal(t, result.Transfer.ID, savedResult.Transfer.ID)
+
+	// the key is stored last, the second transfer is rolled back with it
+	_, err = store.TransferTx(ctx, arg)
+	require.True(t, db.IsIdempotencyConflict(err))
+	requireBalance(t, store, account1.ID, 90)
+	requireBalance(t, store, account2.ID, 10)
+}
+
+func testAtomicTransferBatchTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	payer := createAccount(t, store, utils.USD, 100)
+	payee1 := createAccount(t, store, utils.USD, 0)
+	payee2 := createAccount(t, store, utils.USD, 0)
+
+	_, err := store.TransferBatchTx(ctx, db.TransferBatchTxParams{
+		Owner: payer.Owner,
+		Mode:  db.BatchAtomic,
+		Transfers: []db.TransferTxParams{
+			{FromAccountID: payer.ID, ToAccountID: payee1.ID, Amount: 60},
+			{FromAccountID: payer.ID, ToAccountID: payee2.ID, Amount: 60},
+		},
+	})
+	require.ErrorIs(t, err, db.ErrInsufficientFunds)
+
+	// nothing of the failed batch was kept
+	requireBalance(t, store, payer.ID, 100)
+	requireBalance(t, store, payee1.ID, 0)
+	entries, err := store.ListEntries(ctx, db.ListEntriesParams{AccountID: payee1.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func testBestEffortTransferBatchTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	payer := createAccount(t, store, utils.USD, 100)
+	payee1 := createAccount(t, store, utils.USD, 0)
+	payee2 := createAccount(t, store, utils.USD, 0)
+
+	result, err := store.TransferBatchTx(ctx, db.TransferBatchTxParams{
+		Owner: payer.Owner,
+		Mode:  db.BatchBestEffort,
+		Transfers: []db.TransferTxParams{
+			{FromAccountID: payer.ID, ToAccountID: payee1.ID, Amount: 60},
+			{FromAccountID: payer.ID, ToAccountID: payee2.ID, Amount: 60},
+			{FromAccountID: payer.ID, ToAccountID: payee2.ID, Amount: 40},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, db.TransferBatchPartial, result.Batch.Status)
+	require.Equal(t, int32(1), result.Batch.FailedCount)
+
+	items, err := store.ListTransferBatchItems(ctx, result.Batch.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.Items, items)
+	require.Equal(t, db.TransferBatchItemFailed, items[1].Status)
+
+	requireBalance(t, store, payer.ID, 0)
+	requireBalance(t, store, payee1.ID, 60)
+	requireBalance(t, store, payee2.ID, 40)
+}
+
+func testReverseTransferTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, utils.USD, 100)
+	account2 := createAccount(t, store, utils.USD, 0)
+
+	original, err := store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        50,
+	})
+	require.NoError(t, err)
+
+	reversal, err := store.ReverseTransferTx(ctx, db.ReverseTransferTxParams{TransferID: original.Transfer.ID, Amount: 20})
+	require.NoError(t, err)
+	require.Equal(t, original.Transfer.ID, reversal.Transfer.ReversalOf.Int64)
+
+	totals, err := store.GetReversedTotals(ctx, sql.NullInt64{Int64: original.Transfer.ID, Valid: true})
+	require.NoError(t, err)
+	require.Equal(t, int64(20), totals.ReversedAmount)
+
+	_, err = store.ReverseTransferTx(ctx, db.ReverseTransferTxParams{TransferID: original.Transfer.ID, Amount: 31})
+	require.ErrorIs(t, err, db.ErrReversalExceedsTransfer)
+
+	_, err = store.ReverseTransferTx(ctx, db.ReverseTransferTxParams{TransferID: original.Transfer.ID})
+	require.NoError(t, err)
+	requireBalance(t, store, account1.ID, 100)
+	requireBalance(t, store, account2.ID, 0)
+}
+
+func testHolds(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, utils.USD, 100)
+	account2 := createAccount(t, store, utils.USD, 0)
+
+	hold, err := store.CreateHold(ctx, db.CreateHoldParams{
+		AccountID:   account1.ID,
+		ToAccountID: account2.ID,
+		Amount:      80,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, db.HoldActive, hold.Status)
+
+	held, err := store.GetHeldAmount(ctx, account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(80), held)
+
+	// the held funds can't be transferred
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 30})
+	require.ErrorIs(t, err, db.ErrInsufficientFunds)
+
+	result, err := store.CaptureHold(ctx, db.CaptureHoldParams{HoldID: hold.ID, Amount: 50})
+	require.NoError(t, err)
+	require.Equal(t, db.HoldCaptured, result.Hold.Status)
+	require.Equal(t, result.Transfer.ID, result.Hold.TransferID.Int64)
+	require.Equal(t, int64(50), result.FromAccount.Balance)
+
+	_, err = store.VoidHold(ctx, hold.ID)
+	require.ErrorIs(t, err, db.ErrHoldNotActive)
+	_, err = store.VoidHold(ctx, hold.ID+1_000_000)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = store.InsertHold(ctx, db.InsertHoldParams{
+		AccountID:   account1.ID,
+		ToAccountID: account2.ID,
+		Amount:      0,
+		Currency:    utils.USD,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	requireConstraint(t, err, db.ErrCheckViolation, "holds_amount_check")
+
+	// an expired hold no longer reserves funds
+	expired, err := store.InsertHold(ctx, db.InsertHoldParams{
+		AccountID:   account1.ID,
+		ToAccountID: account2.ID,
+		Amount:      10,
+		Currency:    utils.USD,
+		ExpiresAt:   time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	held, err = store.GetHeldAmount(ctx, account1.ID)
+	require.NoError(t, err)
+	require.Zero(t, held)
+	require.Equal(t, db.HoldExpired, db.HoldStatus(expired))
+}
+
+func testCloseAccount(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, utils.USD, 10)
+	account2 := createAccount(t, store, utils.USD, 0)
+
+	_, err := store.CloseAccount(ctx, account1.ID)
+	require.ErrorIs(t, err, db.ErrAccountNotEmpty)
+
+	closed, err := store.CloseAccount(ctx, account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.AccountClosed, closed.Status)
+
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 1})
+	require.ErrorIs(t, err, db.ErrAccountClosed)
+	requireBalance(t, store, account1.ID, 10)
+
+	_, err = store.CloseAccount(ctx, account2.ID+1_000_000)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testStatement(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	from := time.Now().Add(-time.Minute)
+	account1 := createAccount(t, store, utils.USD, 1000)
+	account2 := createAccount(t, store, utils.USD, 0)
+
+	for _, amount := range []int64{100, 50} {
+		_, err := store.TransferTx(ctx, db.TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		})
+		require.NoError(t, err)
+	}
+
+	opening, err := store.GetOpeningBalance(ctx, db.GetOpeningBalanceParams{FromTime: from, AccountID: account1.ID})
+	require.NoError(t, err)
+	require.Zero(t, opening)
+
+	arg := db.ListStatementLinesParams{
+		AccountID: account1.ID,
+		FromTime:  from,
+		ToTime:    time.Now().Add(time.Minute),
+	}
+	lines, err := store.ListStatementLines(ctx, arg)
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+
+	// the deposit has no counterparty, the transfers have account2
+	require.Zero(t, lines[0].CounterpartyAccountID)
+	require.Equal(t, []int64{1000, 900, 850}, []int64{lines[0].RunningTotal, lines[1].RunningTotal, lines[2].RunningTotal})
+	require.Equal(t, account2.ID, lines[1].CounterpartyAccountID)
+	require.Equal(t, account2.Owner, lines[2].CounterpartyOwner)
+
+	var streamed []db.ListStatementLinesRow
+	err = store.StreamStatementLines(ctx, arg, func(line db.ListStatementLinesRow) error {
+		streamed = append(streamed, line)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, lines, streamed)
+
+	// the snapshot reconciles, opening balance and lines add up to the closing one
+	var balances db.StatementBalances
+	streamed = nil
+	err = store.StatementTx(ctx, arg, func(read db.StatementBalances) error {
+		balances = read
+		return nil
+	}, func(line db.ListStatementLinesRow) error {
+		streamed = append(streamed, line)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, lines, streamed)
+	require.Equal(t, db.StatementBalances{Opening: 0, Closing: 850}, balances)
+
+	stop := errors.New("stop")
+	err = store.StatementTx(ctx, arg, func(db.StatementBalances) error {
+		return stop
+	}, func(line db.ListStatementLinesRow) error {
+		t.Error("lines are read after begin failed")
+		return nil
+	})
+	require.ErrorIs(t, err, stop)
+
+	err = store.StreamStatementLines(ctx, arg, func(line db.ListStatementLinesRow) error {
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+}
+
+func testVerifyLedger(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account1 := createAccount(t, store, utils.USD, 100)
+	account2 := createAccount(t, store, utils.USD, 0)
+
+	result, err := store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	// an entry booked without touching the balance breaks both checks
+	_, err = store.CreateEntry(ctx, db.CreateEntryParams{
+		AccountID:  account1.ID,
+		Amount:     -5,
+		TransferID: sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+	})
+	require.NoError(t, err)
+
+	_, err = store.CreateEntry(ctx, db.CreateEntryParams{AccountID: account1.ID + 1_000_000, Amount: 1})
+	requireConstraint(t, err, db.ErrForeignKeyViolation, "entries_account_id_fkey")
+
+	for _, snapshot := range []bool{false, true} {
+		report, err := store.VerifyLedger(ctx, db.VerifyLedgerParams{Snapshot: snapshot})
+		require.NoError(t, err)
+		require.NotZero(t, report.Accounts)
+
+		// other tests may have left discrepancies of their own
+		found := map[string]bool{}
+		for _, discrepancy := range report.Discrepancies {
+			switch {
+			case discrepancy.AccountID == account2.ID:
+				t.Errorf("unexpected discrepancy: %s", discrepancy.Detail)
+			case discrepancy.Kind == db.DiscrepancyBalance && discrepancy.AccountID == account1.ID:
+				found[db.DiscrepancyBalance] = true
+			case discrepancy.Kind == db.DiscrepancyTransfer && discrepancy.TransferID == result.Transfer.ID:
+				require.Equal(t, "has 3 entries instead of 2", discrepancy.Detail)
+				found[db.DiscrepancyTransfer] = true
+			}
+		}
+		require.Len(t, found, 2)
+	}
+}
@@ -0,0 +1,137 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+// camt053Writer renders ISO 20022 BankToCustomerStatement (camt.053.001.02) messages
+type camt053Writer struct {
+	*xmlWriter
+	statement Statement
+	lines     int
+}
+
+func newCamt053Writer(w io.Writer) *camt053Writer {
+	return &camt053Writer{xmlWriter: newXMLWriter(w)}
+}
+
+func (writer *camt053Writer) Begin(statement Statement) error {
+	writer.statement = statement
+	id := fmt.Sprintf("STMT-%d-%s-%s", statement.AccountID,
+		statement.From.Format("20060102"), statement.To.Format("20060102"))
+
+	writer.raw(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8"`)})
+	writer.start("Document", xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: camt053Namespace})
+	writer.start("BkToCstmrStmt")
+
+	writer.start("GrpHdr")
+	writer.text("MsgId", id)
+	writer.text("CreDtTm", camtTime(statement.GeneratedAt))
+	writer.end("GrpHdr")
+
+	writer.start("Stmt")
+	writer.text("Id", id)
+	writer.text("CreDtTm", camtTime(statement.GeneratedAt))
+	writer.start("FrToDt")
+	writer.text("FrDtTm", camtTime(statement.From))
+	writer.text("ToDtTm", camtTime(statement.To.AddDate(0, 0, 1).Add(-time.Second)))
+	writer.end("FrToDt")
+
+	writer.start("Acct")
+	writer.start("Id")
+	writer.start("Othr")
+	writer.text("Id", strconv.FormatInt(statement.AccountID, 10))
+	writer.end("Othr")
+	writer.end("Id")
+	writer.text("Ccy", statement.Currency)
+	writer.start("Ownr")
+	writer.text("Nm", statement.Owner)
+	writer.end("Ownr")
+	writer.end("Acct")
+
+	// camt.053 lists both balances before the entries
+	writer.balance("OPBD", statement.OpeningBalance, statement.From)
+	writer.balance("CLBD", statement.ClosingBalance, statement.To)
+	return writer.flush()
+}
+
+func (writer *camt053Writer) WriteLine(line Line) error {
+	writer.start("Ntry")
+	writer.text("NtryRef", strconv.FormatInt(line.EntryID, 10))
+	writer.amount(line.Amount)
+	writer.text("Sts", "BOOK")
+	writer.start("BookgDt")
+	writer.text("DtTm", camtTime(line.BookedAt))
+	writer.end("BookgDt")
+	writer.start("ValDt")
+	writer.text("DtTm", camtTime(line.BookedAt))
+	writer.end("ValDt")
+	writer.start("BkTxCd")
+	writer.start("Prtry")
+	writer.text("Cd", "TRANSFER")
+	writer.end("Prtry")
+	writer.end("BkTxCd")
+	if line.TransferID != 0 {
+		writer.start("NtryDtls")
+		writer.start("TxDtls")
+		writer.start("Refs")
+		writer.text("EndToEndId", strconv.FormatInt(line.TransferID, 10))
+		writer.end("Refs")
+		writer.end("TxDtls")
+		writer.end("NtryDtls")
+		writer.text("AddtlNtryInf", fmt.Sprintf("transfer %d with account %d (%s)",
+			line.TransferID, line.CounterpartyAccountID, line.CounterpartyOwner))
+	}
+	writer.end("Ntry")
+
+	writer.lines++
+	if writer.lines%flushEvery == 0 {
+		return writer.flush()
+	}
+	return writer.err
+}
+
+func (writer *camt053Writer) End() error {
+	writer.end("Stmt")
+	writer.end("BkToCstmrStmt")
+	writer.end("Document")
+	return writer.flush()
+}
+
+func (writer *camt053Writer) balance(code string, amount int64, date time.Time) {
+	writer.start("Bal")
+	writer.start("Tp")
+	writer.start("CdOrPrtry")
+	writer.text("Cd", code)
+	writer.end("CdOrPrtry")
+	writer.end("Tp")
+	writer.amount(amount)
+	writer.start("Dt")
+	writer.text("Dt", date.Format("2006-01-02"))
+	writer.end("Dt")
+	writer.end("Bal")
+}
+
+// amount writes the unsigned Amt and its CdtDbtInd, camt.053 never uses negative amounts
+func (writer *camt053Writer) amount(amount int64) {
+	indicator := "CRDT"
+	if amount < 0 {
+		indicator = "DBIT"
+		amount = -amount
+	}
+	currency := writer.statement.Currency
+	writer.text("Amt", utils.NewMoney(amount, currency).String(), xml.Attr{Name: xml.Name{Local: "Ccy"}, Value: currency})
+	writer.text("CdtDbtInd", indicator)
+}
+
+func camtTime(value time.Time) string {
+	return value.UTC().Format("2006-01-02T15:04:05")
+}
@@ -0,0 +1,72 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+var csvHeader = []string{
+	"booked_at",
+	"entry_id",
+	"transfer_id",
+	"counterparty_account_id",
+	"counterparty_owner",
+	"amount",
+	"running_balance",
+	"currency",
+}
+
+type csvWriter struct {
+	writer    *csv.Writer
+	statement Statement
+	lines     int
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+func (writer *csvWriter) Begin(statement Statement) error {
+	writer.statement = statement
+	return writer.writer.Write(csvHeader)
+}
+
+func (writer *csvWriter) WriteLine(line Line) error {
+	currency := writer.statement.Currency
+	err := writer.writer.Write([]string{
+		line.BookedAt.UTC().Format(time.RFC3339),
+		strconv.FormatInt(line.EntryID, 10),
+		optionalID(line.TransferID),
+		optionalID(line.CounterpartyAccountID),
+		line.CounterpartyOwner,
+		utils.NewMoney(line.Amount, currency).String(),
+		utils.NewMoney(line.RunningBalance, currency).String(),
+		currency,
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.lines++
+	if writer.lines%flushEvery == 0 {
+		writer.writer.Flush()
+		return writer.writer.Error()
+	}
+	return nil
+}
+
+func (writer *csvWriter) End() error {
+	writer.writer.Flush()
+	return writer.writer.Error()
+}
+
+func optionalID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,105 @@
+// Package export renders account statements in the file formats used by
+// accounting and reconciliation tools. Writers stream line by line so a
+// statement never has to be held in memory.
+package export
+
+import (
+	"io"
+	"mime"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	CSV     Format = "csv"
+	OFX     Format = "ofx"
+	Camt053 Format = "camt053"
+)
+
+// flushEvery bounds how many lines a writer buffers before writing them out
+const flushEvery = 100
+
+var formats = map[Format]struct {
+	contentType string
+	extension   string
+}{
+	CSV:     {contentType: "text/csv", extension: "csv"},
+	OFX:     {contentType: "application/x-ofx", extension: "ofx"},
+	Camt053: {contentType: "application/xml", extension: "xml"},
+}
+
+// ParseFormat accepts the values of the format query parameter
+func ParseFormat(value string) (Format, bool) {
+	format := Format(strings.ToLower(value))
+	_, ok := formats[format]
+	return format, ok
+}
+
+// FormatFromAccept picks the first export format listed in an Accept header
+func FormatFromAccept(accept string) (Format, bool) {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		for format, info := range formats {
+			if mediaType == info.contentType {
+				return format, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (format Format) ContentType() string {
+	return formats[format].contentType
+}
+
+func (format Format) Extension() string {
+	return formats[format].extension
+}
+
+// Statement is the header of an export, amounts are in minor units of Currency
+type Statement struct {
+	AccountID      int64
+	Owner          string
+	Currency       string
+	From           time.Time // first day included
+	To             time.Time // last day included
+	OpeningBalance int64
+	ClosingBalance int64
+	GeneratedAt    time.Time
+}
+
+// Line is one booked entry of the statement
+type Line struct {
+	EntryID int64
+	// TransferID and CounterpartyAccountID are zero for entries not booked by a transfer
+	TransferID            int64
+	CounterpartyAccountID int64
+	CounterpartyOwner     string
+	Amount                int64
+	RunningBalance        int64
+	BookedAt              time.Time
+}
+
+// Writer renders a statement, Begin is called once, then WriteLine for every
+// line in booking order and End once all lines are written
+type Writer interface {
+	Begin(statement Statement) error
+	WriteLine(line Line) error
+	End() error
+}
+
+func NewWriter(format Format, w io.Writer) Writer {
+	switch format {
+	case OFX:
+		return newOFXWriter(w)
+	case Camt053:
+		return newCamt053Writer(w)
+	default:
+		return newCSVWriter(w)
+	}
+}
@@ -0,0 +1,166 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func testStatement() (Statement, []Line) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	statement := Statement{
+		AccountID:      42,
+		Owner:          "alice",
+		Currency:       utils.USD,
+		From:           from,
+		To:             time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+		OpeningBalance: 500,
+		ClosingBalance: 1250,
+		GeneratedAt:    time.Date(2024, 4, 1, 8, 0, 0, 0, time.UTC),
+	}
+	lines := []Line{
+		{
+			EntryID:               1,
+			TransferID:            7,
+			CounterpartyAccountID: 43,
+			CounterpartyOwner:     "bob & co",
+			Amount:                -250,
+			RunningBalance:        250,
+			BookedAt:              from.Add(time.Hour),
+		},
+		{
+			EntryID:        2,
+			Amount:         1000,
+			RunningBalance: 1250,
+			BookedAt:       from.Add(2 * time.Hour),
+		},
+	}
+	return statement, lines
+}
+
+func render(t *testing.T, format Format) string {
+	statement, lines := testStatement()
+
+	var buffer bytes.Buffer
+	writer := NewWriter(format, &buffer)
+	require.NoError(t, writer.Begin(statement))
+	for _, line := range lines {
+		require.NoError(t, writer.WriteLine(line))
+	}
+	require.NoError(t, writer.End())
+	return buffer.String()
+}
+
+func TestParseFormat(t *testing.T) {
+	format, ok := ParseFormat("CSV")
+	require.True(t, ok)
+	require.Equal(t, CSV, format)
+
+	_, ok = ParseFormat("json")
+	require.False(t, ok)
+}
+
+func TestFormatFromAccept(t *testing.T) {
+	format, ok := FormatFromAccept("application/json;q=0.9, application/x-ofx")
+	require.True(t, ok)
+	require.Equal(t, OFX, format)
+
+	format, ok = FormatFromAccept("text/csv; charset=utf-8")
+	require.True(t, ok)
+	require.Equal(t, CSV, format)
+
+	_, ok = FormatFromAccept("application/json")
+	require.False(t, ok)
+
+	_, ok = FormatFromAccept("")
+	require.False(t, ok)
+}
+
+func TestCSVWriter(t *testing.T) {
+	expected := strings.Join([]string{
+		"booked_at,entry_id,transfer_id,counterparty_account_id,counterparty_owner,amount,running_balance,currency",
+		"2024-03-01T01:00:00Z,1,7,43,bob & co,-2.50,2.50,USD",
+		"2024-03-01T02:00:00Z,2,,,,10.00,12.50,USD",
+		"",
+	}, "\n")
+	require.Equal(t, expected, render(t, CSV))
+}
+
+func TestOFXWriter(t *testing.T) {
+	output := render(t, OFX)
+	require.True(t, strings.HasPrefix(output, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>`))
+	require.Contains(t, output, `<?OFX OFXHEADER="200" VERSION="220"`)
+
+	var document struct {
+		Currency     string `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>CURDEF"`
+		AccountID    string `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>BANKACCTFROM>ACCTID"`
+		Transactions []struct {
+			Type   string `xml:"TRNTYPE"`
+			Amount string `xml:"TRNAMT"`
+			ID     string `xml:"FITID"`
+			Name   string `xml:"NAME"`
+		} `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>BANKTRANLIST>STMTTRN"`
+		LedgerBalance string `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS>LEDGERBAL>BALAMT"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(output), &document))
+
+	require.Equal(t, utils.USD, document.Currency)
+	require.Equal(t, "42", document.AccountID)
+	require.Len(t, document.Transactions, 2)
+	require.Equal(t, "DEBIT", document.Transactions[0].Type)
+	require.Equal(t, "-2.50", document.Transactions[0].Amount)
+	require.Equal(t, "bob & co", document.Transactions[0].Name)
+	require.Equal(t, "CREDIT", document.Transactions[1].Type)
+	require.Equal(t, "12.50", document.LedgerBalance)
+}
+
+func TestCamt053Writer(t *testing.T) {
+	output := render(t, Camt053)
+
+	type amount struct {
+		Value    string `xml:",chardata"`
+		Currency string `xml:"Ccy,attr"`
+	}
+	var document struct {
+		XMLName   xml.Name
+		Statement struct {
+			Account  string `xml:"Acct>Id>Othr>Id"`
+			Balances []struct {
+				Code      string `xml:"Tp>CdOrPrtry>Cd"`
+				Amount    amount `xml:"Amt"`
+				Indicator string `xml:"CdtDbtInd"`
+			} `xml:"Bal"`
+			Entries []struct {
+				Reference string `xml:"NtryRef"`
+				Amount    amount `xml:"Amt"`
+				Indicator string `xml:"CdtDbtInd"`
+				EndToEnd  string `xml:"NtryDtls>TxDtls>Refs>EndToEndId"`
+			} `xml:"Ntry"`
+		} `xml:"BkToCstmrStmt>Stmt"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(output), &document))
+
+	require.Equal(t, camt053Namespace, document.XMLName.Space)
+	require.Equal(t, "42", document.Statement.Account)
+
+	balances := document.Statement.Balances
+	require.Len(t, balances, 2)
+	require.Equal(t, "OPBD", balances[0].Code)
+	require.Equal(t, "5.00", balances[0].Amount.Value)
+	require.Equal(t, "CLBD", balances[1].Code)
+	require.Equal(t, "12.50", balances[1].Amount.Value)
+
+	entries := document.Statement.Entries
+	require.Len(t, entries, 2)
+	require.Equal(t, "2.50", entries[0].Amount.Value)
+	require.Equal(t, utils.USD, entries[0].Amount.Currency)
+	require.Equal(t, "DBIT", entries[0].Indicator)
+	require.Equal(t, "7", entries[0].EndToEnd)
+	require.Equal(t, "CRDT", entries[1].Indicator)
+	require.Empty(t, entries[1].EndToEnd)
+}
@@ -0,0 +1,112 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+const (
+	ofxBankID     = "SIMPLEBANK"
+	ofxTimeFormat = "20060102150405"
+)
+
+// ofxWriter renders OFX 2.2 bank statement responses
+type ofxWriter struct {
+	*xmlWriter
+	statement Statement
+	lines     int
+}
+
+func newOFXWriter(w io.Writer) *ofxWriter {
+	return &ofxWriter{xmlWriter: newXMLWriter(w)}
+}
+
+func (writer *ofxWriter) Begin(statement Statement) error {
+	writer.statement = statement
+
+	writer.raw(xml.ProcInst{Target: "xml", Inst: []byte(`version="1.0" encoding="UTF-8" standalone="no"`)})
+	writer.raw(xml.ProcInst{Target: "OFX", Inst: []byte(`OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"`)})
+	writer.start("OFX")
+
+	writer.start("SIGNONMSGSRSV1")
+	writer.start("SONRS")
+	writer.ofxStatus()
+	writer.text("DTSERVER", ofxTime(statement.GeneratedAt))
+	writer.text("LANGUAGE", "ENG")
+	writer.end("SONRS")
+	writer.end("SIGNONMSGSRSV1")
+
+	writer.start("BANKMSGSRSV1")
+	writer.start("STMTTRNRS")
+	writer.text("TRNUID", "0")
+	writer.ofxStatus()
+	writer.start("STMTRS")
+	writer.text("CURDEF", statement.Currency)
+	writer.start("BANKACCTFROM")
+	writer.text("BANKID", ofxBankID)
+	writer.text("ACCTID", strconv.FormatInt(statement.AccountID, 10))
+	writer.text("ACCTTYPE", "CHECKING")
+	writer.end("BANKACCTFROM")
+
+	writer.start("BANKTRANLIST")
+	writer.text("DTSTART", ofxTime(statement.From))
+	writer.text("DTEND", ofxTime(statement.To.AddDate(0, 0, 1)))
+	return writer.flush()
+}
+
+func (writer *ofxWriter) WriteLine(line Line) error {
+	transactionType := "CREDIT"
+	if line.Amount < 0 {
+		transactionType = "DEBIT"
+	}
+
+	writer.start("STMTTRN")
+	writer.text("TRNTYPE", transactionType)
+	writer.text("DTPOSTED", ofxTime(line.BookedAt))
+	writer.text("TRNAMT", utils.NewMoney(line.Amount, writer.statement.Currency).String())
+	writer.text("FITID", strconv.FormatInt(line.EntryID, 10))
+	if line.CounterpartyOwner != "" {
+		writer.text("NAME", line.CounterpartyOwner)
+	}
+	if line.TransferID != 0 {
+		writer.text("MEMO", fmt.Sprintf("transfer %d with account %d", line.TransferID, line.CounterpartyAccountID))
+	}
+	writer.end("STMTTRN")
+
+	writer.lines++
+	if writer.lines%flushEvery == 0 {
+		return writer.flush()
+	}
+	return writer.err
+}
+
+func (writer *ofxWriter) End() error {
+	statement := writer.statement
+
+	writer.end("BANKTRANLIST")
+	writer.start("LEDGERBAL")
+	writer.text("BALAMT", utils.NewMoney(statement.ClosingBalance, statement.Currency).String())
+	writer.text("DTASOF", ofxTime(statement.To.AddDate(0, 0, 1)))
+	writer.end("LEDGERBAL")
+	writer.end("STMTRS")
+	writer.end("STMTTRNRS")
+	writer.end("BANKMSGSRSV1")
+	writer.end("OFX")
+	return writer.flush()
+}
+
+func (writer *ofxWriter) ofxStatus() {
+	writer.start("STATUS")
+	writer.text("CODE", "0")
+	writer.text("SEVERITY", "INFO")
+	writer.end("STATUS")
+}
+
+func ofxTime(value time.Time) string {
+	return value.UTC().Format(ofxTimeFormat)
+}
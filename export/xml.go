@@ -0,0 +1,52 @@
+package export
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// xmlWriter wraps an encoder with helpers for the nested, mostly text-only
+// elements of OFX and camt.053, the first error sticks and is returned by flush
+type xmlWriter struct {
+	encoder *xml.Encoder
+	err     error
+}
+
+func newXMLWriter(w io.Writer) *xmlWriter {
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return &xmlWriter{encoder: encoder}
+}
+
+func (writer *xmlWriter) start(name string, attrs ...xml.Attr) {
+	if writer.err == nil {
+		writer.err = writer.encoder.EncodeToken(xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs})
+	}
+}
+
+func (writer *xmlWriter) end(name string) {
+	if writer.err == nil {
+		writer.err = writer.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+	}
+}
+
+func (writer *xmlWriter) text(name string, value string, attrs ...xml.Attr) {
+	writer.start(name, attrs...)
+	if writer.err == nil {
+		writer.err = writer.encoder.EncodeToken(xml.CharData(value))
+	}
+	writer.end(name)
+}
+
+func (writer *xmlWriter) raw(token xml.Token) {
+	if writer.err == nil {
+		writer.err = writer.encoder.EncodeToken(token)
+	}
+}
+
+func (writer *xmlWriter) flush() error {
+	if writer.err == nil {
+		writer.err = writer.encoder.Flush()
+	}
+	return writer.err
+}
@@ -0,0 +1,56 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileRateProvider reads a JSON table of rates such as {"USD/EUR": "0.92"}
+// from disk, it is meant for local testing where rates are edited by hand
+type FileRateProvider struct {
+	path string
+
+	mutex  sync.RWMutex
+	static *StaticRateProvider
+}
+
+func NewFileRateProvider(path string) (*FileRateProvider, error) {
+	provider := &FileRateProvider{path: path}
+	if err := provider.Reload(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// Reload reads the file again, the previous table is kept if the file is invalid
+func (provider *FileRateProvider) Reload() error {
+	data, err := os.ReadFile(provider.path)
+	if err != nil {
+		return fmt.Errorf("cannot read rates file: %w", err)
+	}
+
+	var table map[string]string
+	if err := json.Unmarshal(data, &table); err != nil {
+		return fmt.Errorf("cannot parse rates file %s: %w", provider.path, err)
+	}
+
+	static, err := NewStaticRateProvider(table)
+	if err != nil {
+		return err
+	}
+
+	provider.mutex.Lock()
+	provider.static = static
+	provider.mutex.Unlock()
+	return nil
+}
+
+func (provider *FileRateProvider) Rate(ctx context.Context, from string, to string) (Rate, error) {
+	provider.mutex.RLock()
+	defer provider.mutex.RUnlock()
+
+	return provider.static.Rate(ctx, from, to)
+}
@@ -0,0 +1,119 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+var (
+	ErrUnsupportedPair = errors.New("unsupported currency pair")
+	ErrInvalidRate     = errors.New("invalid exchange rate")
+	ErrAmountOverflow  = errors.New("converted amount is out of range")
+)
+
+// RateProvider quotes the exchange rate between two currencies
+type RateProvider interface {
+	// Rate returns how many units of the to currency one unit of the from currency buys
+	Rate(ctx context.Context, from string, to string) (Rate, error)
+}
+
+// NewRateProvider reads rates from file when set, otherwise DefaultRates are used
+func NewRateProvider(file string) (RateProvider, error) {
+	if file != "" {
+		return NewFileRateProvider(file)
+	}
+	return NewStaticRateProvider(DefaultRates)
+}
+
+// Rate is an exchange rate kept as an exact fraction so conversions don't
+// suffer from floating point drift
+type Rate struct {
+	From  string
+	To    string
+	value *big.Rat
+}
+
+// NewRate parses a decimal rate such as "0.9215"
+func NewRate(from string, to string, value string) (Rate, error) {
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok || rat.Sign() <= 0 {
+		return Rate{}, fmt.Errorf("%w: %s/%s = %q", ErrInvalidRate, from, to, value)
+	}
+	return Rate{From: from, To: to, value: rat}, nil
+}
+
+// identityRate is used for same-currency transfers
+func identityRate(currency string) Rate {
+	return Rate{From: currency, To: currency, value: big.NewRat(1, 1)}
+}
+
+// Inverse returns the rate of the opposite direction
+func (rate Rate) Inverse() Rate {
+	return Rate{From: rate.To, To: rate.From, value: new(big.Rat).Inv(rate.value)}
+}
+
+// Convert converts an amount in minor units of From into minor units of To,
+// rounding half away from zero. The rate is quoted in major units so the
+// difference in decimal places between both currencies is applied as well.
+// ErrAmountOverflow is returned when the result doesn't fit in an int64.
+func (rate Rate) Convert(amount int64) (int64, error) {
+	converted := new(big.Rat).Mul(big.NewRat(amount, 1), rate.value)
+	converted.Mul(converted, minorUnitScale(rate.From, rate.To))
+
+	num := converted.Num()
+	den := converted.Denom()
+	quotient, remainder := new(big.Int).QuoRem(num, den, new(big.Int))
+
+	// |remainder| * 2 >= den means the fraction is at least one half
+	if remainder.Sign() != 0 && new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	if !quotient.IsInt64() {
+		return 0, fmt.Errorf("%w: %d %s in %s", ErrAmountOverflow, amount, rate.From, rate.To)
+	}
+	return quotient.Int64(), nil
+}
+
+// String formats the rate as a decimal with up to 10 fractional digits,
+// matching the precision of transfers.exchange_rate
+func (rate Rate) String() string {
+	value := rate.value.FloatString(10)
+	value = strings.TrimRight(value, "0")
+	return strings.TrimSuffix(value, ".")
+}
+
+// minorUnitScale is 10^(to minor units - from minor units), 1 when either currency is unknown
+func minorUnitScale(from string, to string) *big.Rat {
+	fromCurrency, fromOk := utils.Currencies.Lookup(from)
+	toCurrency, toOk := utils.Currencies.Lookup(to)
+	if !fromOk || !toOk {
+		return big.NewRat(1, 1)
+	}
+
+	diff := toCurrency.MinorUnits - fromCurrency.MinorUnits
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(diff))), nil)
+	if diff < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), scale)
+	}
+	return new(big.Rat).SetInt(scale)
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func pairKey(from string, to string) string {
+	return from + "/" + to
+}
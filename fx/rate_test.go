@@ -0,0 +1,157 @@
+package fx
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateConvert(t *testing.T) {
+	rate, err := NewRate(utils.USD, utils.EUR, "0.92")
+	require.NoError(t, err)
+
+	requireConverted(t, 92, rate, 100)
+	requireConverted(t, 9, rate, 10)
+	requireConverted(t, 5, rate, 5) // 4.6 rounds up
+	requireConverted(t, -9, rate, -10)
+	require.Equal(t, "0.92", rate.String())
+
+	half, err := NewRate(utils.USD, utils.EUR, "0.5")
+	require.NoError(t, err)
+	requireConverted(t, 2, half, 3) // 1.5 rounds away from zero
+	requireConverted(t, -2, half, -3)
+}
+
+func TestRateConvertMinorUnits(t *testing.T) {
+	// 1.00 USD buys 149.5 JPY, JPY has no decimal places
+	rate, err := NewRate(utils.USD, utils.JPY, "149.5")
+	require.NoError(t, err)
+	requireConverted(t, 150, rate, 100)
+	requireConverted(t, 1495, rate, 1000)
+
+	// 150 JPY is about 1.00 USD
+	requireConverted(t, 100, rate.Inverse(), 150)
+
+	// 1.00 USD buys 0.376 BHD, BHD has three decimal places
+	rate, err = NewRate(utils.USD, utils.BHD, "0.376")
+	require.NoError(t, err)
+	requireConverted(t, 376, rate, 100)
+}
+
+func TestRateConvertOverflow(t *testing.T) {
+	rate, err := NewRate(utils.USD, utils.EUR, "1.5")
+	require.NoError(t, err)
+
+	_, err = rate.Convert(math.MaxInt64)
+	require.ErrorIs(t, err, ErrAmountOverflow)
+
+	_, err = rate.Convert(math.MinInt64)
+	require.ErrorIs(t, err, ErrAmountOverflow)
+}
+
+func requireConverted(t *testing.T, expected int64, rate Rate, amount int64) {
+	converted, err := rate.Convert(amount)
+	require.NoError(t, err)
+	require.Equal(t, expected, converted)
+}
+
+func TestNewRateInvalid(t *testing.T) {
+	for _, value := range []string{"", "abc", "0", "-1.2"} {
+		_, err := NewRate(utils.USD, utils.EUR, value)
+		require.ErrorIs(t, err, ErrInvalidRate)
+	}
+}
+
+func TestStaticRateProvider(t *testing.T) {
+	provider, err := NewStaticRateProvider(map[string]string{
+		"USD/EUR": "0.8",
+	})
+	require.NoError(t, err)
+
+	rate, err := provider.Rate(context.Background(), utils.USD, utils.EUR)
+	require.NoError(t, err)
+	require.Equal(t, "0.8", rate.String())
+
+	// the inverse is derived from the configured pair
+	rate, err = provider.Rate(context.Background(), utils.EUR, utils.USD)
+	require.NoError(t, err)
+	require.Equal(t, "1.25", rate.String())
+
+	rate, err = provider.Rate(context.Background(), utils.CAD, utils.CAD)
+	require.NoError(t, err)
+	require.Equal(t, "1", rate.String())
+
+	_, err = provider.Rate(context.Background(), utils.USD, utils.CAD)
+	require.ErrorIs(t, err, ErrUnsupportedPair)
+
+	_, err = provider.Rate(context.Background(), utils.USD, "GBP")
+	require.ErrorIs(t, err, ErrUnsupportedPair)
+
+	// disabled currencies are still quoted for the accounts held in them
+	provider, err = NewStaticRateProvider(map[string]string{"USD/JPY": "149.5"})
+	require.NoError(t, err)
+	rate, err = provider.Rate(context.Background(), utils.USD, utils.JPY)
+	require.NoError(t, err)
+	require.Equal(t, "149.5", rate.String())
+}
+
+func TestStaticRateProviderInvalidTable(t *testing.T) {
+	_, err := NewStaticRateProvider(map[string]string{"USDEUR": "0.92"})
+	require.Error(t, err)
+
+	_, err = NewStaticRateProvider(map[string]string{"USD/GBP": "0.79"})
+	require.ErrorIs(t, err, ErrUnsupportedPair)
+
+	_, err = NewStaticRateProvider(map[string]string{"USD/EUR": "zero"})
+	require.ErrorIs(t, err, ErrInvalidRate)
+}
+
+func TestDefaultRates(t *testing.T) {
+	provider, err := NewStaticRateProvider(DefaultRates)
+	require.NoError(t, err)
+
+	// every pair of enabled currencies must have a rate
+	var currencies []string
+	for _, currency := range utils.Currencies.List() {
+		if currency.Enabled {
+			currencies = append(currencies, currency.Code)
+		}
+	}
+	for _, from := range currencies {
+		for _, to := range currencies {
+			_, err := provider.Rate(context.Background(), from, to)
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestFileRateProvider(t *testing.T) {
+	provider, err := NewFileRateProvider(filepath.Join("testdata", "rates.json"))
+	require.NoError(t, err)
+
+	rate, err := provider.Rate(context.Background(), utils.EUR, utils.CAD)
+	require.NoError(t, err)
+	require.Equal(t, "1.48", rate.String())
+
+	path := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"USD/EUR": "0.9"}`), 0o600))
+
+	provider, err = NewFileRateProvider(path)
+	require.NoError(t, err)
+
+	// a broken file keeps the previous table
+	require.NoError(t, os.WriteFile(path, []byte(`not json`), 0o600))
+	require.Error(t, provider.Reload())
+
+	rate, err = provider.Rate(context.Background(), utils.USD, utils.EUR)
+	require.NoError(t, err)
+	require.Equal(t, "0.9", rate.String())
+
+	_, err = NewFileRateProvider(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package fx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+// DefaultRates is used when no rates file is configured
+var DefaultRates = map[string]string{
+	utils.USD + "/" + utils.EUR: "0.92",
+	utils.USD + "/" + utils.CAD: "1.36",
+	utils.EUR + "/" + utils.CAD: "1.48",
+	utils.USD + "/" + utils.JPY: "149.5",
+	utils.USD + "/" + utils.BHD: "0.376",
+}
+
+// StaticRateProvider serves rates from a fixed table, the inverse of every
+// configured pair is derived automatically
+type StaticRateProvider struct {
+	rates map[string]Rate
+}
+
+// NewStaticRateProvider builds a provider from pairs such as {"USD/EUR": "0.92"}
+func NewStaticRateProvider(table map[string]string) (*StaticRateProvider, error) {
+	provider := &StaticRateProvider{rates: make(map[string]Rate)}
+
+	for pair, value := range table {
+		currencies := strings.Split(pair, "/")
+		if len(currencies) != 2 {
+			return nil, fmt.Errorf("invalid currency pair %q: must look like USD/EUR", pair)
+		}
+
+		if !knownPair(currencies[0], currencies[1]) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedPair, pair)
+		}
+
+		rate, err := NewRate(currencies[0], currencies[1], value)
+		if err != nil {
+			return nil, err
+		}
+
+		provider.rates[pairKey(rate.From, rate.To)] = rate
+		inverse := rate.Inverse()
+		if _, ok := table[pairKey(inverse.From, inverse.To)]; !ok {
+			provider.rates[pairKey(inverse.From, inverse.To)] = inverse
+		}
+	}
+	return provider, nil
+}
+
+func (provider *StaticRateProvider) Rate(ctx context.Context, from string, to string) (Rate, error) {
+	if !knownPair(from, to) {
+		return Rate{}, fmt.Errorf("%w: %s/%s", ErrUnsupportedPair, from, to)
+	}
+	if from == to {
+		return identityRate(from), nil
+	}
+
+	rate, ok := provider.rates[pairKey(from, to)]
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: %s/%s", ErrUnsupportedPair, from, to)
+	}
+	return rate, nil
+}
+
+// knownPair reports whether both currencies are registered, disabled ones are
+// still quoted for the accounts opened before they were disabled
+func knownPair(from string, to string) bool {
+	_, fromOk := utils.Currencies.Lookup(from)
+	_, toOk := utils.Currencies.Lookup(to)
+	return fromOk && toOk
+}
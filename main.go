@@ -1,12 +1,25 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "github.com/lib/pq"
 	"github.com/minhdang2803/simple_bank/api"
+	"github.com/minhdang2803/simple_bank/db/memstore"
+	"github.com/minhdang2803/simple_bank/db/migration"
 	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/fx"
+	"github.com/minhdang2803/simple_bank/metrics"
+	"github.com/minhdang2803/simple_bank/scheduler"
 	"github.com/minhdang2803/simple_bank/utils"
 )
 
@@ -16,13 +29,195 @@ func main() {
 		log.Fatal("Cannot load configuration", err)
 	}
 
-	conn, err := sql.Open(config.DBDriver, config.DBSource)
+	// SIGINT/SIGTERM stops the startup retries, the executor and the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	options := db.DefaultStoreOptions()
+	options.TransferIsolation, err = db.ParseIsolationLevel(config.TransferIsolation)
+	if err != nil {
+		log.Fatal("Cannot configure transactions", err)
+	}
+	if config.TxMaxAttempts > 0 {
+		options.Retry.MaxAttempts = config.TxMaxAttempts
+	}
+	store, conn, err := newStore(ctx, *config, options)
 	if err != nil {
 		log.Fatal("Cannot connect to DB", err)
 	}
 
-	store := db.NewStore(conn)
-	server := api.NewServer(store)
+	serverMetrics := metrics.New()
+	store = serverMetrics.Store(store)
+	if conn != nil {
+		if err := serverMetrics.RegisterDB(conn, "simple_bank"); err != nil {
+			log.Fatal("Cannot register DB metrics", err)
+		}
+	}
+
+	// simple_bank migrate up|down|status|force
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(ctx, conn, os.Args[2:]))
+	}
+
+	// simple_bank verify-ledger [-snapshot] [-json]
+	if len(os.Args) > 1 && os.Args[1] == "verify-ledger" {
+		os.Exit(runVerifyLedger(ctx, store, os.Args[2:]))
+	}
+
+	if config.AutoMigrate && conn != nil {
+		if err := autoMigrate(ctx, conn); err != nil {
+			log.Fatal("Cannot migrate DB", err)
+		}
+	}
+
+	if err := api.LoadCurrencies(ctx, *config, store); err != nil {
+		log.Fatal("Cannot load currencies", err)
+	}
 
-	err = server.Start(config.ServerAddress)
+	// the API and the scheduler share the provider to convert at the same rates
+	rates, err := fx.NewRateProvider(config.FXRatesFile)
+	if err != nil {
+		log.Fatal("Cannot create rate provider", err)
+	}
+
+	server, err := api.NewServerWithMetrics(*config, store, rates, serverMetrics)
+	if err != nil {
+		log.Fatal("Cannot create server", err)
+	}
+
+	executor := scheduler.NewExecutor(store, rates, config.SchedulerPollInterval)
+	executor.Start(ctx)
+
+	if conn != nil {
+		server.AddReadinessCheck("database", conn.PingContext)
+	}
+	server.AddReadinessCheck("scheduler", executor.Check)
+	server.SetSchemaStatus(schemaStatus(conn))
+
+	serverErr := make(chan error, 2)
+	go func() {
+		serverErr <- server.Start(config.ServerAddress)
+	}()
+
+	var metricsServer *http.Server
+	if config.MetricsAddress != "" {
+		metricsServer = serverMetrics.Server(config.MetricsAddress)
+		go func() {
+			if err := metricsServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- fmt.Errorf("metrics: %w", err)
+			}
+		}()
+	}
+
+	select {
+	case err = <-serverErr:
+		if err == nil {
+			err = errors.New("server stopped")
+		}
+	case <-ctx.Done():
+		log.Println("Shutting down")
+		err = shutdown(server, metricsServer, config.ShutdownTimeout)
+	}
+	stop()
+	executor.Wait()
+
+	if err != nil {
+		log.Fatal("Cannot serve", err)
+	}
+}
+
+// shutdown lets the requests in flight finish within timeout, metricsServer
+// is nil when the metrics aren't served
+func shutdown(server *api.Server, metricsServer *http.Server, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			log.Printf("cannot shut down metrics server: %v", err)
+		}
+	}
+	return server.Shutdown(ctx)
+}
+
+const (
+	defaultShutdownTimeout  = 15 * time.Second
+	defaultDBConnectTimeout = 30 * time.Second
+	minConnectBackoff       = 100 * time.Millisecond
+	maxConnectBackoff       = 5 * time.Second
+)
+
+// newStore opens the database of DB_DRIVER, memory keeps the data in memory
+// for demos and loses it on exit. The connection is nil in memory.
+func newStore(ctx context.Context, config utils.Config, options db.StoreOptions) (db.Store, *sql.DB, error) {
+	if config.DBDriver == "memory" {
+		return memstore.NewWithOptions(options), nil, nil
+	}
+
+	conn, err := sql.Open(config.DBDriver, config.DBSource)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.DBMaxOpenConns > 0 {
+		conn.SetMaxOpenConns(config.DBMaxOpenConns)
+	}
+	if config.DBMaxIdleConns > 0 {
+		conn.SetMaxIdleConns(config.DBMaxIdleConns)
+	}
+	if config.DBConnMaxLifetime > 0 {
+		conn.SetConnMaxLifetime(config.DBConnMaxLifetime)
+	}
+
+	if err := connect(ctx, conn, config.DBConnectTimeout); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return db.NewStoreWithOptions(conn, options), conn, nil
+}
+
+// connect pings the database until it answers, sql.Open doesn't connect. The
+// delay between attempts doubles up to maxConnectBackoff, so that the server
+// can start along with its database.
+func connect(ctx context.Context, conn *sql.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = defaultDBConnectTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := minConnectBackoff
+	for {
+		err := conn.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		log.Printf("cannot reach DB, retrying in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// schemaStatus reads the schema version of conn, the memory store always
+// has the tables of the latest migration
+func schemaStatus(conn *sql.DB) api.SchemaStatus {
+	if conn == nil {
+		return func(ctx context.Context) (migration.Status, error) {
+			latest := migration.Latest()
+			return migration.Status{Version: latest, Latest: latest}, nil
+		}
+	}
+
+	migrator, err := migration.New(conn)
+	if err != nil {
+		log.Fatal("Cannot load migrations", err)
+	}
+	return migrator.Status
 }
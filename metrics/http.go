@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute is the route label of requests no route matched, so that
+// scanners can't blow up the number of series with made-up paths
+const unmatchedRoute = "unmatched"
+
+// Middleware counts the requests handled by gin and their latency, labelled
+// with the route pattern rather than the path
+func (metrics *Metrics) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(ctx.Writer.Status())
+
+		metrics.httpRequests.WithLabelValues(ctx.Request.Method, route, status).Inc()
+		metrics.httpRequestDuration.WithLabelValues(ctx.Request.Method, route, status).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,125 @@
+// Package metrics exposes Prometheus metrics of the server: HTTP requests,
+// the database connection pool, store transactions and business events.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "simple_bank"
+
+// readHeaderTimeout bounds how long a scraper may take to send the headers
+const readHeaderTimeout = 10 * time.Second
+
+// Reasons of failed logins
+const (
+	LoginUnknownUser   = "unknown_user"
+	LoginWrongPassword = "wrong_password"
+)
+
+// Metrics holds the collectors of a server in a registry of its own, so that
+// several servers, in tests, don't clash
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+
+	txDuration *prometheus.HistogramVec
+
+	transfers       *prometheus.CounterVec
+	transferVolume  *prometheus.CounterVec
+	accountsCreated *prometheus.CounterVec
+	loginFailures   *prometheus.CounterVec
+}
+
+// New returns metrics registered with the Go runtime and process collectors
+func New() *Metrics {
+	metrics := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status code.",
+		}, []string{"method", "route", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		txDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "store_tx_duration_seconds",
+			Help:      "Duration of the store transactions that move money by operation and outcome, retries included.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "outcome"}),
+		transfers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfers_total",
+			Help:      "Transfers booked by source currency.",
+		}, []string{"currency"}),
+		transferVolume: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfer_volume_minor_units_total",
+			Help:      "Amount transferred by source currency, in minor units of the currency.",
+		}, []string{"currency"}),
+		accountsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "accounts_created_total",
+			Help:      "Accounts opened by currency.",
+		}, []string{"currency"}),
+		loginFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "login_failures_total",
+			Help:      "Failed logins by reason.",
+		}, []string{"reason"}),
+	}
+
+	metrics.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metrics.httpRequests,
+		metrics.httpRequestDuration,
+		metrics.txDuration,
+		metrics.transfers,
+		metrics.transferVolume,
+		metrics.accountsCreated,
+		metrics.loginFailures,
+	)
+	return metrics
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (metrics *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{})
+}
+
+// Server serves the metrics on /metrics of address. It listens apart from the
+// API so that the port can be kept off the public network.
+func (metrics *Metrics) Server(address string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	return &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+}
+
+// RegisterDB exports the connection pool stats of conn
+func (metrics *Metrics) RegisterDB(conn *sql.DB, name string) error {
+	return metrics.registry.Register(collectors.NewDBStatsCollector(conn, name))
+}
+
+// LoginFailed counts a failed login, reason is LoginUnknownUser or
+// LoginWrongPassword
+func (metrics *Metrics) LoginFailed(reason string) {
+	metrics.loginFailures.WithLabelValues(reason).Inc()
+}
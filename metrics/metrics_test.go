@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minhdang2803/simple_bank/db/memstore"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func createAccount(t *testing.T, store db.Store, currency string) db.Account {
+	user, err := store.CreateUser(context.Background(), db.CreateUserParams{
+		Username:       utils.RandomOwner(),
+		HashedPassword: utils.RandomString(32),
+		FullName:       utils.RandomOwner(),
+		Email:          utils.RandomEmail(),
+	})
+	require.NoError(t, err)
+
+	account, err := store.CreateAccountTx(context.Background(), db.CreateAccountTxParams{
+		CreateAccountParams: db.CreateAccountParams{Owner: user.Username, Currency: currency},
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func TestStore(t *testing.T) {
+	metrics := New()
+	store := metrics.Store(memstore.New())
+	ctx := context.Background()
+
+	account1 := createAccount(t, store, utils.USD)
+	account2 := createAccount(t, store, utils.USD)
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.accountsCreated.WithLabelValues(utils.USD)))
+
+	_, err := store.AddAccountBalance(ctx, db.AddAccountBalanceParams{ID: account1.ID, Amount: 100})
+	require.NoError(t, err)
+
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 30})
+	require.NoError(t, err)
+	_, err = store.TransferTx(ctx, db.TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 100})
+	require.ErrorIs(t, err, db.ErrInsufficientFunds)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.transfers.WithLabelValues(utils.USD)))
+	require.Equal(t, 30.0, testutil.ToFloat64(metrics.transferVolume.WithLabelValues(utils.USD)))
+	require.Equal(t, 2, testutil.CollectAndCount(metrics.txDuration))
+
+	body := scrape(t, metrics)
+	require.Contains(t, body, `simple_bank_store_tx_duration_seconds_count{operation="transfer",outcome="ok"} 1`)
+	require.Contains(t, body, `simple_bank_store_tx_duration_seconds_count{operation="transfer",outcome="rejected"} 1`)
+	require.Contains(t, body, "simple_bank_store_tx_retries_total 0")
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	metrics := New()
+
+	router := gin.New()
+	router.Use(metrics.Middleware())
+	router.GET("/accounts/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/accounts/1", "/accounts/2", "/unknown"} {
+		request, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	// requests are labelled with the route, not the path
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.httpRequests.WithLabelValues(http.MethodGet, "/accounts/:id", "404")))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.httpRequests.WithLabelValues(http.MethodGet, unmatchedRoute, "404")))
+}
+
+func TestLoginFailed(t *testing.T) {
+	metrics := New()
+	metrics.LoginFailed(LoginWrongPassword)
+	metrics.LoginFailed(LoginWrongPassword)
+
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.loginFailures.WithLabelValues(LoginWrongPassword)))
+	require.Contains(t, scrape(t, metrics), `simple_bank_login_failures_total{reason="wrong_password"} 2`)
+}
+
+func TestServer(t *testing.T) {
+	metrics := New()
+	server := metrics.Server("127.0.0.1:0")
+	require.Equal(t, "127.0.0.1:0", server.Addr)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+	server.Handler.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), "go_goroutines")
+
+	// only the metrics are served on that port
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, "/healthz", nil)
+	require.NoError(t, err)
+	server.Handler.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func scrape(t *testing.T, metrics *Metrics) string {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+
+	metrics.Handler().ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.True(t, strings.Contains(recorder.Body.String(), "go_goroutines"))
+	return recorder.Body.String()
+}
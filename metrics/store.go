@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcomes of the store transactions
+const (
+	outcomeOK = "ok"
+	// outcomeRejected is a transaction refused by a business rule, the
+	// client's doing rather than a fault of the server
+	outcomeRejected = "rejected"
+	outcomeError    = "error"
+)
+
+// Store is a db.Store that records the metrics of the store it wraps. It only
+// looks at arguments and results, so it works with any implementation.
+type Store struct {
+	db.Store
+	metrics *Metrics
+}
+
+var _ db.Store = (*Store)(nil)
+
+// Store wraps store to record its metrics and exports the retry counters
+// of its transactions. It is called at most once per Metrics.
+func (metrics *Metrics) Store(store db.Store) db.Store {
+	metrics.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "store_tx_retries_total",
+			Help:      "Transactions run again after a deadlock or a serialization failure.",
+		}, func() float64 { return float64(store.TxStats().Retries) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "store_tx_retries_exhausted_total",
+			Help:      "Transactions that still failed with a retryable error on their last attempt.",
+		}, func() float64 { return float64(store.TxStats().Exhausted) }),
+	)
+	return &Store{Store: store, metrics: metrics}
+}
+
+// observe records the duration and outcome of operation since start
+func (store *Store) observe(operation string, start time.Time, err error) {
+	store.metrics.txDuration.WithLabelValues(operation, outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+func (store *Store) countTransfer(transfer db.Transfer) {
+	store.metrics.transfers.WithLabelValues(transfer.FromCurrency).Inc()
+	store.metrics.transferVolume.WithLabelValues(transfer.FromCurrency).Add(float64(transfer.Amount))
+}
+
+func (store *Store) TransferTx(ctx context.Context, arg db.TransferTxParams) (db.TransferTxResult, error) {
+	start := time.Now()
+	result, err := store.Store.TransferTx(ctx, arg)
+	store.observe("transfer", start, err)
+	if err == nil {
+		store.countTransfer(result.Transfer)
+	}
+	return result, err
+}
+
+func (store *Store) ReverseTransferTx(ctx context.Context, arg db.ReverseTransferTxParams) (db.TransferTxResult, error) {
+	start := time.Now()
+	result, err := store.Store.ReverseTransferTx(ctx, arg)
+	store.observe("reverse_transfer", start, err)
+	if err == nil {
+		store.countTransfer(result.Transfer)
+	}
+	return result, err
+}
+
+func (store *Store) TransferBatchTx(ctx context.Context, arg db.TransferBatchTxParams) (db.TransferBatchTxResult, error) {
+	start := time.Now()
+	result, err := store.Store.TransferBatchTx(ctx, arg)
+	store.observe("transfer_batch", start, err)
+	if err == nil {
+		for _, item := range result.Items {
+			if item.Status == db.TransferBatchItemCompleted {
+				store.metrics.transfers.WithLabelValues(item.Currency).Inc()
+				store.metrics.transferVolume.WithLabelValues(item.Currency).Add(float64(item.Amount))
+			}
+		}
+	}
+	return result, err
+}
+
+func (store *Store) CaptureHold(ctx context.Context, arg db.CaptureHoldParams) (db.CaptureHoldResult, error) {
+	start := time.Now()
+	result, err := store.Store.CaptureHold(ctx, arg)
+	store.observe("capture_hold", start, err)
+	if err == nil {
+		store.countTransfer(result.Transfer)
+	}
+	return result, err
+}
+
+func (store *Store) CreateAccount(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
+	account, err := store.Store.CreateAccount(ctx, arg)
+	if err == nil {
+		store.metrics.accountsCreated.WithLabelValues(account.Currency).Inc()
+	}
+	return account, err
+}
+
+func (store *Store) CreateAccountTx(ctx context.Context, arg db.CreateAccountTxParams) (db.Account, error) {
+	account, err := store.Store.CreateAccountTx(ctx, arg)
+	if err == nil {
+		store.metrics.accountsCreated.WithLabelValues(account.Currency).Inc()
+	}
+	return account, err
+}
+
+// outcome classifies err for the outcome label
+func outcome(err error) string {
+	if err == nil {
+		return outcomeOK
+	}
+
+	err = db.TranslateError(err)
+	switch {
+	case db.IsIdempotencyConflict(err),
+		errors.Is(err, db.ErrRecordNotFound),
+		errors.Is(err, db.ErrForeignKeyViolation),
+		errors.Is(err, db.ErrCheckViolation),
+		errors.Is(err, db.ErrInsufficientFunds),
+		errors.Is(err, db.ErrReversalExceedsTransfer),
+		errors.Is(err, db.ErrReversalOfReversal),
+		errors.Is(err, db.ErrHoldNotActive),
+		errors.Is(err, db.ErrCaptureExceedsHold),
+		errors.Is(err, db.ErrAccountFrozen),
+		errors.Is(err, db.ErrAccountClosed):
+		return outcomeRejected
+	}
+	return outcomeError
+}
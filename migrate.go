@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/minhdang2803/simple_bank/db/migration"
+)
+
+// Exit codes of the migrate subcommand
+const (
+	exitMigrateOK    = 0
+	exitMigrateError = 1
+	exitMigrateUsage = 2
+)
+
+const migrateUsage = "usage: simple_bank migrate up | down [-all] [N] | status | force VERSION"
+
+// runMigrate runs the migrate subcommand and returns its exit code
+func runMigrate(ctx context.Context, conn *sql.DB, args []string) int {
+	if len(args) == 0 {
+		log.Println(migrateUsage)
+		return exitMigrateUsage
+	}
+	if conn == nil {
+		log.Println("cannot migrate: DB_DRIVER has no schema")
+		return exitMigrateUsage
+	}
+
+	migrator, err := migration.New(conn)
+	if err != nil {
+		log.Printf("cannot load migrations: %v", err)
+		return exitMigrateError
+	}
+
+	switch args[0] {
+	case "up":
+		var applied []int64
+		applied, err = migrator.Up(ctx)
+		printVersions(os.Stdout, "applied", applied)
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		all := flags.Bool("all", false, "revert every migration")
+		flags.Parse(args[1:])
+
+		steps := 1
+		if *all {
+			steps = -1
+		} else if flags.NArg() > 0 {
+			steps, err = strconv.Atoi(flags.Arg(0))
+			if err != nil || steps <= 0 {
+				log.Println(migrateUsage)
+				return exitMigrateUsage
+			}
+		}
+
+		var reverted []int64
+		reverted, err = migrator.Down(ctx, steps)
+		printVersions(os.Stdout, "reverted", reverted)
+	case "status":
+		// printed below, as after every command
+	case "force":
+		if len(args) != 2 {
+			log.Println(migrateUsage)
+			return exitMigrateUsage
+		}
+		version, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil {
+			log.Println(migrateUsage)
+			return exitMigrateUsage
+		}
+		err = migrator.Force(ctx, version)
+	default:
+		log.Println(migrateUsage)
+		return exitMigrateUsage
+	}
+	if err != nil {
+		log.Printf("cannot migrate: %v", err)
+		return exitMigrateError
+	}
+
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		log.Print(err)
+		return exitMigrateError
+	}
+	printMigrationStatus(os.Stdout, status)
+	return exitMigrateOK
+}
+
+// autoMigrate applies the pending migrations when the server starts
+func autoMigrate(ctx context.Context, conn *sql.DB) error {
+	migrator, err := migration.New(conn)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(ctx)
+	for _, version := range applied {
+		log.Printf("applied migration %d", version)
+	}
+	return err
+}
+
+func printVersions(w io.Writer, action string, versions []int64) {
+	for _, version := range versions {
+		fmt.Fprintf(w, "%s migration %d\n", action, version)
+	}
+}
+
+func printMigrationStatus(w io.Writer, status migration.Status) {
+	fmt.Fprintf(w, "schema version %d of %d", status.Version, status.Latest)
+	if status.Dirty {
+		fmt.Fprint(w, " (dirty)")
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,213 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLookahead bounds the search for the next occurrence, a schedule such
+// as "0 0 30 2 *" never matches
+const maxLookahead = 5 * 366 * 24 * time.Hour
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// Schedule is a cron expression evaluated in UTC: minute, hour, day of month,
+// month and day of week, or one of the @yearly, @monthly, @weekly, @daily and
+// @hourly shortcuts. Fields take *, lists, ranges and steps such as "1-5",
+// "*/15" or "mon,wed,fri".
+//
+// A day of month missing from a month, the 31st in April, is skipped like in
+// cron. L stands for the last day of the month for orders that must run at
+// the end of every month. When both the day of month and the day of week are
+// restricted an occurrence matches either of them.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// lastDom is set when the day of month includes L
+	lastDom bool
+	// domAny and dowAny are set for a * day field
+	domAny, dowAny bool
+}
+
+// ParseSchedule parses a cron expression, see Schedule
+func ParseSchedule(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q must have 5 fields, got %d", spec, len(fields))
+	}
+
+	var schedule Schedule
+	var err error
+	if schedule.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if schedule.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+
+	dom := fields[2]
+	var items []string
+	for _, item := range strings.Split(dom, ",") {
+		if strings.EqualFold(item, "L") {
+			schedule.lastDom = true
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) > 0 {
+		if schedule.dom, err = parseField(strings.Join(items, ","), 1, 31, nil); err != nil {
+			return nil, fmt.Errorf("day of month: %w", err)
+		}
+	}
+	schedule.domAny = dom == "*"
+
+	if schedule.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	// 7 is sunday as well
+	if schedule.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	schedule.dowAny = fields[4] == "*"
+
+	return &schedule, nil
+}
+
+func parseField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		if item == "" {
+			return 0, fmt.Errorf("empty item in %q", field)
+		}
+
+		expr, step := item, 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			var err error
+			expr = item[:i]
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+		}
+
+		var lo, hi int
+		switch {
+		case expr == "*":
+			lo, hi = min, max
+		case strings.Contains(expr, "-"):
+			bounds := strings.SplitN(expr, "-", 2)
+			var err error
+			if lo, err = parseValue(bounds[0], min, max, names); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(bounds[1], min, max, names); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", expr)
+			}
+		default:
+			var err error
+			if lo, err = parseValue(expr, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			// "5/10" means every 10 starting at 5
+			if step > 1 {
+				hi = max
+			}
+		}
+
+		for value := lo; value <= hi; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(value string, min, max int, names map[string]int) (int, error) {
+	if n, ok := names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", n, min, max)
+	}
+	return n, nil
+}
+
+// Next returns the first occurrence strictly after the given time, or the
+// zero time when the schedule doesn't match anything in the next five years
+func (schedule *Schedule) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxLookahead)
+
+	for t.Before(limit) {
+		if schedule.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !schedule.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if schedule.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if schedule.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (schedule *Schedule) matchDay(t time.Time) bool {
+	dom := schedule.dom&(1<<uint(t.Day())) != 0
+	if schedule.lastDom && t.AddDate(0, 0, 1).Day() == 1 {
+		dom = true
+	}
+	dow := schedule.dow&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case schedule.domAny && schedule.dowAny:
+		return true
+	case schedule.domAny:
+		return dow
+	case schedule.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+}
+
+func TestScheduleNext(t *testing.T) {
+	testCases := []struct {
+		name  string
+		spec  string
+		after time.Time
+		next  time.Time
+	}{
+		{"Weekly", "0 9 * * 1", date(2024, 3, 1, 12, 0), date(2024, 3, 4, 9, 0)},
+		{"Day names", "30 8 * * mon-fri", date(2024, 3, 2, 0, 0), date(2024, 3, 4, 8, 30)},
+		{"Sunday as 7", "0 12 * * 7", date(2024, 3, 4, 0, 0), date(2024, 3, 10, 12, 0)},
+		{"Every 15 minutes", "*/15 * * * *", date(2024, 3, 1, 10, 7), date(2024, 3, 1, 10, 15)},
+		{"Strictly after", "0 9 1,15 * *", date(2024, 3, 1, 9, 0), date(2024, 3, 15, 9, 0)},
+		{"Monthly shortcut", "@monthly", date(2024, 1, 31, 23, 59), date(2024, 2, 1, 0, 0)},
+		{"Last day of a leap february", "0 9 L * *", date(2024, 2, 10, 0, 0), date(2024, 2, 29, 9, 0)},
+		{"Last day of february", "0 9 L * *", date(2023, 2, 10, 0, 0), date(2023, 2, 28, 9, 0)},
+		{"Last day of a 30 day month", "0 9 L * *", date(2024, 4, 30, 10, 0), date(2024, 5, 31, 9, 0)},
+		{"31st skips short months", "0 0 31 * *", date(2024, 4, 1, 0, 0), date(2024, 5, 31, 0, 0)},
+		{"Day of month or day of week", "0 0 1 * mon", date(2024, 4, 1, 0, 0), date(2024, 4, 8, 0, 0)},
+		{"Month names", "0 0 1 jan,jul *", date(2024, 2, 1, 0, 0), date(2024, 7, 1, 0, 0)},
+		{"Year boundary", "@yearly", date(2024, 6, 1, 0, 0), date(2025, 1, 1, 0, 0)},
+		{"Never", "0 0 30 2 *", date(2024, 1, 1, 0, 0), time.Time{}},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tc.spec)
+			require.NoError(t, err)
+			require.Equal(t, tc.next, schedule.Next(tc.after))
+		})
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"61 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+		"@fortnightly",
+	} {
+		_, err := ParseSchedule(spec)
+		require.Error(t, err, spec)
+	}
+}
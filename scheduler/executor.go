@@ -0,0 +1,312 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/fx"
+	"github.com/minhdang2803/simple_bank/utils"
+)
+
+const (
+	defaultPollInterval = 10 * time.Second
+	// batchSize is how many due transfers a single poll claims
+	batchSize = 10
+	// lease is how long a claimed transfer stays hidden from other executors,
+	// a row still running after that is considered abandoned and picked up again
+	lease = 5 * time.Minute
+	// executeTimeout bounds a single transfer, it isn't tied to the poll
+	// context so that shutting down lets the transfer in flight finish
+	executeTimeout = 30 * time.Second
+
+	// MaxAttempts is how many times a transfer is tried before it is marked failed
+	MaxAttempts = 5
+	minBackoff  = time.Minute
+	maxBackoff  = time.Hour
+
+	// scheduledTransferPath and standingOrderPath are recorded with the
+	// idempotency key of every transfer the executor books
+	scheduledTransferPath = "/scheduled-transfers/:id/execute"
+	standingOrderPath     = "/standing-orders/:id/execute"
+)
+
+// errPermanent marks failures that retrying can't fix
+var errPermanent = errors.New("permanent failure")
+
+// Executor books scheduled transfers and the occurrences of standing orders
+// once they are due. Several executors can poll the same database, due rows
+// are claimed with FOR UPDATE SKIP LOCKED and every execution is recorded
+// under an idempotency key so that a transfer is never booked twice. It also
+// closes the holds past their expiry.
+type Executor struct {
+	store        db.Store
+	rates        fx.RateProvider
+	pollInterval time.Duration
+	now          func() time.Time
+	done         chan struct{}
+
+	// mu guards the state reported by Check
+	mu      sync.Mutex
+	running bool
+	pollErr error
+}
+
+func NewExecutor(store db.Store, rates fx.RateProvider, pollInterval time.Duration) *Executor {
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	return &Executor{
+		store:        store,
+		rates:        rates,
+		pollInterval: pollInterval,
+		now:          time.Now,
+		done:         make(chan struct{}),
+	}
+}
+
+// Start polls for due transfers in the background until ctx is cancelled
+func (executor *Executor) Start(ctx context.Context) {
+	executor.setRunning(true)
+	go func() {
+		defer close(executor.done)
+		defer executor.setRunning(false)
+
+		ticker := time.NewTicker(executor.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			_, err := executor.RunOnce(ctx)
+			if ctx.Err() != nil {
+				err = nil
+			}
+			if err != nil {
+				log.Printf("cannot execute scheduled transfers: %v", err)
+			}
+			executor.setPollErr(err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait blocks until the executor started by Start has stopped
+func (executor *Executor) Wait() {
+	<-executor.done
+}
+
+// Check reports whether the executor is polling, it fails when the executor
+// isn't running or its last poll failed
+func (executor *Executor) Check(ctx context.Context) error {
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+
+	if !executor.running {
+		return errors.New("executor is not running")
+	}
+	if executor.pollErr != nil {
+		return fmt.Errorf("last poll failed: %w", executor.pollErr)
+	}
+	return nil
+}
+
+func (executor *Executor) setRunning(running bool) {
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+	executor.running = running
+}
+
+func (executor *Executor) setPollErr(err error) {
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+	executor.pollErr = err
+}
+
+// RunOnce claims one batch of due scheduled transfers and one of due standing
+// orders and executes them, it returns how many were claimed. Stale holds are
+// expired along the way. It stops early, leaving the rest to a later poll,
+// when ctx is cancelled.
+func (executor *Executor) RunOnce(ctx context.Context) (int, error) {
+	transfers, err := executor.runScheduledTransfers(ctx)
+	orders, ordersErr := executor.runStandingOrders(ctx)
+	if err == nil {
+		err = ordersErr
+	}
+	if holdsErr := executor.expireHolds(ctx); err == nil {
+		err = holdsErr
+	}
+	return transfers + orders, err
+}
+
+func (executor *Executor) runScheduledTransfers(ctx context.Context) (int, error) {
+	claimed, err := executor.store.ClaimDueScheduledTransfers(ctx, db.ClaimDueScheduledTransfersParams{
+		LeaseSeconds: int32(lease / time.Second),
+		Limit:        batchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, scheduled := range claimed {
+		if ctx.Err() != nil {
+			break
+		}
+		executeCtx, cancel := context.WithTimeout(context.Background(), executeTimeout)
+		executor.execute(executeCtx, scheduled)
+		cancel()
+	}
+	return len(claimed), nil
+}
+
+// execute books a claimed transfer and records the outcome
+func (executor *Executor) execute(ctx context.Context, scheduled db.ScheduledTransfer) {
+	result, err := executor.transfer(ctx, payment{
+		fromAccountID: scheduled.FromAccountID,
+		toAccountID:   scheduled.ToAccountID,
+		amount:        scheduled.Amount,
+		currency:      scheduled.Currency,
+		idempotency: db.IdempotencyParams{
+			Username:    scheduled.Owner,
+			Key:         fmt.Sprintf("%sscheduled-transfer-%d", db.SystemIdempotencyKeyPrefix, scheduled.ID),
+			RequestPath: scheduledTransferPath,
+			RequestHash: strconv.FormatInt(scheduled.ID, 10),
+		},
+	})
+	if err == nil {
+		_, err = executor.store.CompleteScheduledTransfer(ctx, db.CompleteScheduledTransferParams{
+			ID:         scheduled.ID,
+			TransferID: nullInt64(result.Transfer.ID),
+		})
+		if err != nil {
+			// the lease runs out and the next attempt finds the idempotency key
+			log.Printf("cannot complete scheduled transfer %d: %v", scheduled.ID, err)
+		}
+		return
+	}
+
+	arg := db.FailScheduledTransferParams{
+		ID:            scheduled.ID,
+		Status:        db.ScheduledTransferPending,
+		LastError:     err.Error(),
+		NextAttemptAt: executor.now().Add(Backoff(scheduled.Attempts)),
+	}
+	if scheduled.Attempts >= MaxAttempts || isPermanent(err) {
+		arg.Status = db.ScheduledTransferFailed
+	}
+	if _, err := executor.store.FailScheduledTransfer(ctx, arg); err != nil {
+		log.Printf("cannot record failure of scheduled transfer %d: %v", scheduled.ID, err)
+	}
+}
+
+// payment is what the executor books, a scheduled transfer or an occurrence
+// of a standing order
+type payment struct {
+	fromAccountID int64
+	toAccountID   int64
+	amount        int64
+	currency      string
+	idempotency   db.IdempotencyParams
+}
+
+func (executor *Executor) transfer(ctx context.Context, payment payment) (db.TransferTxResult, error) {
+	fromAccount, err := executor.store.GetAccount(ctx, payment.fromAccountID)
+	if err != nil {
+		return db.TransferTxResult{}, err
+	}
+	if fromAccount.Currency != payment.currency {
+		return db.TransferTxResult{}, fmt.Errorf("%w: account [%d] currency mismatch %s vs %s",
+			errPermanent, fromAccount.ID, fromAccount.Currency, payment.currency)
+	}
+
+	toAccount, err := executor.store.GetAccount(ctx, payment.toAccountID)
+	if err != nil {
+		return db.TransferTxResult{}, err
+	}
+
+	arg := db.TransferTxParams{
+		FromAccountID: payment.fromAccountID,
+		ToAccountID:   payment.toAccountID,
+		Amount:        payment.amount,
+		Idempotency:   &payment.idempotency,
+	}
+
+	// cross-currency transfers are converted at the rate of the day they run
+	if toAccount.Currency != fromAccount.Currency {
+		rate, err := executor.rates.Rate(ctx, fromAccount.Currency, toAccount.Currency)
+		if err != nil {
+			return db.TransferTxResult{}, err
+		}
+		arg.ToAmount, err = rate.Convert(payment.amount)
+		if err != nil {
+			return db.TransferTxResult{}, err
+		}
+		arg.ExchangeRate = rate.String()
+		if arg.ToAmount <= 0 {
+			return db.TransferTxResult{}, fmt.Errorf("%w: amount is too small to be converted", errPermanent)
+		}
+	}
+
+	result, err := executor.store.TransferTx(ctx, arg)
+	if db.IsIdempotencyConflict(err) {
+		// an earlier attempt booked the transfer but couldn't record it
+		return executor.savedResult(ctx, payment.idempotency)
+	}
+	return result, err
+}
+
+func (executor *Executor) savedResult(ctx context.Context, idempotency db.IdempotencyParams) (db.TransferTxResult, error) {
+	var result db.TransferTxResult
+
+	saved, err := executor.store.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		Username: idempotency.Username,
+		Key:      idempotency.Key,
+	})
+	if err != nil {
+		return result, err
+	}
+	if saved.RequestPath != idempotency.RequestPath || saved.RequestHash != idempotency.RequestHash {
+		// the key was taken by another request, retrying won't free it
+		return result, fmt.Errorf("%w: idempotency key %q was used by another request", errPermanent, idempotency.Key)
+	}
+
+	err = json.Unmarshal(saved.ResponseBody, &result)
+	return result, err
+}
+
+// Backoff is the delay before retrying a transfer that failed attempts times,
+// it doubles from a minute up to an hour
+func Backoff(attempts int32) time.Duration {
+	backoff := minBackoff
+	for i := int32(1); i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+func isPermanent(err error) bool {
+	return errors.Is(err, errPermanent) ||
+		errors.Is(err, db.ErrRecordNotFound) ||
+		errors.Is(err, db.ErrForeignKeyViolation) ||
+		errors.Is(err, db.ErrAccountClosed) ||
+		errors.Is(err, fx.ErrUnsupportedPair) ||
+		errors.Is(err, fx.ErrAmountOverflow) ||
+		errors.Is(err, utils.ErrUnknownCurrency)
+}
+
+func nullInt64(value int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: value, Valid: true}
+}
@@ -0,0 +1,300 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/fx"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func randomAccount(currency string) db.Account {
+	return db.Account{
+		ID:       utils.RandomInt(1, 1000),
+		Owner:    utils.RandomOwner(),
+		Balance:  utils.RandomMoney(),
+		Currency: currency,
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	account1 := randomAccount(utils.USD)
+	account2 := randomAccount(utils.USD)
+	account3 := randomAccount(utils.EUR)
+
+	scheduled := db.ScheduledTransfer{
+		ID:            utils.RandomInt(1, 1000),
+		Owner:         account1.Owner,
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+		Currency:      utils.USD,
+		Status:        db.ScheduledTransferRunning,
+		Attempts:      1,
+	}
+	idempotency := &db.IdempotencyParams{
+		Username:    account1.Owner,
+		Key:         fmt.Sprintf("%sscheduled-transfer-%d", db.SystemIdempotencyKeyPrefix, scheduled.ID),
+		RequestPath: scheduledTransferPath,
+		RequestHash: fmt.Sprint(scheduled.ID),
+	}
+	result := db.TransferTxResult{Transfer: db.Transfer{ID: utils.RandomInt(1, 1000)}}
+
+	testCases := []struct {
+		name       string
+		scheduled  db.ScheduledTransfer
+		buildStubs func(store *mockdb.MockStore, scheduled db.ScheduledTransfer)
+	}{
+		{
+			name:      "Completed",
+			scheduled: scheduled,
+			buildStubs: func(store *mockdb.MockStore, scheduled db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Eq(db.TransferTxParams{
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        100,
+					Idempotency:   idempotency,
+				})).Times(1).Return(result, nil)
+				store.EXPECT().CompleteScheduledTransfer(gomock.Any(), gomock.Eq(db.CompleteScheduledTransferParams{
+					ID:         scheduled.ID,
+					TransferID: sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+				})).Times(1)
+			},
+		},
+		{
+			name: "Converted at the rate of the day",
+			scheduled: func() db.ScheduledTransfer {
+				s := scheduled
+				s.ToAccountID = account3.ID
+				return s
+			}(),
+			buildStubs: func(store *mockdb.MockStore, scheduled db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account3.ID)).Times(1).Return(account3, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Eq(db.TransferTxParams{
+					FromAccountID: account1.ID,
+					ToAccountID:   account3.ID,
+					Amount:        100,
+					ToAmount:      92,
+					ExchangeRate:  "0.92",
+					Idempotency:   idempotency,
+				})).Times(1).Return(result, nil)
+				store.EXPECT().CompleteScheduledTransfer(gomock.Any(), gomock.Any()).Times(1)
+			},
+		},
+		{
+			name:      "Booked by an earlier attempt",
+			scheduled: scheduled,
+			buildStubs: func(store *mockdb.MockStore, scheduled db.ScheduledTransfer) {
+				body, err := json.Marshal(result)
+				require.NoError(t, err)
+
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(2).Return(account1, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, &pq.Error{
+					Code:       "23505",
+					Constraint: "idempotency_keys_pkey",
+				})
+				store.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Eq(db.GetIdempotencyKeyParams{
+					Username: idempotency.Username,
+					Key:      idempotency.Key,
+				})).Times(1).Return(db.IdempotencyKey{
+					RequestPath:  idempotency.RequestPath,
+					RequestHash:  idempotency.RequestHash,
+					ResponseBody: body,
+				}, nil)
+				store.EXPECT().CompleteScheduledTransfer(gomock.Any(), gomock.Eq(db.CompleteScheduledTransferParams{
+					ID:         scheduled.ID,
+					TransferID: sql.NullInt64{Int64: result.Transfer.ID, Valid: true},
+				})).Times(1)
+			},
+		},
+		{
+			name:      "Key used by another request",
+			scheduled: scheduled,
+			buildStubs: func(store *mockdb.MockStore, scheduled db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(2).Return(account1, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, &pq.Error{
+					Code:       "23505",
+					Constraint: "idempotency_keys_pkey",
+				})
+				store.EXPECT().GetIdempotencyKey(gomock.Any(), gomock.Any()).Times(1).Return(db.IdempotencyKey{
+					RequestPath:  "/transfers",
+					RequestHash:  "0123456789abcdef",
+					ResponseBody: []byte(`{"transfer":{"id":1}}`),
+				}, nil)
+				store.EXPECT().CompleteScheduledTransfer(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().FailScheduledTransfer(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, arg db.FailScheduledTransferParams) (db.ScheduledTransfer, error) {
+						require.Equal(t, db.ScheduledTransferFailed, arg.Status)
+						return db.ScheduledTransfer{}, nil
+					})
+			},
+		},
+		{
+			name:      "Retried with backoff",
+			scheduled: scheduled,
+			buildStubs: func(store *mockdb.MockStore, scheduled db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(2).Return(account1, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+				store.EXPECT().CompleteScheduledTransfer(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().FailScheduledTransfer(gomock.Any(), gomock.Eq(db.FailScheduledTransferParams{
+					ID:            scheduled.ID,
+					Status:        db.ScheduledTransferPending,
+					LastError:     db.ErrInsufficientFunds.Error(),
+					NextAttemptAt: now.Add(time.Minute),
+				})).Times(1)
+			},
+		},
+		{
+			name: "Out of attempts",
+			scheduled: func() db.ScheduledTransfer {
+				s := scheduled
+				s.Attempts = MaxAttempts
+				return s
+			}(),
+			buildStubs: func(store *mockdb.MockStore, scheduled db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(2).Return(account1, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+				store.EXPECT().FailScheduledTransfer(gomock.Any(), gomock.Eq(db.FailScheduledTransferParams{
+					ID:            scheduled.ID,
+					Status:        db.ScheduledTransferFailed,
+					LastError:     db.ErrInsufficientFunds.Error(),
+					NextAttemptAt: now.Add(Backoff(MaxAttempts)),
+				})).Times(1)
+			},
+		},
+		{
+			name:      "Account closed",
+			scheduled: scheduled,
+			buildStubs: func(store *mockdb.MockStore, scheduled db.ScheduledTransfer) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().FailScheduledTransfer(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, arg db.FailScheduledTransferParams) (db.ScheduledTransfer, error) {
+						require.Equal(t, db.ScheduledTransferFailed, arg.Status)
+						return db.ScheduledTransfer{}, nil
+					})
+			},
+		},
+	}
+
+	rates, err := fx.NewStaticRateProvider(fx.DefaultRates)
+	require.NoError(t, err)
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			store.EXPECT().ClaimDueScheduledTransfers(gomock.Any(), gomock.Eq(db.ClaimDueScheduledTransfersParams{
+				LeaseSeconds: int32(lease / time.Second),
+				Limit:        batchSize,
+			})).Times(1).Return([]db.ScheduledTransfer{tc.scheduled}, nil)
+			store.EXPECT().ClaimDueStandingOrders(gomock.Any(), gomock.Any()).Times(1).Return([]db.StandingOrder{}, nil)
+			store.EXPECT().ExpireHolds(gomock.Any(), gomock.Any()).Times(1).Return([]db.Hold{}, nil)
+			tc.buildStubs(store, tc.scheduled)
+
+			executor := NewExecutor(store, rates, time.Second)
+			executor.now = func() time.Time { return now }
+
+			claimed, err := executor.RunOnce(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, 1, claimed)
+		})
+	}
+}
+
+func TestStartStopsWithContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().ClaimDueScheduledTransfers(gomock.Any(), gomock.Any()).MinTimes(1).Return([]db.ScheduledTransfer{}, nil)
+	store.EXPECT().ClaimDueStandingOrders(gomock.Any(), gomock.Any()).MinTimes(1).Return([]db.StandingOrder{}, nil)
+	store.EXPECT().ExpireHolds(gomock.Any(), gomock.Any()).MinTimes(1).Return([]db.Hold{}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	executor := NewExecutor(store, nil, time.Millisecond)
+	executor.Start(ctx)
+
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		executor.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("executor didn't stop")
+	}
+}
+
+func TestRunOnceExpiresHolds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().ClaimDueScheduledTransfers(gomock.Any(), gomock.Any()).Times(1).Return([]db.ScheduledTransfer{}, nil)
+	store.EXPECT().ClaimDueStandingOrders(gomock.Any(), gomock.Any()).Times(1).Return([]db.StandingOrder{}, nil)
+	store.EXPECT().ExpireHolds(gomock.Any(), gomock.Eq(int32(expireBatch))).Times(1).Return(nil, errors.New("connection refused"))
+
+	executor := NewExecutor(store, nil, time.Second)
+	claimed, err := executor.RunOnce(context.Background())
+	require.Error(t, err)
+	require.Zero(t, claimed)
+}
+
+func TestBackoff(t *testing.T) {
+	require.Equal(t, time.Minute, Backoff(0))
+	require.Equal(t, time.Minute, Backoff(1))
+	require.Equal(t, 2*time.Minute, Backoff(2))
+	require.Equal(t, 16*time.Minute, Backoff(5))
+	require.Equal(t, time.Hour, Backoff(20))
+}
+
+func TestCheck(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+
+	polled := make(chan struct{}, 1)
+	store.EXPECT().ClaimDueScheduledTransfers(gomock.Any(), gomock.Any()).Times(1).Return(nil, errors.New("connection refused"))
+	store.EXPECT().ClaimDueStandingOrders(gomock.Any(), gomock.Any()).Times(1).Return([]db.StandingOrder{}, nil)
+	store.EXPECT().ExpireHolds(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(func(ctx context.Context, limit int32) ([]db.Hold, error) {
+		polled <- struct{}{}
+		return []db.Hold{}, nil
+	})
+
+	executor := NewExecutor(store, nil, time.Hour)
+	require.EqualError(t, executor.Check(context.Background()), "executor is not running")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	executor.Start(ctx)
+	<-polled
+	require.Eventually(t, func() bool {
+		return executor.Check(context.Background()) != nil
+	}, time.Second, time.Millisecond)
+	require.ErrorContains(t, executor.Check(context.Background()), "connection refused")
+
+	cancel()
+	executor.Wait()
+	require.EqualError(t, executor.Check(context.Background()), "executor is not running")
+}
@@ -0,0 +1,13 @@
+package scheduler
+
+import "context"
+
+// expireBatch is how many stale holds a single poll releases
+const expireBatch = 100
+
+// expireHolds marks the active holds past their expiry as expired. They
+// already stopped reserving funds, this closes them for good.
+func (executor *Executor) expireHolds(ctx context.Context) error {
+	_, err := executor.store.ExpireHolds(ctx, expireBatch)
+	return err
+}
@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+const (
+	// maxCatchUp bounds the occurrences of one order handled per poll, an
+	// order further behind is caught up by the following polls
+	maxCatchUp = 31
+	// lateAfter is how late an occurrence can still be booked under the
+	// skip catch-up policy
+	lateAfter = time.Hour
+)
+
+func (executor *Executor) runStandingOrders(ctx context.Context) (int, error) {
+	claimed, err := executor.store.ClaimDueStandingOrders(ctx, db.ClaimDueStandingOrdersParams{
+		LeaseSeconds: int32(lease / time.Second),
+		Limit:        batchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, order := range claimed {
+		if ctx.Err() != nil {
+			break
+		}
+		executeCtx, cancel := context.WithTimeout(context.Background(), executeTimeout)
+		executor.runStandingOrder(executeCtx, order)
+		cancel()
+	}
+	return len(claimed), nil
+}
+
+// runStandingOrder handles the occurrences of a claimed order that came due,
+// each of them is recorded as a run. A failed transfer is recorded and the
+// order moves on, other errors leave the occurrence to a later poll.
+func (executor *Executor) runStandingOrder(ctx context.Context, order db.StandingOrder) {
+	arg := db.AdvanceStandingOrderParams{
+		ID:          order.ID,
+		NextRunAt:   order.NextRunAt,
+		Occurrences: order.Occurrences,
+		Status:      db.StandingOrderActive,
+	}
+
+	schedule, err := ParseSchedule(order.Schedule)
+	if err != nil {
+		// schedules are validated when the order is created
+		log.Printf("cannot parse schedule of standing order %d: %v", order.ID, err)
+		arg.Status = db.StandingOrderCancelled
+		executor.advance(ctx, arg)
+		return
+	}
+
+	now := executor.now()
+	for i := 0; i < maxCatchUp && !arg.NextRunAt.After(now); i++ {
+		if finished(order, arg.NextRunAt, arg.Occurrences) {
+			break
+		}
+		following := schedule.Next(arg.NextRunAt)
+
+		run := db.CreateStandingOrderRunParams{
+			StandingOrderID: order.ID,
+			ScheduledFor:    arg.NextRunAt,
+			Status:          db.StandingOrderRunSkipped,
+		}
+		if !skipped(order, arg.NextRunAt, following, arg.Occurrences, now) {
+			result, err := executor.transfer(ctx, payment{
+				fromAccountID: order.FromAccountID,
+				toAccountID:   order.ToAccountID,
+				amount:        order.Amount,
+				currency:      order.Currency,
+				idempotency: db.IdempotencyParams{
+					Username:    order.Owner,
+					Key:         fmt.Sprintf("%sstanding-order-%d-%d", db.SystemIdempotencyKeyPrefix, order.ID, arg.NextRunAt.Unix()),
+					RequestPath: standingOrderPath,
+					RequestHash: fmt.Sprintf("%d-%d", order.ID, arg.NextRunAt.Unix()),
+				},
+			})
+			switch {
+			case err == nil:
+				run.Status = db.StandingOrderRunCompleted
+				run.TransferID = nullInt64(result.Transfer.ID)
+			case errors.Is(err, db.ErrInsufficientFunds) || errors.Is(err, db.ErrAccountFrozen) || isPermanent(err):
+				run.Status = db.StandingOrderRunFailed
+				run.Error = err.Error()
+			default:
+				log.Printf("cannot run standing order %d: %v", order.ID, err)
+				arg.LockedUntil = sql.NullTime{Time: now.Add(minBackoff), Valid: true}
+				executor.advance(ctx, arg)
+				return
+			}
+		}
+
+		if err := executor.store.CreateStandingOrderRun(ctx, run); err != nil {
+			// the transfer is idempotent, the occurrence is run again later
+			log.Printf("cannot record run of standing order %d: %v", order.ID, err)
+			arg.LockedUntil = sql.NullTime{Time: now.Add(minBackoff), Valid: true}
+			executor.advance(ctx, arg)
+			return
+		}
+		arg.Occurrences++
+		arg.NextRunAt = following
+	}
+
+	if finished(order, arg.NextRunAt, arg.Occurrences) {
+		arg.Status = db.StandingOrderCompleted
+	}
+	executor.advance(ctx, arg)
+}
+
+func (executor *Executor) advance(ctx context.Context, arg db.AdvanceStandingOrderParams) {
+	if _, err := executor.store.AdvanceStandingOrder(ctx, arg); err != nil {
+		// the lease runs out and the runs already recorded are not repeated
+		log.Printf("cannot advance standing order %d: %v", arg.ID, err)
+	}
+}
+
+// finished reports whether an order has no occurrence left at next
+func finished(order db.StandingOrder, next time.Time, occurrences int32) bool {
+	return next.IsZero() ||
+		(order.EndAt.Valid && next.After(order.EndAt.Time)) ||
+		(order.MaxOccurrences.Valid && occurrences >= order.MaxOccurrences.Int32)
+}
+
+// skipped applies the catch-up policy of an order to an occurrence due at next
+func skipped(order db.StandingOrder, next time.Time, following time.Time, occurrences int32, now time.Time) bool {
+	switch order.CatchUp {
+	case db.CatchUpAll:
+		return false
+	case db.CatchUpSkip:
+		return now.Sub(next) > lateAfter
+	default:
+		// only the most recent occurrence that counts is booked
+		return !following.After(now) && !finished(order, following, occurrences+1)
+	}
+}
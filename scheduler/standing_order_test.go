@@ -0,0 +1,229 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockdb "github.com/minhdang2803/simple_bank/db/mock"
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+	"github.com/minhdang2803/simple_bank/fx"
+	"github.com/minhdang2803/simple_bank/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunStandingOrders(t *testing.T) {
+	now := date(2024, 3, 1, 12, 0)
+	account1 := randomAccount(utils.USD)
+	account2 := randomAccount(utils.USD)
+
+	order := db.StandingOrder{
+		ID:            utils.RandomInt(1, 1000),
+		Owner:         account1.Owner,
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+		Currency:      utils.USD,
+		Schedule:      "@hourly",
+		CatchUp:       db.CatchUpLatest,
+		StartAt:       date(2024, 3, 1, 0, 0),
+		Status:        db.StandingOrderActive,
+		NextRunAt:     now,
+	}
+	result := db.TransferTxResult{Transfer: db.Transfer{ID: utils.RandomInt(1, 1000)}}
+
+	testCases := []struct {
+		name        string
+		order       func(order db.StandingOrder) db.StandingOrder
+		transferErr error
+		// runs are the statuses recorded for each occurrence
+		runs    []string
+		advance db.AdvanceStandingOrderParams
+	}{
+		{
+			name:  "Due occurrence",
+			order: func(order db.StandingOrder) db.StandingOrder { return order },
+			runs:  []string{db.StandingOrderRunCompleted},
+			advance: db.AdvanceStandingOrderParams{
+				NextRunAt:   date(2024, 3, 1, 13, 0),
+				Occurrences: 1,
+				Status:      db.StandingOrderActive,
+			},
+		},
+		{
+			name: "Latest catch-up",
+			order: func(order db.StandingOrder) db.StandingOrder {
+				order.NextRunAt = date(2024, 3, 1, 9, 0)
+				return order
+			},
+			runs: []string{
+				db.StandingOrderRunSkipped,
+				db.StandingOrderRunSkipped,
+				db.StandingOrderRunSkipped,
+				db.StandingOrderRunCompleted,
+			},
+			advance: db.AdvanceStandingOrderParams{
+				NextRunAt:   date(2024, 3, 1, 13, 0),
+				Occurrences: 4,
+				Status:      db.StandingOrderActive,
+			},
+		},
+		{
+			name: "All catch-up",
+			order: func(order db.StandingOrder) db.StandingOrder {
+				order.NextRunAt = date(2024, 3, 1, 10, 0)
+				order.CatchUp = db.CatchUpAll
+				return order
+			},
+			runs: []string{
+				db.StandingOrderRunCompleted,
+				db.StandingOrderRunCompleted,
+				db.StandingOrderRunCompleted,
+			},
+			advance: db.AdvanceStandingOrderParams{
+				NextRunAt:   date(2024, 3, 1, 13, 0),
+				Occurrences: 3,
+				Status:      db.StandingOrderActive,
+			},
+		},
+		{
+			name: "Skip catch-up",
+			order: func(order db.StandingOrder) db.StandingOrder {
+				order.NextRunAt = date(2024, 3, 1, 9, 0)
+				order.CatchUp = db.CatchUpSkip
+				return order
+			},
+			runs: []string{
+				db.StandingOrderRunSkipped,
+				db.StandingOrderRunSkipped,
+				db.StandingOrderRunCompleted,
+				db.StandingOrderRunCompleted,
+			},
+			advance: db.AdvanceStandingOrderParams{
+				NextRunAt:   date(2024, 3, 1, 13, 0),
+				Occurrences: 4,
+				Status:      db.StandingOrderActive,
+			},
+		},
+		{
+			name: "Latest catch-up before the end date",
+			order: func(order db.StandingOrder) db.StandingOrder {
+				order.NextRunAt = date(2024, 3, 1, 9, 0)
+				order.EndAt = sql.NullTime{Time: date(2024, 3, 1, 10, 30), Valid: true}
+				return order
+			},
+			runs: []string{
+				db.StandingOrderRunSkipped,
+				db.StandingOrderRunCompleted,
+			},
+			advance: db.AdvanceStandingOrderParams{
+				NextRunAt:   date(2024, 3, 1, 11, 0),
+				Occurrences: 2,
+				Status:      db.StandingOrderCompleted,
+			},
+		},
+		{
+			name: "Max occurrences",
+			order: func(order db.StandingOrder) db.StandingOrder {
+				order.Occurrences = 2
+				order.MaxOccurrences = sql.NullInt32{Int32: 3, Valid: true}
+				return order
+			},
+			runs: []string{db.StandingOrderRunCompleted},
+			advance: db.AdvanceStandingOrderParams{
+				NextRunAt:   date(2024, 3, 1, 13, 0),
+				Occurrences: 3,
+				Status:      db.StandingOrderCompleted,
+			},
+		},
+		{
+			name:        "Insufficient funds",
+			order:       func(order db.StandingOrder) db.StandingOrder { return order },
+			transferErr: db.ErrInsufficientFunds,
+			runs:        []string{db.StandingOrderRunFailed},
+			advance: db.AdvanceStandingOrderParams{
+				NextRunAt:   date(2024, 3, 1, 13, 0),
+				Occurrences: 1,
+				Status:      db.StandingOrderActive,
+			},
+		},
+		{
+			name:        "Frozen account",
+			order:       func(order db.StandingOrder) db.StandingOrder { return order },
+			transferErr: db.ErrAccountFrozen,
+			runs:        []string{db.StandingOrderRunFailed},
+			advance: db.AdvanceStandingOrderParams{
+				NextRunAt:   date(2024, 3, 1, 13, 0),
+				Occurrences: 1,
+				Status:      db.StandingOrderActive,
+			},
+		},
+		{
+			name:        "Retried later",
+			order:       func(order db.StandingOrder) db.StandingOrder { return order },
+			transferErr: errors.New("connection reset by peer"),
+			advance: db.AdvanceStandingOrderParams{
+				NextRunAt:   now,
+				Occurrences: 0,
+				Status:      db.StandingOrderActive,
+				LockedUntil: sql.NullTime{Time: now.Add(minBackoff), Valid: true},
+			},
+		},
+	}
+
+	rates, err := fx.NewStaticRateProvider(fx.DefaultRates)
+	require.NoError(t, err)
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mockdb.NewMockStore(ctrl)
+
+			order := tc.order(order)
+			store.EXPECT().ClaimDueScheduledTransfers(gomock.Any(), gomock.Any()).Times(1).Return([]db.ScheduledTransfer{}, nil)
+			store.EXPECT().ClaimDueStandingOrders(gomock.Any(), gomock.Eq(db.ClaimDueStandingOrdersParams{
+				LeaseSeconds: int32(lease / time.Second),
+				Limit:        batchSize,
+			})).Times(1).Return([]db.StandingOrder{order}, nil)
+			store.EXPECT().ExpireHolds(gomock.Any(), gomock.Any()).Times(1).Return([]db.Hold{}, nil)
+
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).AnyTimes().Return(account1, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).AnyTimes().Return(account2, nil)
+			store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).AnyTimes().
+				DoAndReturn(func(_ context.Context, arg db.TransferTxParams) (db.TransferTxResult, error) {
+					require.Equal(t, order.Amount, arg.Amount)
+					require.Equal(t, order.Owner, arg.Idempotency.Username)
+					require.Regexp(t, fmt.Sprintf("^%sstanding-order-%d-[0-9]+$", db.SystemIdempotencyKeyPrefix, order.ID), arg.Idempotency.Key)
+					return result, tc.transferErr
+				})
+
+			var runs []string
+			store.EXPECT().CreateStandingOrderRun(gomock.Any(), gomock.Any()).Times(len(tc.runs)).
+				DoAndReturn(func(_ context.Context, arg db.CreateStandingOrderRunParams) error {
+					require.Equal(t, order.ID, arg.StandingOrderID)
+					require.Equal(t, arg.Status == db.StandingOrderRunCompleted, arg.TransferID.Valid)
+					runs = append(runs, arg.Status)
+					return nil
+				})
+
+			advance := tc.advance
+			advance.ID = order.ID
+			store.EXPECT().AdvanceStandingOrder(gomock.Any(), gomock.Eq(advance)).Times(1)
+
+			executor := NewExecutor(store, rates, time.Second)
+			executor.now = func() time.Time { return now }
+
+			claimed, err := executor.RunOnce(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, 1, claimed)
+			require.Equal(t, tc.runs, runs)
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package token
+
+import "time"
+
+// Maker manages the creation and verification of access tokens
+type Maker interface {
+	// CreateToken creates a new token of tokenType for a specific username, role and duration
+	CreateToken(username string, role string, tokenType TokenType, duration time.Duration) (string, *Payload, error)
+
+	// VerifyToken checks if the token is valid and of tokenType
+	VerifyToken(token string, tokenType TokenType) (*Payload, error)
+}
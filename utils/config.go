@@ -1,11 +1,43 @@
 package utils
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Config struct {
-	DBDriver      string `mapstructure:"DB_DRIVER"`
-	DBSource      string `mapstructure:"DB_SOURCE"`
-	ServerAddress string `mapstructure:"SERVER_ADDRESS"`
+	DBDriver string `mapstructure:"DB_DRIVER"`
+	DBSource string `mapstructure:"DB_SOURCE"`
+	// DBMaxOpenConns, DBMaxIdleConns and DBConnMaxLifetime size the
+	// connection pool, 0 keeps the defaults of database/sql
+	DBMaxOpenConns    int           `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns    int           `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime time.Duration `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	// DBConnectTimeout is how long startup retries to reach the database
+	DBConnectTimeout      time.Duration `mapstructure:"DB_CONNECT_TIMEOUT"`
+	ServerAddress         string        `mapstructure:"SERVER_ADDRESS"`
+	TokenSymmetricKey     string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	AccessTokenDuration   time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration  time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	FXRatesFile           string        `mapstructure:"FX_RATES_FILE"`
+	CurrenciesFile        string        `mapstructure:"CURRENCIES_FILE"`
+	MaxPageSize           int32         `mapstructure:"MAX_PAGE_SIZE"`
+	SchedulerPollInterval time.Duration `mapstructure:"SCHEDULER_POLL_INTERVAL"`
+	// TransferIsolation is the isolation level of transactions that move
+	// money, read_committed, repeatable_read or serializable
+	TransferIsolation string `mapstructure:"TRANSFER_ISOLATION"`
+	// TxMaxAttempts bounds how often a transaction aborted by a deadlock or a
+	// serialization failure is run, 0 keeps the default
+	TxMaxAttempts int `mapstructure:"TX_MAX_ATTEMPTS"`
+	// AutoMigrate applies the embedded migrations before the server starts
+	AutoMigrate bool `mapstructure:"AUTO_MIGRATE"`
+	// ShutdownTimeout is how long the requests in flight may take to finish
+	// once the server is told to stop
+	ShutdownTimeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	// MetricsAddress is where /metrics is served, apart from the API so that
+	// it stays on an internal port. Empty doesn't serve the metrics.
+	MetricsAddress string `mapstructure:"METRICS_ADDRESS"`
 }
 
 func LoadConfig(path string) (config *Config, err error) {
@@ -1,15 +1,171 @@
 package utils
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+)
+
 const (
 	USD = "USD"
 	EUR = "EUR"
 	CAD = "CAD"
+	JPY = "JPY"
+	BHD = "BHD"
 )
 
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// maxMinorUnits keeps 10^MinorUnits well inside int64 amounts
+const maxMinorUnits = 4
+
+var currencyCodeRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Currency describes an ISO 4217 currency
+type Currency struct {
+	Code        string `json:"code"`
+	NumericCode int    `json:"numeric_code"`
+	// MinorUnits is the number of decimal places, 2 for USD, 0 for JPY
+	MinorUnits int    `json:"minor_units"`
+	Symbol     string `json:"symbol"`
+	Enabled    bool   `json:"enabled"`
+}
+
+func (currency Currency) validate() error {
+	if !currencyCodeRegexp.MatchString(currency.Code) {
+		return fmt.Errorf("invalid currency code %q", currency.Code)
+	}
+	if currency.MinorUnits < 0 || currency.MinorUnits > maxMinorUnits {
+		return fmt.Errorf("currency %s: minor units must be between 0 and %d", currency.Code, maxMinorUnits)
+	}
+	return nil
+}
+
+// CurrencyRegistry holds the currencies the bank knows about, only enabled
+// ones can be used for new accounts and transfers
+type CurrencyRegistry struct {
+	mutex      sync.RWMutex
+	currencies map[string]Currency
+}
+
+func NewCurrencyRegistry(currencies ...Currency) (*CurrencyRegistry, error) {
+	registry := &CurrencyRegistry{}
+	if err := registry.Replace(currencies); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// Replace swaps the whole registry content, nothing changes if a currency is invalid
+func (registry *CurrencyRegistry) Replace(currencies []Currency) error {
+	table := make(map[string]Currency, len(currencies))
+	for _, currency := range currencies {
+		if err := currency.validate(); err != nil {
+			return err
+		}
+		table[currency.Code] = currency
+	}
+
+	registry.mutex.Lock()
+	registry.currencies = table
+	registry.mutex.Unlock()
+	return nil
+}
+
+// Register adds a currency or overwrites the one with the same code
+func (registry *CurrencyRegistry) Register(currency Currency) error {
+	if err := currency.validate(); err != nil {
+		return err
+	}
+
+	registry.mutex.Lock()
+	registry.currencies[currency.Code] = currency
+	registry.mutex.Unlock()
+	return nil
+}
+
+// Lookup returns a known currency whether or not it is enabled
+func (registry *CurrencyRegistry) Lookup(code string) (Currency, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	currency, ok := registry.currencies[code]
+	return currency, ok
+}
+
+// IsSupported reports whether the currency is known and enabled
+func (registry *CurrencyRegistry) IsSupported(code string) bool {
+	currency, ok := registry.Lookup(code)
+	return ok && currency.Enabled
+}
+
+func (registry *CurrencyRegistry) SetEnabled(code string, enabled bool) (Currency, error) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	currency, ok := registry.currencies[code]
+	if !ok {
+		return Currency{}, fmt.Errorf("%w: %s", ErrUnknownCurrency, code)
+	}
+	currency.Enabled = enabled
+	registry.currencies[code] = currency
+	return currency, nil
+}
+
+// List returns every known currency sorted by code
+func (registry *CurrencyRegistry) List() []Currency {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	currencies := make([]Currency, 0, len(registry.currencies))
+	for _, currency := range registry.currencies {
+		currencies = append(currencies, currency)
+	}
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i].Code < currencies[j].Code
+	})
+	return currencies
+}
+
+// DefaultCurrencies mirrors the rows seeded by the currencies migration
+var DefaultCurrencies = []Currency{
+	{Code: USD, NumericCode: 840, MinorUnits: 2, Symbol: "$", Enabled: true},
+	{Code: EUR, NumericCode: 978, MinorUnits: 2, Symbol: "€", Enabled: true},
+	{Code: CAD, NumericCode: 124, MinorUnits: 2, Symbol: "CA$", Enabled: true},
+	{Code: JPY, NumericCode: 392, MinorUnits: 0, Symbol: "¥", Enabled: false},
+	{Code: BHD, NumericCode: 48, MinorUnits: 3, Symbol: "BD", Enabled: false},
+}
+
+// Currencies is the registry used by request validation and money formatting,
+// it starts with DefaultCurrencies and is replaced at startup from config or the database
+var Currencies = mustCurrencyRegistry(DefaultCurrencies...)
+
+func mustCurrencyRegistry(currencies ...Currency) *CurrencyRegistry {
+	registry, err := NewCurrencyRegistry(currencies...)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
 func IsSupportedCurrency(currency string) bool {
-	switch currency {
-	case USD, EUR, CAD:
-		return true
+	return Currencies.IsSupported(currency)
+}
+
+// LoadCurrencies reads a JSON array of currencies, see Currency for the keys
+func LoadCurrencies(path string) ([]Currency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read currencies file: %w", err)
+	}
+
+	var currencies []Currency
+	if err := json.Unmarshal(data, &currencies); err != nil {
+		return nil, fmt.Errorf("cannot parse currencies file %s: %w", path, err)
 	}
-	return false
+	return currencies, nil
 }
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrencyRegistry(t *testing.T) {
+	registry, err := NewCurrencyRegistry(DefaultCurrencies...)
+	require.NoError(t, err)
+
+	require.True(t, registry.IsSupported(USD))
+	require.False(t, registry.IsSupported(JPY))
+	require.False(t, registry.IsSupported("XXX"))
+
+	currency, ok := registry.Lookup(JPY)
+	require.True(t, ok)
+	require.Equal(t, 0, currency.MinorUnits)
+
+	currency, err = registry.SetEnabled(JPY, true)
+	require.NoError(t, err)
+	require.True(t, currency.Enabled)
+	require.True(t, registry.IsSupported(JPY))
+
+	_, err = registry.SetEnabled("XXX", true)
+	require.ErrorIs(t, err, ErrUnknownCurrency)
+
+	currencies := registry.List()
+	require.Len(t, currencies, len(DefaultCurrencies))
+	for i := 1; i < len(currencies); i++ {
+		require.Less(t, currencies[i-1].Code, currencies[i].Code)
+	}
+}
+
+func TestCurrencyRegistryReplace(t *testing.T) {
+	registry, err := NewCurrencyRegistry(DefaultCurrencies...)
+	require.NoError(t, err)
+
+	err = registry.Replace([]Currency{{Code: "usd", MinorUnits: 2}})
+	require.Error(t, err)
+	err = registry.Replace([]Currency{{Code: "XAA", MinorUnits: 9}})
+	require.Error(t, err)
+
+	// a failed replace keeps the previous content
+	require.True(t, registry.IsSupported(USD))
+
+	err = registry.Replace([]Currency{{Code: "GBP", NumericCode: 826, MinorUnits: 2, Symbol: "£", Enabled: true}})
+	require.NoError(t, err)
+	require.True(t, registry.IsSupported("GBP"))
+	require.False(t, registry.IsSupported(USD))
+
+	err = registry.Register(Currency{Code: USD, NumericCode: 840, MinorUnits: 2, Symbol: "$", Enabled: true})
+	require.NoError(t, err)
+	require.True(t, registry.IsSupported(USD))
+	require.Len(t, registry.List(), 2)
+}
+
+func TestLoadCurrencies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "currencies.json")
+	data := `[{"code": "GBP", "numeric_code": 826, "minor_units": 2, "symbol": "£", "enabled": true}]`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	currencies, err := LoadCurrencies(path)
+	require.NoError(t, err)
+	require.Equal(t, []Currency{{Code: "GBP", NumericCode: 826, MinorUnits: 2, Symbol: "£", Enabled: true}}, currencies)
+
+	_, err = LoadCurrencies(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+var amountRegexp = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// Money is an amount in the minor units of its currency (cents for USD).
+// It is exchanged with clients as a decimal string such as "12.50".
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney converts a decimal string into minor units, it rejects more
+// decimal places than the currency has
+func ParseMoney(value string, currency string) (Money, error) {
+	info, ok := Currencies.Lookup(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %s", ErrUnknownCurrency, currency)
+	}
+	if !amountRegexp.MatchString(value) {
+		return Money{}, fmt.Errorf("%w: %q is not a decimal number", ErrInvalidAmount, value)
+	}
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if len(fraction) > info.MinorUnits {
+		return Money{}, fmt.Errorf("%w: %s allows at most %d decimal places", ErrInvalidAmount, currency, info.MinorUnits)
+	}
+
+	fraction += strings.Repeat("0", info.MinorUnits-len(fraction))
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, value)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// String formats the amount with the currency's decimal places, amounts in
+// an unknown currency are printed in minor units
+func (money Money) String() string {
+	info, ok := Currencies.Lookup(money.Currency)
+	if !ok || info.MinorUnits == 0 {
+		return strconv.FormatInt(money.Amount, 10)
+	}
+
+	sign := ""
+	amount := uint64(money.Amount)
+	if money.Amount < 0 {
+		sign = "-"
+		amount = uint64(-(money.Amount + 1)) + 1 // avoids overflow on math.MinInt64
+	}
+
+	scale := uint64(math.Pow10(info.MinorUnits))
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, info.MinorUnits, amount%scale)
+}
+
+func (money Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(money.String())
+}
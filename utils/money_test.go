@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoneyString(t *testing.T) {
+	testCases := []struct {
+		money    Money
+		expected string
+	}{
+		{NewMoney(1250, USD), "12.50"},
+		{NewMoney(5, USD), "0.05"},
+		{NewMoney(-5, EUR), "-0.05"},
+		{NewMoney(0, CAD), "0.00"},
+		{NewMoney(1250, JPY), "1250"},
+		{NewMoney(1250, BHD), "1.250"},
+		{NewMoney(42, "XXX"), "42"},
+		{NewMoney(math.MinInt64, USD), "-92233720368547758.08"},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, tc.money.String())
+	}
+
+	data, err := json.Marshal(NewMoney(1250, USD))
+	require.NoError(t, err)
+	require.Equal(t, `"12.50"`, string(data))
+}
+
+func TestParseMoney(t *testing.T) {
+	testCases := []struct {
+		value    string
+		currency string
+		expected int64
+	}{
+		{"12.50", USD, 1250},
+		{"12.5", USD, 1250},
+		{"12", USD, 1200},
+		{"-0.05", USD, -5},
+		{"1250", JPY, 1250},
+		{"1.25", BHD, 1250},
+	}
+
+	for _, tc := range testCases {
+		money, err := ParseMoney(tc.value, tc.currency)
+		require.NoError(t, err)
+		require.Equal(t, NewMoney(tc.expected, tc.currency), money)
+	}
+
+	for _, value := range []string{"", "abc", "1.", ".5", "1.005", "1e3", "99999999999999999999"} {
+		_, err := ParseMoney(value, USD)
+		require.ErrorIs(t, err, ErrInvalidAmount, value)
+	}
+
+	_, err := ParseMoney("1.5", JPY)
+	require.ErrorIs(t, err, ErrInvalidAmount)
+
+	_, err = ParseMoney("1", "XXX")
+	require.ErrorIs(t, err, ErrUnknownCurrency)
+}
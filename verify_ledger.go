@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	db "github.com/minhdang2803/simple_bank/db/sqlc"
+)
+
+// Exit codes of the verify-ledger subcommand
+const (
+	exitLedgerOK       = 0
+	exitLedgerMismatch = 1
+	exitLedgerError    = 2
+)
+
+// runVerifyLedger runs the verify-ledger subcommand and returns its exit code,
+// non-zero when the ledger is inconsistent or couldn't be checked
+func runVerifyLedger(ctx context.Context, store db.Store, args []string) int {
+	flags := flag.NewFlagSet("verify-ledger", flag.ExitOnError)
+	snapshot := flags.Bool("snapshot", false, "run every check in a single repeatable read snapshot")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	report, err := store.VerifyLedger(ctx, db.VerifyLedgerParams{Snapshot: *snapshot})
+	if err != nil {
+		log.Printf("cannot verify ledger: %v", err)
+		return exitLedgerError
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = printLedgerReport(os.Stdout, report)
+	}
+	if err != nil {
+		log.Printf("cannot print ledger report: %v", err)
+		return exitLedgerError
+	}
+
+	if !report.OK() {
+		return exitLedgerMismatch
+	}
+	return exitLedgerOK
+}
+
+func printLedgerReport(w io.Writer, report db.LedgerReport) error {
+	_, err := fmt.Fprintf(w, "checked %d accounts, %d entries and %d transfers at %s (snapshot: %t)\n",
+		report.Accounts, report.Entries, report.Transfers, report.CheckedAt.Format("2006-01-02 15:04:05 MST"), report.Snapshot)
+	if err != nil {
+		return err
+	}
+
+	for _, discrepancy := range report.Discrepancies {
+		subject := fmt.Sprintf("account [%d]", discrepancy.AccountID)
+		if discrepancy.TransferID != 0 {
+			subject = fmt.Sprintf("transfer [%d]", discrepancy.TransferID)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s %s\n", discrepancy.Kind, subject, discrepancy.Detail); err != nil {
+			return err
+		}
+	}
+
+	if report.OK() {
+		_, err = fmt.Fprintln(w, "ledger is consistent")
+	} else {
+		_, err = fmt.Fprintf(w, "found %d discrepancies\n", len(report.Discrepancies))
+	}
+	return err
+}